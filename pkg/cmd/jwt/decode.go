@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/scottcagno/webslinger/pkg/web/jwt"
+)
+
+// decode prints the header and claims of the token without verifying it.
+func decode(args []string) error {
+	fs := flag.NewFlagSet("decode", flag.ExitOnError)
+	fs.Parse(args)
+
+	raw, err := readToken(fs.Args())
+	if err != nil {
+		return err
+	}
+	token, err := jwt.DefaultParser.Parse(raw)
+	if err != nil {
+		return &codeError{exitMalformed, err}
+	}
+	return printToken(stdout, raw, token)
+}
+
+// printToken pretty-prints the header and claims of the token. The time
+// claims are annotated with the time they represent, relative to now.
+func printToken(w io.Writer, raw jwt.RawToken, token *jwt.Token) error {
+	// Print the segments as they were encoded, instead of re-marshalling
+	// the decoded structs, so nothing is lost (or reordered)
+	for _, section := range []jwt.Section{jwt.HeaderSection, jwt.PayloadSection} {
+		i, j := raw.GetSection(section)
+		dat, err := base64.RawURLEncoding.DecodeString(string(raw[i:j]))
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		err = json.Indent(&buf, dat, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s:\n%s\n", section, buf.Bytes())
+	}
+	claims := token.Payload
+	now := time.Now()
+	for _, name := range []string{"iat", "nbf", "exp"} {
+		var date jwt.NumericDate
+		var err error
+		switch name {
+		case "iat":
+			date, err = claims.GetIAT()
+		case "nbf":
+			date, err = claims.GetNBF()
+		case "exp":
+			date, err = claims.GetEXP()
+		}
+		if err != nil {
+			continue
+		}
+		t := date.Time()
+		fmt.Fprintf(w, "%s: %s (%s)\n", name, t.Format(time.RFC3339), relative(now, t))
+	}
+	return nil
+}
+
+// relative describes t relative to now, e.g. "in 5m0s" or "5m0s ago".
+func relative(now, t time.Time) string {
+	d := t.Sub(now).Truncate(time.Second)
+	if d < 0 {
+		return (-d).String() + " ago"
+	}
+	return "in " + d.String()
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/scottcagno/webslinger/pkg/web/jwt"
+)
+
+// keygen generates a key pair for the signing method. The private key is
+// written to stdout, unless an output prefix is provided, in which case
+// the private and public keys are written to <prefix>.<ext> and
+// <prefix>.pub.<ext>.
+func keygen(args []string) error {
+	fs := flag.NewFlagSet("keygen", flag.ExitOnError)
+	alg := fs.String("alg", "", "signing method to generate a key for (e.g. ES256)")
+	format := fs.String("format", "pem", "output format, pem or jwk")
+	kid := fs.String("kid", "", "key id for jwk output (defaults to the thumbprint)")
+	out := fs.String("out", "", "write the keys to files using this prefix")
+	passFile := fs.String("passfile", "", "encrypt the pem private key using the password in this file")
+	fs.Parse(args)
+
+	method, err := signingMethod(*alg)
+	if err != nil {
+		return err
+	}
+	password, err := readPassword(*passFile)
+	if err != nil {
+		return err
+	}
+	keys := method.GenerateKeyPair()
+	if keys == nil {
+		return errors.New("could not generate key pair")
+	}
+
+	var pri, pub []byte
+	var ext string
+	switch *format {
+	case "pem":
+		ext = "pem"
+		pri, pub, err = marshalPEM(method, keys, password)
+	case "jwk":
+		ext = "jwk"
+		pri, pub, err = marshalJWK(method, keys, *kid)
+	default:
+		err = fmt.Errorf("unknown format %q", *format)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		_, err = stdout.Write(pri)
+		return err
+	}
+	err = os.WriteFile(*out+"."+ext, pri, 0600)
+	if err != nil {
+		return err
+	}
+	if pub == nil {
+		return nil
+	}
+	return os.WriteFile(*out+".pub."+ext, pub, 0644)
+}
+
+// marshalPEM encodes the key pair as PEM. HMAC secrets are written as-is,
+// and have no public half.
+func marshalPEM(method jwt.SigningMethod, keys *jwt.KeyPair, password []byte) ([]byte, []byte, error) {
+	if isHMAC(method) {
+		secret := keys.PrivateKey.([]byte)
+		return append(secret, '\n'), nil, nil
+	}
+	var pri []byte
+	var err error
+	if password != nil {
+		pri, err = jwt.MarshalPrivateKeyToEncryptedPEM(keys.PrivateKey, password)
+	} else {
+		pri, err = jwt.MarshalPrivateKeyToPEM(keys.PrivateKey)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	pub, err := jwt.MarshalPublicKeyToPEM(keys.PublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pri, pub, nil
+}
+
+// marshalJWK encodes the key pair as JWKs. Symmetric keys have no public
+// half.
+func marshalJWK(method jwt.SigningMethod, keys *jwt.KeyPair, kid string) ([]byte, []byte, error) {
+	jwk, err := keys.JWK()
+	if err != nil {
+		return nil, nil, err
+	}
+	jwk.Alg = method.Name()
+	jwk.Use = "sig"
+	jwk.Kid = kid
+	if kid == "" && !isHMAC(method) {
+		jwk.Kid, err = jwt.KeyID(keys.PublicKey)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	pri, err := json.MarshalIndent(jwk, "", "  ")
+	if err != nil {
+		return nil, nil, err
+	}
+	pri = append(pri, '\n')
+	public := jwk.Public()
+	if public == nil {
+		return pri, nil, nil
+	}
+	pub, err := json.MarshalIndent(public, "", "  ")
+	if err != nil {
+		return nil, nil, err
+	}
+	return pri, append(pub, '\n'), nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/scottcagno/webslinger/pkg/web/jwt"
+)
+
+// signingMethod looks up the signing method with the provided name.
+func signingMethod(alg string) (jwt.SigningMethod, error) {
+	if alg == "" {
+		return nil, errors.New("the -alg flag is required")
+	}
+	method := jwt.GetSigningMethod(alg)
+	if method == nil {
+		return nil, fmt.Errorf("unknown signing method %q", alg)
+	}
+	return method, nil
+}
+
+// isHMAC reports whether the signing method is an HMAC method, which uses
+// a raw secret instead of a key pair.
+func isHMAC(method jwt.SigningMethod) bool {
+	_, ok := method.(*jwt.SigningMethodHMAC)
+	return ok
+}
+
+// readPassword reads the password for an encrypted private key from the
+// provided file, if there is one.
+func readPassword(path string) ([]byte, error) {
+	if path == "" {
+		return nil, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(b, "\r\n"), nil
+}
+
+// loadSigningKey loads the private key for the signing method from a PEM
+// or JWK file, or the raw secret for HMAC methods. The kid of a JWK is
+// returned as well.
+func loadSigningKey(method jwt.SigningMethod, path string, password []byte) (crypto.PrivateKey, string, error) {
+	if path == "" {
+		return nil, "", errors.New("the -key flag is required")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	if isJSON(data) {
+		jwk, err := jwt.ParseJWK(data)
+		if err != nil {
+			return nil, "", err
+		}
+		if !jwk.IsPrivate() {
+			return nil, "", fmt.Errorf("%s does not hold a private key", path)
+		}
+		keys, err := jwt.KeyPairFromJWK(jwk)
+		if err != nil {
+			return nil, "", err
+		}
+		err = jwt.ValidateKeyPair(method, keys)
+		if err != nil {
+			return nil, "", err
+		}
+		return keys.PrivateKey, jwk.Kid, nil
+	}
+	keys, err := jwt.KeyPairFromPEM(method, data, nil, password)
+	if err != nil {
+		return nil, "", err
+	}
+	return keys.PrivateKey, "", nil
+}
+
+// loadVerificationKey loads the public key for the signing method from a
+// PEM file (a public key or certificate), a JWK, a JWK set (using the kid
+// of the token), or the raw secret for HMAC methods.
+func loadVerificationKey(method jwt.SigningMethod, path string, kid string) (crypto.PublicKey, error) {
+	if path == "" {
+		return nil, errors.New("the -key flag is required")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if isHMAC(method) && !isJSON(data) {
+		return bytes.TrimSpace(data), nil
+	}
+	if isJSON(data) {
+		jwk, err := jwt.ParseJWK(data)
+		if err != nil {
+			// Not a single key, try a key set
+			set, err := jwt.ParseJWKSet(data)
+			if err != nil {
+				return nil, err
+			}
+			jwk = set.Key(kid)
+			if jwk == nil {
+				return nil, fmt.Errorf("no key with kid %q in %s", kid, path)
+			}
+		}
+		return jwk.PublicKey()
+	}
+	return jwt.ParsePublicKeyFromPEM(data)
+}
+
+func isJSON(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("{"))
+}
+
+// readToken returns the token from the arguments, or from stdin if it was
+// left out or given as "-".
+func readToken(args []string) (jwt.RawToken, error) {
+	if len(args) > 1 {
+		return nil, errors.New("too many arguments")
+	}
+	var token string
+	if len(args) == 1 && args[0] != "-" {
+		token = args[0]
+	} else {
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		token = string(b)
+	}
+	token = strings.TrimSpace(token)
+	token = strings.TrimPrefix(token, "Bearer ")
+	if token == "" {
+		return nil, errors.New("no token provided")
+	}
+	return jwt.RawToken(token), nil
+}
@@ -1,15 +1,99 @@
+// Command jwt generates keys, and signs, decodes and verifies tokens, so
+// tokens can be debugged without writing any Go.
+//
+// Usage:
+//
+//	jwt keygen -alg ES256 [-format pem|jwk] [-kid id] [-passfile file] [-out prefix]
+//	jwt sign   -alg ES256 -key private.pem [-claims file.json] [-claim k=v]... [-exp 1h]
+//	jwt decode [token]
+//	jwt verify -alg ES256 -key public.pem [-iss iss] [-aud aud] [-sub sub] [-leeway 30s] [-q] [token]
+//
+// When the token is left out, or given as "-", it is read from stdin.
 package main
 
 import (
-	"github.com/scottcagno/webslinger/pkg/web/jwt"
+	"errors"
+	"fmt"
+	"io"
+	"os"
 )
 
-func main() {
+const usage = `usage: jwt <command> [flags] [token]
+
+commands:
+  keygen   generate a key pair for a signing method
+  sign     sign claims and print the token
+  decode   print the header and claims of a token without verifying it
+  verify   verify a token and print its header and claims
+
+run 'jwt <command> -h' for the flags of a command
 
-	method := jwt.RS256
+exit codes of verify:
+  0  the token is valid
+  1  usage or i/o error
+  2  the token is malformed
+  3  the signature, algorithm or key is invalid
+  4  the token has expired
+  5  the token is not valid yet
+  6  the claims do not match the expected values
+`
 
-	tm := jwt.NewTokenManager(method, method.GenerateKeyPair())
+// stdout is where the commands print their output.
+var stdout io.Writer = os.Stdout
 
-	_ = tm
+// Exit codes
+const (
+	exitOK = iota
+	exitError
+	exitMalformed
+	exitSignature
+	exitExpired
+	exitNotValidYet
+	exitClaims
+)
+
+// codeError is returned by a command to exit with a specific code.
+type codeError struct {
+	code int
+	err  error
+}
 
+func (e *codeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *codeError) Unwrap() error {
+	return e.err
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(exitError)
+	}
+	var err error
+	args := os.Args[2:]
+	switch os.Args[1] {
+	case "keygen":
+		err = keygen(args)
+	case "sign":
+		err = sign(args)
+	case "decode":
+		err = decode(args)
+	case "verify":
+		err = verify(args)
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		err = fmt.Errorf("unknown command %q\n\n%s", os.Args[1], usage)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "jwt:", err)
+		var ce *codeError
+		if errors.As(err, &ce) {
+			os.Exit(ce.code)
+		}
+		os.Exit(exitError)
+	}
 }
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/scottcagno/webslinger/pkg/web/jwt"
+)
+
+// claimFlags collects repeated -claim name=value flags. Values that are
+// valid JSON are kept as is, anything else is used as a string.
+type claimFlags jwt.MapClaims
+
+func (c claimFlags) String() string {
+	return ""
+}
+
+func (c claimFlags) Set(s string) error {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok || k == "" {
+		return fmt.Errorf("claim %q must be in the form name=value", s)
+	}
+	var val any
+	if json.Unmarshal([]byte(v), &val) != nil {
+		val = v
+	}
+	c[k] = val
+	return nil
+}
+
+// sign signs the claims and prints the token. The registered claims can be
+// set using flags, which override any read from the claims file. The exp
+// and iat claims are only added by default when the claims file does not
+// hold them, so a file can still be used to mint an expired token.
+func sign(args []string) error {
+	claims := make(jwt.MapClaims)
+	fs := flag.NewFlagSet("sign", flag.ExitOnError)
+	alg := fs.String("alg", "", "signing method (e.g. ES256)")
+	keyFile := fs.String("key", "", "private key file (PEM or JWK), or the secret for HMAC methods")
+	passFile := fs.String("passfile", "", "password file for an encrypted private key")
+	kid := fs.String("kid", "", "key id for the header (defaults to the kid of a JWK)")
+	claimsFile := fs.String("claims", "", "JSON file holding the claims, or - for stdin")
+	iss := fs.String("iss", "", "issuer claim")
+	sub := fs.String("sub", "", "subject claim")
+	aud := fs.String("aud", "", "audience claim")
+	jti := fs.String("jti", "", "token id claim")
+	exp := fs.Duration("exp", time.Hour, "expire the token after this duration, 0 to leave out (overrides the claims file only if set)")
+	nbf := fs.Duration("nbf", 0, "make the token valid after this duration")
+	iat := fs.Bool("iat", true, "add the issued at claim (overrides the claims file only if set)")
+	extra := make(claimFlags)
+	fs.Var(extra, "claim", "additional claim as name=value (repeatable)")
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	set := make(map[string]bool)
+	fs.Visit(
+		func(f *flag.Flag) {
+			set[f.Name] = true
+		},
+	)
+
+	method, err := signingMethod(*alg)
+	if err != nil {
+		return err
+	}
+	password, err := readPassword(*passFile)
+	if err != nil {
+		return err
+	}
+	key, keyID, err := loadSigningKey(method, *keyFile, password)
+	if err != nil {
+		return err
+	}
+	if *kid != "" {
+		keyID = *kid
+	}
+
+	if *claimsFile != "" {
+		err = readClaims(*claimsFile, &claims)
+		if err != nil {
+			return err
+		}
+	}
+	for k, v := range extra {
+		claims[k] = v
+	}
+	setString(claims, "iss", *iss)
+	setString(claims, "sub", *sub)
+	setString(claims, "aud", *aud)
+	setString(claims, "jti", *jti)
+	now := time.Now()
+	if set["exp"] || claims["exp"] == nil {
+		delete(claims, "exp")
+		if *exp != 0 {
+			claims["exp"] = now.Add(*exp).Unix()
+		}
+	}
+	if *nbf != 0 {
+		claims["nbf"] = now.Add(*nbf).Unix()
+	}
+	if set["iat"] || claims["iat"] == nil {
+		delete(claims, "iat")
+		if *iat {
+			claims["iat"] = now.Unix()
+		}
+	}
+
+	token, err := jwt.NewTokenWithHeader(method, jwt.TokenHeader{Kid: keyID}, claims, key)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, string(token))
+	return nil
+}
+
+// readClaims decodes the JSON claims in the file, or stdin if the path is
+// "-".
+func readClaims(path string, claims *jwt.MapClaims) error {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(data, claims)
+	if err != nil {
+		return fmt.Errorf("invalid claims: %w", err)
+	}
+	return nil
+}
+
+func setString(claims jwt.MapClaims, k, v string) {
+	if v != "" {
+		claims[k] = v
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scottcagno/webslinger/pkg/web/jwt"
+)
+
+// run runs the command with the arguments, and returns what it printed.
+func run(t *testing.T, cmd func(args []string) error, args ...string) (string, error) {
+	t.Helper()
+	var buf bytes.Buffer
+	stdout = &buf
+	t.Cleanup(func() { stdout = os.Stdout })
+	err := cmd(args)
+	return strings.TrimSpace(buf.String()), err
+}
+
+func writeFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSignVerify(t *testing.T) {
+	tests := []struct {
+		alg    string
+		format string
+	}{
+		{"HS256", "pem"},
+		{"HS256", "jwk"},
+		{"RS256", "pem"},
+		{"PS256", "jwk"},
+		{"ES256", "pem"},
+		{"ES384", "jwk"},
+		{"EdDSA", "pem"},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.alg+"/"+tt.format, func(t *testing.T) {
+				prefix := filepath.Join(t.TempDir(), "key")
+				_, err := run(t, keygen, "-alg", tt.alg, "-format", tt.format, "-out", prefix)
+				if err != nil {
+					t.Fatalf("error generating keys: %v", err)
+				}
+				private := prefix + "." + tt.format
+				public := prefix + ".pub." + tt.format
+				if _, err = os.Stat(public); err != nil {
+					// Secrets have no public half
+					public = private
+				}
+
+				token, err := run(
+					t, sign, "-alg", tt.alg, "-key", private, "-iss", "cli", "-sub", "jon doe",
+					"-claim", "admin=true", "-claim", "name=jon",
+				)
+				if err != nil {
+					t.Fatalf("error signing: %v", err)
+				}
+
+				// The token verifies, and is printed along with its claims
+				out, err := run(t, verify, "-alg", tt.alg, "-key", public, "-iss", "cli", "-sub", "jon doe", token)
+				if err != nil {
+					t.Fatalf("error verifying: %v", err)
+				}
+				if !strings.Contains(out, `"name": "jon"`) || !strings.Contains(out, `"admin": true`) {
+					t.Errorf("expected the claims to be printed, got:\n%s", out)
+				}
+
+				// Claims that do not match, and tampered tokens, are
+				// rejected with their own exit codes
+				_, err = run(t, verify, "-alg", tt.alg, "-key", public, "-iss", "other", "-q", token)
+				assertCode(t, exitClaims, err)
+				tampered := token[:len(token)-4] + "AAAA"
+				if tampered == token {
+					tampered = token[:len(token)-4] + "BBBB"
+				}
+				_, err = run(t, verify, "-alg", tt.alg, "-key", public, "-q", tampered)
+				assertCode(t, exitSignature, err)
+			},
+		)
+	}
+}
+
+func assertCode(t *testing.T, want int, err error) {
+	t.Helper()
+	var ce *codeError
+	if !errors.As(err, &ce) {
+		t.Errorf("expected exit code %d, got %v", want, err)
+		return
+	}
+	if ce.code != want {
+		t.Errorf("expected exit code %d, got %d: %v", want, ce.code, ce.err)
+	}
+}
+
+func TestSign_ClaimsFileTimes(t *testing.T) {
+	secret := writeFile(t, "secret", "0123456789abcdef0123456789abcdef")
+	now := time.Now().Unix()
+	const past = 1000000000
+
+	tests := []struct {
+		name    string
+		claims  string
+		args    []string
+		wantExp int64 // -1 when left out, 0 when set to about now + 1h
+		wantIat int64 // -1 when left out, 0 when set to about now
+	}{
+		{"defaults", `{}`, nil, 0, 0},
+		{"file keeps exp and iat", `{"exp":1000000000,"iat":1000000000}`, nil, past, past},
+		{"file keeps exp", `{"exp":1000000000}`, nil, past, 0},
+		{"explicit exp overrides", `{"exp":1000000000}`, []string{"-exp", "1h"}, 0, 0},
+		{"explicit iat overrides", `{"iat":1000000000}`, []string{"-iat=true"}, 0, 0},
+		{"explicit zero exp removes", `{"exp":1000000000}`, []string{"-exp", "0"}, -1, 0},
+		{"explicit false iat removes", `{"iat":1000000000}`, []string{"-iat=false"}, 0, -1},
+		{"zero exp without file", `{}`, []string{"-exp", "0"}, -1, 0},
+	}
+	for _, tt := range tests {
+		claims := writeFile(t, "claims.json", tt.claims)
+		args := append([]string{"-alg", "HS256", "-key", secret, "-claims", claims}, tt.args...)
+		token, err := run(t, sign, args...)
+		if err != nil {
+			t.Fatalf("[%s] error signing: %v", tt.name, err)
+		}
+		parsed, err := jwt.ParseRawToken(jwt.RawToken(token))
+		if err != nil {
+			t.Fatalf("[%s] error parsing: %v", tt.name, err)
+		}
+		check := func(claim string, want, offset int64) {
+			got, found := parsed.Payload[claim].(float64)
+			switch {
+			case want == -1:
+				if found {
+					t.Errorf("[%s] expected %s to be left out, got %v", tt.name, claim, got)
+				}
+			case want == 0:
+				if !found || int64(got) < now+offset || int64(got) > now+offset+60 {
+					t.Errorf("[%s] expected %s to be about %d, got %v", tt.name, claim, now+offset, parsed.Payload[claim])
+				}
+			default:
+				if int64(got) != want {
+					t.Errorf("[%s] expected %s to be %d, got %v", tt.name, claim, want, parsed.Payload[claim])
+				}
+			}
+		}
+		check("exp", tt.wantExp, int64(time.Hour/time.Second))
+		check("iat", tt.wantIat, 0)
+	}
+}
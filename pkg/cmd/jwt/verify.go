@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/scottcagno/webslinger/pkg/web/jwt"
+)
+
+// verify verifies the token and prints its header and claims. The exit
+// code reports why a token failed verification.
+func verify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	alg := fs.String("alg", "", "expected signing method (e.g. ES256)")
+	keyFile := fs.String("key", "", "public key file (PEM, certificate, JWK or JWK set), or the secret for HMAC methods")
+	iss := fs.String("iss", "", "expected issuer")
+	aud := fs.String("aud", "", "expected audience")
+	sub := fs.String("sub", "", "expected subject")
+	leeway := fs.Duration("leeway", 0, "leeway for the time claims to account for clock skew")
+	quiet := fs.Bool("q", false, "do not print the token")
+	fs.Parse(args)
+
+	method, err := signingMethod(*alg)
+	if err != nil {
+		return err
+	}
+	raw, err := readToken(fs.Args())
+	if err != nil {
+		return err
+	}
+	hdr, err := jwt.DefaultParser.ParseHeader(raw)
+	if err != nil {
+		return &codeError{exitMalformed, err}
+	}
+	key, err := loadVerificationKey(method, *keyFile, hdr.Kid)
+	if err != nil {
+		return err
+	}
+
+	v := &jwt.Validator{
+		Method:      method,
+		ExpectedISS: *iss,
+		ExpectedAUD: *aud,
+		ExpectedSUB: *sub,
+		Margin:      *leeway,
+	}
+	token, err := v.ValidateToken(raw, key)
+	if err != nil {
+		return &codeError{exitCode(err), describe(err)}
+	}
+	if !*quiet {
+		return printToken(stdout, raw, token)
+	}
+	return nil
+}
+
+// exitCode maps a validation error to an exit code. When several checks
+// failed, the most fundamental failure determines the code.
+func exitCode(err error) int {
+	var ve *jwt.ValidationError
+	switch {
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return exitMalformed
+	case !errors.As(err, &ve):
+		return exitSignature
+	case ve.Has(jwt.CheckAlgorithm | jwt.CheckKeyType | jwt.CheckCritical | jwt.CheckSignature):
+		return exitSignature
+	case ve.Has(jwt.CheckExpiresAt):
+		return exitExpired
+	case ve.Has(jwt.CheckNotBefore | jwt.CheckIssuedAt):
+		return exitNotValidYet
+	}
+	return exitClaims
+}
+
+// describe lists each failed check of a validation error on its own line,
+// so it is clear exactly why the token was rejected.
+func describe(err error) error {
+	var ve *jwt.ValidationError
+	if !errors.As(err, &ve) {
+		return err
+	}
+	var b strings.Builder
+	b.WriteString("token failed validation")
+	for _, f := range ve.Failures {
+		fmt.Fprintf(&b, "\n  %s: %s", f.Check, f)
+	}
+	return errors.New(b.String())
+}
@@ -0,0 +1,173 @@
+package jwt
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// benchKeys holds a key pair for every registered signing method. RSA keys
+// take a while to generate, so they are only generated once.
+var benchKeys struct {
+	once    sync.Once
+	names   []string
+	methods map[string]SigningMethod
+	keys    map[string]*KeyPair
+}
+
+// benchMethods returns the names of all the registered signing methods, in
+// order, generating a key pair for each of them on the first call.
+func benchMethods(b *testing.B) []string {
+	b.Helper()
+	benchKeys.once.Do(
+		func() {
+			benchKeys.methods = make(map[string]SigningMethod)
+			benchKeys.keys = make(map[string]*KeyPair)
+			methods.Range(
+				func(k, v any) bool {
+					method := v.(func() SigningMethod)()
+					benchKeys.names = append(benchKeys.names, k.(string))
+					benchKeys.methods[k.(string)] = method
+					benchKeys.keys[k.(string)] = method.GenerateKeyPair()
+					return true
+				},
+			)
+			sort.Strings(benchKeys.names)
+		},
+	)
+	return benchKeys.names
+}
+
+// benchClaims returns claims of the given size, all of them valid for the
+// next hour.
+func benchClaims(size string) MapClaims {
+	now := time.Now()
+	claims := MapClaims{
+		"sub": "1234567890",
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	if size == "small" {
+		return claims
+	}
+	claims["iss"] = "https://auth.example.com"
+	claims["aud"] = []string{"https://api.example.com"}
+	claims["iat"] = now.Unix()
+	claims["nbf"] = now.Unix()
+	claims["jti"] = "d2b1a0c4-8f5e-4c1b-9f3a-6e7d8c9b0a1f"
+	claims["name"] = "John Doe"
+	claims["email"] = "john.doe@example.com"
+	claims["scope"] = "openid profile email orders:read orders:write"
+	if size == "medium" {
+		return claims
+	}
+	roles := make([]string, 100)
+	for i := range roles {
+		roles[i] = fmt.Sprintf("role-%03d", i)
+	}
+	claims["roles"] = roles
+	return claims
+}
+
+var benchSizes = []string{"small", "medium", "large"}
+
+// runBench runs fn as a sub benchmark for every signing method and claims
+// size.
+func runBench(b *testing.B, fn func(b *testing.B, method SigningMethod, keys *KeyPair, claims MapClaims)) {
+	for _, name := range benchMethods(b) {
+		for _, size := range benchSizes {
+			method, keys, claims := benchKeys.methods[name], benchKeys.keys[name], benchClaims(size)
+			b.Run(
+				name+"/"+size, func(b *testing.B) {
+					b.ReportAllocs()
+					fn(b, method, keys, claims)
+				},
+			)
+		}
+	}
+}
+
+func BenchmarkNewToken(b *testing.B) {
+	runBench(
+		b, func(b *testing.B, method SigningMethod, keys *KeyPair, claims MapClaims) {
+			for i := 0; i < b.N; i++ {
+				if _, err := NewToken(method, claims, keys.PrivateKey); err != nil {
+					b.Fatal(err)
+				}
+			}
+		},
+	)
+}
+
+func BenchmarkParseRawToken(b *testing.B) {
+	runBench(
+		b, func(b *testing.B, method SigningMethod, keys *KeyPair, claims MapClaims) {
+			raw, err := NewToken(method, claims, keys.PrivateKey)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err = ParseRawToken(raw); err != nil {
+					b.Fatal(err)
+				}
+			}
+		},
+	)
+}
+
+func BenchmarkValidator_ValidateToken(b *testing.B) {
+	runBench(
+		b, func(b *testing.B, method SigningMethod, keys *KeyPair, claims MapClaims) {
+			raw, err := NewToken(method, claims, keys.PrivateKey)
+			if err != nil {
+				b.Fatal(err)
+			}
+			v := &Validator{Method: method}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err = v.ValidateToken(raw, keys.PublicKey); err != nil {
+					b.Fatal(err)
+				}
+			}
+		},
+	)
+}
+
+func BenchmarkTokenManager_GenerateToken(b *testing.B) {
+	for _, method := range []SigningMethod{HS256, ES256, EdDSA} {
+		tm := NewTokenManager(method, method.GenerateKeyPair())
+		claims := benchClaims("medium")
+		b.Run(
+			method.Name(), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := tm.GenerateToken(claims); err != nil {
+						b.Fatal(err)
+					}
+				}
+			},
+		)
+	}
+}
+
+func BenchmarkTokenManager_ValidateToken(b *testing.B) {
+	for _, method := range []SigningMethod{HS256, ES256, EdDSA} {
+		tm := NewTokenManager(method, method.GenerateKeyPair())
+		raw, err := tm.GenerateToken(benchClaims("medium"))
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(
+			method.Name(), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := tm.ValidateToken(raw); err != nil {
+						b.Fatal(err)
+					}
+				}
+			},
+		)
+	}
+}
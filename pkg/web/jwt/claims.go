@@ -31,6 +31,70 @@ func NumericDateFromJsonNumber(n json.Number) NumericDate {
 	return NumericDate(v)
 }
 
+// Audience is the audience claim (aud). RFC 7519 allows the audience to
+// be either a single string or an array of strings, so Audience can be
+// unmarshaled from either form. When it is marshaled, a single audience
+// is written as a string and multiple audiences as an array.
+type Audience []string
+
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+func (a *Audience) UnmarshalJSON(b []byte) error {
+	var v any
+	err := json.Unmarshal(b, &v)
+	if err != nil {
+		return err
+	}
+	aud, err := audienceFromAny(v)
+	if err != nil {
+		return err
+	}
+	*a = aud
+	return nil
+}
+
+// Contains reports whether the audience contains the provided audience.
+func (a Audience) Contains(aud string) bool {
+	for _, s := range a {
+		if s == aud {
+			return true
+		}
+	}
+	return false
+}
+
+func audienceFromAny(v any) (Audience, error) {
+	switch t := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if t == "" {
+			return nil, nil
+		}
+		return Audience{t}, nil
+	case Audience:
+		return t, nil
+	case []string:
+		return t, nil
+	case []any:
+		aud := make(Audience, 0, len(t))
+		for _, e := range t {
+			s, ok := e.(string)
+			if !ok {
+				return nil, ErrTokenInvalidAudience
+			}
+			aud = append(aud, s)
+		}
+		return aud, nil
+	}
+	return nil, ErrTokenInvalidAudience
+}
+
 // SkipValidation can be  used as a return value from ValidateClaimFunc to
 // indicate that the claim in the call is to be skipped. It is not returned
 // as an error by any function.
@@ -51,8 +115,8 @@ type ClaimsSet interface {
 	// GetSUB is the subject of the JWT.
 	GetSUB() (string, error)
 
-	// GetAUD is the audience (Recipient for which the JWT is intended.)
-	GetAUD() (string, error)
+	// GetAUD is the audience (Recipients for which the JWT is intended.)
+	GetAUD() (Audience, error)
 
 	// GetEXP is the time after which the JWT expires.
 	GetEXP() (NumericDate, error)
@@ -78,11 +142,15 @@ type CustomClaimsSet interface {
 }
 
 // RegisteredClaims is the default set of registered claims. It can be used
-// in addition to custom claims by embedding the registered claims in
+// in addition to custom claims by embedding the registered claims in a
+// custom claims struct. The getters are safe to call on a nil pointer, so
+// the registered claims can be embedded as a pointer. Claims that are left
+// as their zero value are treated as missing, just like they are left out
+// when marshaled.
 type RegisteredClaims struct {
 	Issuer         string      `json:"iss,omitempty"`
 	Subject        string      `json:"sub,omitempty"`
-	Audience       string      `json:"aud,omitempty"`
+	Audience       Audience    `json:"aud,omitempty"`
 	ExpirationTime NumericDate `json:"exp,omitempty"`
 	NotBeforeTime  NumericDate `json:"nbf,omitempty"`
 	IssuedAtTime   NumericDate `json:"iat,omitempty"`
@@ -90,30 +158,51 @@ type RegisteredClaims struct {
 }
 
 func (r *RegisteredClaims) GetISS() (string, error) {
+	if r == nil || r.Issuer == "" {
+		return "", ErrTokenClaimNotFound
+	}
 	return r.Issuer, nil
 }
 
 func (r *RegisteredClaims) GetSUB() (string, error) {
+	if r == nil || r.Subject == "" {
+		return "", ErrTokenClaimNotFound
+	}
 	return r.Subject, nil
 }
 
-func (r *RegisteredClaims) GetAUD() (string, error) {
+func (r *RegisteredClaims) GetAUD() (Audience, error) {
+	if r == nil || r.Audience == nil {
+		return nil, ErrTokenClaimNotFound
+	}
 	return r.Audience, nil
 }
 
 func (r *RegisteredClaims) GetEXP() (NumericDate, error) {
+	if r == nil || r.ExpirationTime == 0 {
+		return -1, ErrTokenClaimNotFound
+	}
 	return r.ExpirationTime, nil
 }
 
 func (r *RegisteredClaims) GetNBF() (NumericDate, error) {
+	if r == nil || r.NotBeforeTime == 0 {
+		return -1, ErrTokenClaimNotFound
+	}
 	return r.NotBeforeTime, nil
 }
 
 func (r *RegisteredClaims) GetIAT() (NumericDate, error) {
+	if r == nil || r.IssuedAtTime == 0 {
+		return -1, ErrTokenClaimNotFound
+	}
 	return r.IssuedAtTime, nil
 }
 
 func (r *RegisteredClaims) GetJTI() (string, error) {
+	if r == nil || r.ID == "" {
+		return "", ErrTokenClaimNotFound
+	}
 	return r.ID, nil
 }
 
@@ -127,28 +216,33 @@ func (m MapClaims) getClaim(k string) (any, error) {
 	return v, nil
 }
 
-func (m MapClaims) GetISS() (string, error) {
-	v, err := m.getClaim("iss")
+// getString returns the claim, or ErrTokenMalformed if it is not a string.
+func (m MapClaims) getString(k string) (string, error) {
+	v, err := m.getClaim(k)
 	if err != nil {
 		return "", err
 	}
-	return v.(string), nil
+	s, ok := v.(string)
+	if !ok {
+		return "", ErrTokenMalformed
+	}
+	return s, nil
+}
+
+func (m MapClaims) GetISS() (string, error) {
+	return m.getString("iss")
 }
 
 func (m MapClaims) GetSUB() (string, error) {
-	v, err := m.getClaim("sub")
-	if err != nil {
-		return "", err
-	}
-	return v.(string), nil
+	return m.getString("sub")
 }
 
-func (m MapClaims) GetAUD() (string, error) {
+func (m MapClaims) GetAUD() (Audience, error) {
 	v, err := m.getClaim("aud")
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return v.(string), nil
+	return audienceFromAny(v)
 }
 
 func (m MapClaims) GetEXP() (NumericDate, error) {
@@ -176,11 +270,7 @@ func (m MapClaims) GetIAT() (NumericDate, error) {
 }
 
 func (m MapClaims) GetJTI() (string, error) {
-	v, err := m.getClaim("jti")
-	if err != nil {
-		return "", err
-	}
-	return v.(string), nil
+	return m.getString("jti")
 }
 
 func (m MapClaims) getNumericDate(k string) (NumericDate, error) {
@@ -196,6 +286,17 @@ func (m MapClaims) getNumericDate(k string) (NumericDate, error) {
 	case json.Number:
 		n = NumericDateFromJsonNumber(t)
 		break
+	case NumericDate:
+		n = t
+		break
+	case int64:
+		n = NumericDate(t)
+		break
+	case int:
+		n = NumericDate(t)
+		break
+	default:
+		return -1, ErrTokenMalformed
 	}
 	return n, nil
 }
@@ -1,7 +1,9 @@
 package jwt
 
 import (
+	"encoding/json"
 	"errors"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -17,7 +19,7 @@ func TestRegisteredClaims(t *testing.T) {
 	claims1 := &RegisteredClaims{
 		Issuer:         "me",
 		Subject:        "this is a test",
-		Audience:       "anyone",
+		Audience:       Audience{"anyone"},
 		ExpirationTime: NumericDateNow().Add(12 * time.Hour),
 		NotBeforeTime:  NumericDateNow(),
 		IssuedAtTime:   NumericDateNow(),
@@ -30,7 +32,7 @@ func TestRegisteredClaims(t *testing.T) {
 	claims2 := &RegisteredClaims{
 		Issuer:         "you",
 		Subject:        "this is not a test",
-		Audience:       "nobody",
+		Audience:       Audience{"nobody"},
 		ExpirationTime: NumericDateNow().Add(12 * time.Hour),
 		NotBeforeTime:  NumericDateNow(),
 		IssuedAtTime:   NumericDateNow().Add(time.Hour - 1),
@@ -67,7 +69,7 @@ func TestRegisteredCustomClaims(t *testing.T) {
 		RegisteredClaims: &RegisteredClaims{
 			Issuer:         "me",
 			Subject:        "this is a test",
-			Audience:       "anyone",
+			Audience:       Audience{"anyone"},
 			ExpirationTime: NumericDateNow().Add(12 * time.Hour),
 			NotBeforeTime:  NumericDateNow(),
 			IssuedAtTime:   NumericDateNow(),
@@ -83,7 +85,7 @@ func TestRegisteredCustomClaims(t *testing.T) {
 		RegisteredClaims: &RegisteredClaims{
 			Issuer:         "me",
 			Subject:        "this is a test",
-			Audience:       "anyone",
+			Audience:       Audience{"anyone"},
 			ExpirationTime: NumericDateNow().Add(12 * time.Hour),
 			NotBeforeTime:  NumericDateNow(),
 			IssuedAtTime:   NumericDateNow(),
@@ -131,3 +133,72 @@ func TestMapClaims(t *testing.T) {
 		t.Errorf("validate claims should have failed and it didn't\n")
 	}
 }
+
+func TestAudience_JSON(t *testing.T) {
+	tests := []struct {
+		data string
+		aud  Audience
+	}{
+		{`{"aud":"anyone"}`, Audience{"anyone"}},
+		{`{"aud":["anyone","someone"]}`, Audience{"anyone", "someone"}},
+		{`{"aud":[]}`, Audience{}},
+		{`{}`, nil},
+	}
+	for _, tt := range tests {
+		var claims RegisteredClaims
+		err := json.Unmarshal([]byte(tt.data), &claims)
+		if err != nil {
+			t.Fatalf("error unmarshaling %s: %v", tt.data, err)
+		}
+		if !reflect.DeepEqual(tt.aud, claims.Audience) {
+			t.Errorf("wanted=%v, got=%v\n", tt.aud, claims.Audience)
+		}
+		b, err := json.Marshal(&claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tt.aud) > 0 {
+			assert(t, tt.data, string(b))
+		}
+	}
+
+	var claims RegisteredClaims
+	err := json.Unmarshal([]byte(`{"aud":42}`), &claims)
+	if err == nil {
+		t.Errorf("expected an error unmarshaling a numeric audience")
+	}
+}
+
+func TestValidator_Audience(t *testing.T) {
+	claims := MapClaims{
+		"aud": []any{"orders", "billing"},
+		"exp": NumericDateNow().Add(time.Hour),
+	}
+	tests := []struct {
+		validator *Validator
+		valid     bool
+	}{
+		{&Validator{ExpectedAUD: "orders"}, true},
+		{&Validator{ExpectedAUD: "shipping"}, false},
+		{&Validator{ExpectedAUDs: []string{"shipping", "billing"}}, true},
+		{&Validator{ExpectedAUDs: []string{"shipping", "billing"}, RequireAllAUDs: true}, false},
+		{&Validator{ExpectedAUDs: []string{"orders", "billing"}, RequireAllAUDs: true}, true},
+		{&Validator{ExpectedAUD: "orders", ExpectedAUDs: []string{"shipping"}, RequireAllAUDs: true}, false},
+	}
+	for i, tt := range tests {
+		err := tt.validator.ValidateClaims(claims)
+		if tt.valid && err != nil {
+			t.Errorf("[%d] error validating claims: %v", i, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrTokenInvalidAudience) {
+			t.Errorf("[%d] expected %v, got %v", i, ErrTokenInvalidAudience, err)
+		}
+	}
+
+	// A malformed audience should not panic, and should fail validation
+	claims["aud"] = 42
+	err := (&Validator{ExpectedAUD: "orders"}).ValidateClaims(claims)
+	if !errors.Is(err, ErrTokenInvalidAudience) {
+		t.Errorf("expected %v, got %v", ErrTokenInvalidAudience, err)
+	}
+}
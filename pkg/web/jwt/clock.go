@@ -0,0 +1,65 @@
+package jwt
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the source of the current time used by the Validator, the
+// TokenManager and everything else that deals with time, such as the
+// KeyRing and the stores. It can be replaced to test time based logic, such
+// as token expiry, deterministically.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the Clock that is used when none is provided. It returns
+// the current system time.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// clockNow returns the current time according to the clock, or according
+// to SystemClock if the clock is nil.
+func clockNow(c Clock) time.Time {
+	if c == nil {
+		return SystemClock.Now()
+	}
+	return c.Now()
+}
+
+// FakeClock is a Clock that only moves when it is told to. It is safe for
+// concurrent use.
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock returns a FakeClock that is set to the time supplied.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set sets the clock to the time supplied.
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Advance moves the clock forward by the duration supplied.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
@@ -0,0 +1,245 @@
+package jwt
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestValidator_Clock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	v := &Validator{
+		Clock:     clock,
+		Margin:    time.Minute,
+		ExpLeeway: 30 * time.Second,
+	}
+	claims := MapClaims{
+		"iat": NumericDate(1700000000),
+		"nbf": NumericDate(1700000000 + 60),
+		"exp": NumericDate(1700000000 + 3600),
+	}
+
+	tests := []struct {
+		name    string
+		advance time.Duration
+		want    error
+	}{
+		// nbf uses the margin, as it has no leeway of its own
+		{"before nbf margin", -time.Second, ErrTokenNotValidYet},
+		{"within nbf margin", time.Second, nil},
+		{"before exp", time.Hour, nil},
+		// exp uses its own leeway instead of the margin
+		{"within exp leeway", 29 * time.Second, nil},
+		{"past exp leeway", 2 * time.Second, ErrTokenExpired},
+	}
+	for _, tt := range tests {
+		clock.Advance(tt.advance)
+		err := v.ValidateClaims(claims)
+		if tt.want == nil && err != nil {
+			t.Errorf("[%s] unexpected error: %v", tt.name, err)
+		}
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("[%s] expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestValidator_MaxAge(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	v := &Validator{
+		Clock:  clock,
+		MaxAge: time.Hour,
+	}
+	claims := &RegisteredClaims{
+		ExpirationTime: NumericDate(1700000000).Add(24 * time.Hour),
+		IssuedAtTime:   NumericDate(1700000000),
+	}
+	err := v.ValidateClaims(claims)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	clock.Advance(time.Hour + time.Second)
+	err = v.ValidateClaims(claims)
+	if !errors.Is(err, ErrTokenTooOld) {
+		t.Errorf("expected %v, got %v", ErrTokenTooOld, err)
+	}
+
+	// The iat claim is required when a max age is set
+	claims.IssuedAtTime = 0
+	err = v.ValidateClaims(claims)
+	var verr *ValidationError
+	if !errors.As(err, &verr) || !verr.Has(CheckMaxAge) {
+		t.Fatalf("expected a max age failure, got %v", err)
+	}
+	assert(t, "missing iat claim", verr.Failure(CheckMaxAge).Detail)
+}
+
+func TestValidator_RequiredClaims(t *testing.T) {
+	v := &Validator{
+		RequiredClaims: []string{"sub", "jti", "scope"},
+	}
+	exp := NumericDateNow().Add(time.Hour)
+
+	err := v.ValidateClaims(MapClaims{"exp": exp, "sub": "jon doe", "jti": "1", "scope": "read"})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err = v.ValidateClaims(MapClaims{"exp": exp, "sub": "jon doe"})
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a *ValidationError, got %v", err)
+	}
+	assert(t, CheckRequiredClaims, verr.Checks)
+	assert(t, "missing jti, scope", verr.Failure(CheckRequiredClaims).Detail)
+
+	// Custom claims sets are checked as well
+	v.RequiredClaims = []string{"sub", "SecretField"}
+	err = v.ValidateClaims(
+		&MyCustomClaims{
+			SecretField:      "top secret",
+			RegisteredClaims: &RegisteredClaims{ExpirationTime: exp},
+		},
+	)
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a *ValidationError, got %v", err)
+	}
+	assert(t, "missing sub", verr.Failure(CheckRequiredClaims).Detail)
+
+	// A missing exp is reported as such, instead of as expired at epoch
+	v.RequiredClaims = nil
+	err = v.ValidateClaims(&RegisteredClaims{Subject: "jon doe"})
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a *ValidationError, got %v", err)
+	}
+	assert(t, "missing exp claim", verr.Failure(CheckExpiresAt).Detail)
+}
+
+func TestTokenManager_Clock(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	tm := newRefreshTestManager(t)
+	tm.Clock = clock
+
+	pair, err := tm.IssueTokenPair("jon doe", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The access token expires according to the fake clock
+	clock.Advance(tm.AccessTokenTTL + tm.Margin + time.Second)
+	_, err = tm.ValidateToken(RawToken(pair.AccessToken))
+	if !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("expected %v, got %v", ErrTokenExpired, err)
+	}
+	// And so does the refresh token
+	clock.Advance(tm.RefreshTokenTTL)
+	_, err = tm.RefreshTokenPair(pair.RefreshToken)
+	if err != ErrRefreshTokenExpired {
+		t.Errorf("expected %v, got %v", ErrRefreshTokenExpired, err)
+	}
+}
+
+func TestTokenManager_ClockKeys(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	tm := NewTokenManager(ES256, ES256.GenerateKeyPair())
+	tm.Clock = clock
+
+	// Tokens issued without claims expire an hour from the fake now
+	raw, err := tm.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err := tm.ValidateToken(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp, _ := tok.Payload.GetEXP()
+	assert(t, NumericDate(1700000000+3600), exp)
+
+	// The grace window of a retired key is measured using the fake clock
+	raw, err = tm.GenerateToken(MapClaims{"exp": NumericDate(1700000000).Add(24 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tm.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(DefaultKeyGrace - time.Second)
+	if _, err = tm.ValidateToken(raw); err != nil {
+		t.Errorf("expected the retired key to be within its grace window, got %v", err)
+	}
+	clock.Advance(time.Second)
+	if _, err = tm.ValidateToken(raw); !errors.Is(err, ErrKeyExpired) {
+		t.Errorf("expected %v, got %v", ErrKeyExpired, err)
+	}
+}
+
+func TestRevocationStore_Clock(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	mem := NewMemoryRevocationStore(time.Minute)
+	defer mem.Close()
+	mem.Clock = clock
+	file, err := OpenFileRevocationStore(filepath.Join(t.TempDir(), "revoked"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	file.Clock = clock
+
+	for _, store := range []RevocationStore{mem, file} {
+		if err = store.Revoke("1", clock.Now().Add(time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clock.Advance(time.Minute - time.Second)
+	for _, store := range []RevocationStore{mem, file} {
+		if revoked, _ := store.IsRevoked("1"); !revoked {
+			t.Errorf("[%T] expected the token to be revoked", store)
+		}
+	}
+	clock.Advance(time.Second)
+	for _, store := range []RevocationStore{mem, file} {
+		if revoked, _ := store.IsRevoked("1"); revoked {
+			t.Errorf("[%T] expected the revocation to have expired", store)
+		}
+	}
+}
+
+func TestTokenManager_RevokeExpLeeway(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	tm := newRefreshTestManager(t)
+	tm.Clock = clock
+	tm.RevocationStore.(*MemoryRevocationStore).Clock = clock
+	tm.RefreshTokenStore.(*MemoryRefreshTokenStore).Clock = clock
+	tm.ExpLeeway = 10 * time.Minute
+
+	// One access token is revoked directly, the other one along with its
+	// family when the refresh token is replayed
+	p1, err := tm.IssueTokenPair("jon doe", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tm.Revoke(RawToken(p1.AccessToken)); err != nil {
+		t.Fatal(err)
+	}
+	p2, err := tm.IssueTokenPair("jon doe", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p3, err := tm.RefreshTokenPair(p2.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tm.RefreshTokenPair(p2.RefreshToken); err != ErrRefreshTokenReused {
+		t.Fatalf("expected %v, got %v", ErrRefreshTokenReused, err)
+	}
+
+	// The tokens stay revoked for as long as the exp leeway accepts them,
+	// which is well past the margin
+	clock.Advance(tm.AccessTokenTTL + 5*time.Minute)
+	for _, raw := range []string{p1.AccessToken, p3.AccessToken} {
+		_, err = tm.ValidateToken(RawToken(raw))
+		if !errors.Is(err, ErrTokenRevoked) {
+			t.Errorf("expected %v, got %v", ErrTokenRevoked, err)
+		}
+	}
+}
@@ -0,0 +1,453 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DPoPHeader is the request header carrying a DPoP proof (RFC 9449).
+const DPoPHeader = "DPoP"
+
+// dpopType is the typ header of a DPoP proof.
+const dpopType = "dpop+jwt"
+
+// DPoPClaims are the claims of a DPoP proof, as described in RFC 9449,
+// section 4.2.
+type DPoPClaims struct {
+	Jti string      `json:"jti"`
+	Htm string      `json:"htm"`
+	Htu string      `json:"htu"`
+	Iat NumericDate `json:"iat"`
+
+	// Ath is the hash of the access token the proof is presented with,
+	// and it is empty if there is none (e.g. when requesting a token).
+	Ath string `json:"ath,omitempty"`
+
+	// Nonce is the nonce provided by the server, if any.
+	Nonce string `json:"nonce,omitempty"`
+}
+
+// DPoPSigner creates DPoP proofs on the client side. Each proof is signed
+// using the private key of the client, and carries the public key in its
+// jwk header, so the server can bind tokens to the key.
+type DPoPSigner struct {
+	method SigningMethod
+	key    crypto.PrivateKey
+	jwk    *JWK
+	jkt    string
+
+	// Clock is the source of the iat claim; if it is left as nil,
+	// SystemClock will be used.
+	Clock Clock
+}
+
+// NewDPoPSigner returns a DPoPSigner that signs proofs with the key pair
+// using the provided signing method. The method must be asymmetric, since
+// the public key is handed to the server.
+func NewDPoPSigner(method SigningMethod, keys *KeyPair) (*DPoPSigner, error) {
+	jwk, err := keys.PublicJWK()
+	if err != nil {
+		return nil, err
+	}
+	if jwk.Kty == KeyTypeOct {
+		return nil, ErrInvalidKeyType
+	}
+	if err = checkKeyType(method, keys.PublicKey); err != nil {
+		return nil, err
+	}
+	jkt, err := dpopThumbprint(jwk)
+	if err != nil {
+		return nil, err
+	}
+	return &DPoPSigner{
+		method: method,
+		key:    keys.PrivateKey,
+		jwk:    jwk,
+		jkt:    jkt,
+	}, nil
+}
+
+// Thumbprint returns the base64url encoded SHA-256 thumbprint of the public
+// key (the jkt), which the server uses to bind tokens to the key.
+func (s *DPoPSigner) Thumbprint() string {
+	return s.jkt
+}
+
+// Proof returns a new DPoP proof for a request with the HTTP method htm to
+// the URI htu. If the proof is sent along with an access token, the token
+// must be provided so its hash is included in the proof.
+func (s *DPoPSigner) Proof(htm, htu, accessToken string) (RawToken, error) {
+	jti, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+	now := clockNow(s.Clock)
+	claims := MapClaims{
+		"jti": jti,
+		"htm": htm,
+		"htu": htu,
+		"iat": now.Unix(),
+	}
+	if accessToken != "" {
+		claims["ath"] = accessTokenHash(accessToken)
+	}
+	return NewTokenWithHeader(s.method, TokenHeader{Typ: dpopType, Jwk: s.jwk}, claims, s.key)
+}
+
+// Authorize adds a new DPoP proof to the request, along with the access
+// token using the DPoP authorization scheme. The access token may be empty,
+// in which case only the proof is added.
+func (s *DPoPSigner) Authorize(r *http.Request, accessToken string) error {
+	proof, err := s.Proof(r.Method, dpopURI(r.URL), accessToken)
+	if err != nil {
+		return err
+	}
+	r.Header.Set(DPoPHeader, string(proof))
+	if accessToken != "" {
+		r.Header.Set("Authorization", "DPoP "+accessToken)
+	}
+	return nil
+}
+
+// DPoPProof is a verified DPoP proof.
+type DPoPProof struct {
+	RawToken
+	Header TokenHeader
+	Claims DPoPClaims
+
+	// JKT is the thumbprint of the public key the proof was signed
+	// with, which must match the cnf.jkt claim of the access token.
+	JKT string
+}
+
+// DPoPVerifier verifies the DPoP proofs of incoming requests, as described
+// in RFC 9449, section 4.3. The jti of every accepted proof is remembered
+// for as long as the proof could be accepted, so a proof can only be used
+// once. A DPoPVerifier should be created using NewDPoPVerifier, and closed
+// once it is no longer needed. One created as a struct literal sets up its
+// replay cache on first use, which is cleaned up every minute.
+type DPoPVerifier struct {
+
+	// Algorithms holds the signing algorithms accepted for proofs. It
+	// defaults to ES256, ES384, ES512, RS256, PS256 and EdDSA.
+	Algorithms []string
+
+	// MaxAge is how long after it was issued a proof is accepted. It
+	// defaults to one minute.
+	MaxAge time.Duration
+
+	// Margin is the allowed clock skew between the client and the
+	// server. It defaults to five seconds.
+	Margin time.Duration
+
+	// Clock is the source of the current time; if it is left as nil,
+	// SystemClock will be used.
+	Clock Clock
+
+	// RequestURI returns the URI of the request that the htu claim is
+	// compared to. It defaults to the scheme, host and path the request
+	// was received on, which will not be right behind a proxy that
+	// rewrites any of them.
+	RequestURI func(r *http.Request) string
+
+	once sync.Once
+	seen *timeoutMap[string, struct{}]
+}
+
+// NewDPoPVerifier initializes and returns a new DPoPVerifier that cleans up
+// the jti values of expired proofs at the interval supplied.
+func NewDPoPVerifier(interval time.Duration) *DPoPVerifier {
+	v := &DPoPVerifier{}
+	v.seen = newTimeoutMap[string, struct{}](interval, v.now)
+	return v
+}
+
+func (v *DPoPVerifier) now() time.Time {
+	return clockNow(v.Clock)
+}
+
+// Close stops the background cleaner of the verifier.
+func (v *DPoPVerifier) Close() error {
+	v.replayCache().stop()
+	return nil
+}
+
+// replayCache returns the jti values of the accepted proofs, creating the
+// cache if the verifier was not created using NewDPoPVerifier.
+func (v *DPoPVerifier) replayCache() *timeoutMap[string, struct{}] {
+	v.once.Do(
+		func() {
+			if v.seen == nil {
+				v.seen = newTimeoutMap[string, struct{}](time.Minute, v.now)
+			}
+		},
+	)
+	return v.seen
+}
+
+// defaultDPoPAlgorithms are the algorithms accepted for proofs when none
+// are configured.
+var defaultDPoPAlgorithms = []string{"ES256", "ES384", "ES512", "RS256", "PS256", "EdDSA"}
+
+func (v *DPoPVerifier) algorithms() []string {
+	if len(v.Algorithms) == 0 {
+		return defaultDPoPAlgorithms
+	}
+	return v.Algorithms
+}
+
+// Verify verifies the proof for a request with the HTTP method htm to the
+// URI htu. If the proof was presented along with an access token, the
+// token must be provided so its hash can be checked. The query and
+// fragment of htu are ignored.
+func (v *DPoPVerifier) Verify(proof RawToken, htm, htu, accessToken string) (*DPoPProof, error) {
+	p := &DPoPProof{}
+	token, err := parseRawToken(proof, &p.Claims)
+	if err != nil {
+		return nil, errors.Join(ErrDPoPProofInvalid, err)
+	}
+	p.RawToken, p.Header = token.RawToken, token.Header
+
+	// The header must identify a proof signed using an asymmetric key,
+	// and carry the public key but nothing else.
+	if !strings.EqualFold(p.Header.Typ, dpopType) {
+		return nil, fmt.Errorf("%w: typ must be %q", ErrDPoPProofInvalid, dpopType)
+	}
+	if !containsString(v.algorithms(), p.Header.Alg) {
+		return nil, fmt.Errorf("%w: alg %q is not allowed", ErrDPoPProofInvalid, p.Header.Alg)
+	}
+	jwk := p.Header.Jwk
+	if jwk == nil || jwk.Kty == KeyTypeOct || jwk.IsPrivate() {
+		return nil, fmt.Errorf("%w: jwk must hold a public key", ErrDPoPProofInvalid)
+	}
+	key, err := jwk.PublicKey()
+	if err != nil {
+		return nil, errors.Join(ErrDPoPProofInvalid, err)
+	}
+	if err = checkKeyType(token.Method, key); err != nil {
+		return nil, errors.Join(ErrDPoPProofInvalid, err)
+	}
+	err = token.Method.Verify(proof.SigningSection(), token.Signature, key)
+	if err != nil {
+		return nil, errors.Join(ErrDPoPProofInvalid, ErrTokenSignatureInvalid, err)
+	}
+
+	// The proof must be for this request, and for this access token
+	c := &p.Claims
+	if c.Jti == "" {
+		return nil, fmt.Errorf("%w: jti is missing", ErrDPoPProofInvalid)
+	}
+	if c.Htm != htm {
+		return nil, fmt.Errorf("%w: htm does not match", ErrDPoPProofInvalid)
+	}
+	if !dpopURIEqual(c.Htu, htu) {
+		return nil, fmt.Errorf("%w: htu does not match", ErrDPoPProofInvalid)
+	}
+	if accessToken != "" {
+		ath := accessTokenHash(accessToken)
+		if subtle.ConstantTimeCompare([]byte(c.Ath), []byte(ath)) != 1 {
+			return nil, fmt.Errorf("%w: ath does not match", ErrDPoPProofInvalid)
+		}
+	}
+
+	// The proof must be fresh, and it must not have been seen before
+	maxAge, margin := v.MaxAge, v.Margin
+	if maxAge == 0 {
+		maxAge = time.Minute
+	}
+	if margin == 0 {
+		margin = 5 * time.Second
+	}
+	now := v.now()
+	iat := c.Iat.Time()
+	if c.Iat == 0 || now.Add(margin).Before(iat) || now.Sub(iat) > maxAge+margin {
+		return nil, fmt.Errorf("%w: iat is outside the acceptable window", ErrDPoPProofInvalid)
+	}
+	if !v.replayCache().putIfAbsent(c.Jti, struct{}{}, iat.Add(maxAge+margin)) {
+		return nil, ErrDPoPProofReplayed
+	}
+
+	p.JKT, err = dpopThumbprint(jwk)
+	if err != nil {
+		return nil, errors.Join(ErrDPoPProofInvalid, err)
+	}
+	return p, nil
+}
+
+// VerifyRequest verifies the DPoP proof carried by the request. The
+// request must carry exactly one proof.
+func (v *DPoPVerifier) VerifyRequest(r *http.Request, accessToken string) (*DPoPProof, error) {
+	proofs := r.Header.Values(DPoPHeader)
+	if len(proofs) == 0 {
+		return nil, ErrDPoPProofMissing
+	}
+	if len(proofs) > 1 {
+		return nil, fmt.Errorf("%w: more than one proof", ErrDPoPProofInvalid)
+	}
+	uri := dpopRequestURI(r)
+	if v.RequestURI != nil {
+		uri = v.RequestURI(r)
+	}
+	return v.Verify(RawToken(proofs[0]), r.Method, uri, accessToken)
+}
+
+// DPoPError is the error returned for requests that failed DPoP, which
+// makes WriteAuthError use the DPoP authentication scheme.
+type DPoPError struct {
+
+	// Algs are the algorithms accepted for proofs.
+	Algs []string
+
+	Err error
+}
+
+func (e *DPoPError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DPoPError) Unwrap() error {
+	return e.Err
+}
+
+// DPoPConfirmation returns the cnf claim that binds a token to the DPoP key
+// with the provided thumbprint (RFC 9449, section 6).
+func DPoPConfirmation(jkt string) map[string]any {
+	return map[string]any{"jkt": jkt}
+}
+
+// dpopBinding returns the thumbprint the token with the claims is bound
+// to, or an empty string if it is not bound to a DPoP key.
+func dpopBinding(claims MapClaims) string {
+	v, _ := lookupClaim(claims, "cnf.jkt")
+	jkt, _ := v.(string)
+	return jkt
+}
+
+// IssueDPoPTokenPair works just like IssueTokenPair, but the access tokens
+// issued to the family are bound to the DPoP key with the thumbprint jkt
+// (usually DPoPProof.JKT of the token request). The refresh tokens are
+// bound to the same key, so they can only be used with RefreshDPoPTokenPair
+// (RFC 9449, section 5), and the access tokens issued when they are used
+// stay bound to the key as well.
+func (m *TokenManager) IssueDPoPTokenPair(sub, jkt string, claims MapClaims) (*TokenPair, error) {
+	if jkt == "" {
+		return nil, ErrDPoPProofMissing
+	}
+	bound := make(MapClaims, len(claims)+1)
+	for k, v := range claims {
+		bound[k] = v
+	}
+	bound["cnf"] = DPoPConfirmation(jkt)
+	return m.issueTokenFamily(sub, jkt, bound)
+}
+
+// RefreshDPoPTokenPair works just like RefreshTokenPair, for the refresh
+// tokens issued by IssueDPoPTokenPair. The token request must carry a DPoP
+// proof signed using the key the family is bound to, otherwise the refresh
+// token is rejected and the family is left untouched. Families that are
+// not bound to a DPoP key are rejected with ErrDPoPBindingMismatch.
+func (m *TokenManager) RefreshDPoPTokenPair(v *DPoPVerifier, r *http.Request, refreshToken string) (*TokenPair, error) {
+	proof, err := v.VerifyRequest(r, "")
+	if err != nil {
+		return nil, err
+	}
+	return m.refreshTokenPair(refreshToken, proof.JKT)
+}
+
+// validateDPoPRequest validates the DPoP bound access token and the proof
+// carried by the request, and checks that the token is bound to the key
+// the proof was signed with.
+func (m *TokenManager) validateDPoPRequest(v *DPoPVerifier, r *http.Request, raw RawToken) (*Token, error) {
+	proof, err := v.VerifyRequest(r, string(raw))
+	if err != nil {
+		return nil, err
+	}
+	token, err := m.ValidateToken(raw)
+	if err != nil {
+		return nil, err
+	}
+	jkt := dpopBinding(token.Payload)
+	if jkt == "" || subtle.ConstantTimeCompare([]byte(jkt), []byte(proof.JKT)) != 1 {
+		return nil, ErrDPoPBindingMismatch
+	}
+	return token, nil
+}
+
+// accessTokenHash returns the ath claim for the access token.
+func accessTokenHash(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// dpopThumbprint returns the base64url encoded SHA-256 thumbprint of the
+// JWK.
+func dpopThumbprint(jwk *JWK) (string, error) {
+	b, err := jwk.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// dpopRequestURI returns the URI the request was received on, without the
+// query and fragment.
+func dpopRequestURI(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.EscapedPath()
+}
+
+// dpopURI returns the URI without the query and fragment.
+func dpopURI(u *url.URL) string {
+	v := *u
+	v.RawQuery, v.ForceQuery, v.Fragment, v.RawFragment = "", false, "", ""
+	return v.String()
+}
+
+// dpopURIEqual compares the htu claim to the URI of the request, after
+// normalising both (RFC 9449, section 4.3). The query and fragment are
+// ignored.
+func dpopURIEqual(htu, uri string) bool {
+	a, err := normaliseDPoPURI(htu)
+	if err != nil {
+		return false
+	}
+	b, err := normaliseDPoPURI(uri)
+	if err != nil {
+		return false
+	}
+	return a == b
+}
+
+func normaliseDPoPURI(s string) (string, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", err
+	}
+	if !u.IsAbs() || u.Host == "" {
+		return "", fmt.Errorf("%q is not an absolute URI", s)
+	}
+	scheme, host := strings.ToLower(u.Scheme), strings.ToLower(u.Host)
+	if scheme == "https" {
+		host = strings.TrimSuffix(host, ":443")
+	}
+	if scheme == "http" {
+		host = strings.TrimSuffix(host, ":80")
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	return scheme + "://" + host + path, nil
+}
@@ -0,0 +1,293 @@
+package jwt
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestDPoPVerifier(t *testing.T) *DPoPVerifier {
+	t.Helper()
+	v := NewDPoPVerifier(time.Minute)
+	t.Cleanup(func() { v.Close() })
+	return v
+}
+
+func TestDPoPSigner(t *testing.T) {
+	for _, method := range []SigningMethod{ES256, RS256, PS256, EdDSA} {
+		t.Run(
+			method.Name(), func(t *testing.T) {
+				signer, err := NewDPoPSigner(method, method.GenerateKeyPair())
+				if err != nil {
+					t.Fatal(err)
+				}
+				proof, err := signer.Proof("POST", "https://server.example.com/token", "")
+				if err != nil {
+					t.Fatal(err)
+				}
+				hdr := proof.Header()
+				assert(t, "dpop+jwt", hdr.Typ)
+				if hdr.Jwk == nil || hdr.Jwk.IsPrivate() {
+					t.Fatalf("expected a public jwk header, got %+v", hdr.Jwk)
+				}
+
+				v := newTestDPoPVerifier(t)
+				p, err := v.Verify(proof, "POST", "https://server.example.com/token?x=1", "")
+				if err != nil {
+					t.Fatalf("error verifying proof: %v", err)
+				}
+				assert(t, signer.Thumbprint(), p.JKT)
+				assert(t, "POST", p.Claims.Htm)
+			},
+		)
+	}
+	if _, err := NewDPoPSigner(HS256, HS256.GenerateKeyPair()); err == nil {
+		t.Errorf("expected symmetric keys to be rejected")
+	}
+}
+
+func TestDPoPVerifier_Verify(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	signer, err := NewDPoPSigner(ES256, ES256.GenerateKeyPair())
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer.Clock = clock
+	const htu = "https://resource.example.org/protected"
+	proof := func(htm, htu, at string) RawToken {
+		raw, err := signer.Proof(htm, htu, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+	keys := ES256.GenerateKeyPair()
+	jwk, _ := keys.PublicJWK()
+	private, _ := keys.JWK()
+	forged := func(hdr TokenHeader, claims MapClaims) RawToken {
+		claims["jti"], claims["htm"], claims["htu"] = "forged", "GET", htu
+		claims["iat"] = clock.Now().Unix()
+		raw, err := NewTokenWithHeader(ES256, hdr, claims, keys.PrivateKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+
+	tests := []struct {
+		name  string
+		proof RawToken
+		htm   string
+		htu   string
+		at    string
+		err   error
+	}{
+		{"valid", proof("GET", htu, "token"), "GET", htu, "token", nil},
+		{"default port", proof("GET", "https://Resource.example.org:443/protected", ""), "GET", htu, "", nil},
+		{"query", proof("GET", htu+"?a=b#c", ""), "GET", htu + "?d=e", "", nil},
+		{"htm", proof("GET", htu, ""), "POST", htu, "", ErrDPoPProofInvalid},
+		{"htu", proof("GET", htu, ""), "GET", htu + "/other", "", ErrDPoPProofInvalid},
+		{"relative htu", proof("GET", "/protected", ""), "GET", htu, "", ErrDPoPProofInvalid},
+		{"ath", proof("GET", htu, "token"), "GET", htu, "other", ErrDPoPProofInvalid},
+		{"missing ath", proof("GET", htu, ""), "GET", htu, "token", ErrDPoPProofInvalid},
+		{"typ", forged(TokenHeader{Jwk: jwk}, MapClaims{}), "GET", htu, "", ErrDPoPProofInvalid},
+		{"no jwk", forged(TokenHeader{Typ: "dpop+jwt"}, MapClaims{}), "GET", htu, "", ErrDPoPProofInvalid},
+		{"private jwk", forged(TokenHeader{Typ: "dpop+jwt", Jwk: private}, MapClaims{}), "GET", htu, "", ErrDPoPProofInvalid},
+		{"other jwk", forged(TokenHeader{Typ: "dpop+jwt", Jwk: signer.jwk}, MapClaims{}), "GET", htu, "", ErrTokenSignatureInvalid},
+		{"malformed", RawToken("a.b.c"), "GET", htu, "", ErrDPoPProofInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				v := newTestDPoPVerifier(t)
+				v.Clock = clock
+				_, err := v.Verify(tt.proof, tt.htm, tt.htu, tt.at)
+				if tt.err == nil && err != nil {
+					t.Errorf("expected the proof to be valid, got %v", err)
+				}
+				if tt.err != nil && !errors.Is(err, tt.err) {
+					t.Errorf("expected %v, got %v", tt.err, err)
+				}
+			},
+		)
+	}
+
+	// Proofs can only be used once, and only for a short while
+	v := newTestDPoPVerifier(t)
+	v.Clock = clock
+	raw := proof("GET", htu, "")
+	if _, err = v.Verify(raw, "GET", htu, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.Verify(raw, "GET", htu, ""); !errors.Is(err, ErrDPoPProofReplayed) {
+		t.Errorf("expected %v, got %v", ErrDPoPProofReplayed, err)
+	}
+	raw = proof("GET", htu, "")
+	clock.Advance(2 * time.Minute)
+	if _, err = v.Verify(raw, "GET", htu, ""); !errors.Is(err, ErrDPoPProofInvalid) {
+		t.Errorf("expected %v, got %v", ErrDPoPProofInvalid, err)
+	}
+	raw = proof("GET", htu, "")
+	clock.Advance(-time.Minute)
+	if _, err = v.Verify(raw, "GET", htu, ""); !errors.Is(err, ErrDPoPProofInvalid) {
+		t.Errorf("expected proofs from the future to be rejected, got %v", err)
+	}
+}
+
+// TestDPoPVerifier_RFC9449 verifies the proof from RFC 9449, section 4.1,
+// which was made by another implementation.
+func TestDPoPVerifier_RFC9449(t *testing.T) {
+	proof := RawToken(
+		"eyJ0eXAiOiJkcG9wK2p3dCIsImFsZyI6IkVTMjU2IiwiandrIjp7Imt0eSI6Ik" +
+			"VDIiwieCI6Imw4dEZyaHgtMzR0VjNoUklDUkRZOXpDa0RscEJoRjQyVVFVZldWQV" +
+			"dCRnMiLCJ5IjoiOVZFNGpmX09rX282NHpiVFRsY3VOSmFqSG10NnY5VERWclUwQ2" +
+			"R2R1JEQSIsImNydiI6IlAtMjU2In19.eyJqdGkiOiItQndDM0VTYzZhY2MybFRjIi" +
+			"wiaHRtIjoiUE9TVCIsImh0dSI6Imh0dHBzOi8vc2VydmVyLmV4YW1wbGUuY29tL3" +
+			"Rva2VuIiwiaWF0IjoxNTYyMjYyNjE2fQ.2-GxA6T8lP4vfrg8v-FdWP0A0zdrj8igiM" +
+			"LvqRMUvwnQg4PtFLbdLXiOSsX0x7NVY-FNyJK70nfbV37xRZT3Lg",
+	)
+	v := newTestDPoPVerifier(t)
+	v.Clock = NewFakeClock(time.Unix(1562262616, 0))
+	p, err := v.Verify(proof, "POST", "https://server.example.com/token", "")
+	if err != nil {
+		t.Fatalf("error verifying proof: %v", err)
+	}
+	assert(t, "-BwC3ESc6acc2lTc", p.Claims.Jti)
+}
+
+func TestDPoPVerifier_ZeroValue(t *testing.T) {
+	signer, err := NewDPoPSigner(ES256, ES256.GenerateKeyPair())
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := signer.Proof("GET", "https://resource.example.org/", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A verifier that was not created using NewDPoPVerifier still
+	// rejects replayed proofs
+	v := &DPoPVerifier{MaxAge: time.Minute}
+	defer v.Close()
+	if _, err = v.Verify(proof, "GET", "https://resource.example.org/", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.Verify(proof, "GET", "https://resource.example.org/", ""); !errors.Is(err, ErrDPoPProofReplayed) {
+		t.Errorf("expected %v, got %v", ErrDPoPProofReplayed, err)
+	}
+	// Closing more than once is fine
+	v.Close()
+	newTestDPoPVerifier(t).Close()
+}
+
+func TestTokenManager_DPoPMiddleware(t *testing.T) {
+	tm := newRefreshTestManager(t)
+	signer, err := NewDPoPSigner(ES256, ES256.GenerateKeyPair())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair, err := tm.IssueDPoPTokenPair("alice", signer.Thumbprint(), MapClaims{"scope": "orders:read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, "DPoP", pair.TokenType)
+	bearer, err := tm.IssueTokenPair("bob", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier := newTestDPoPVerifier(t)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	serve := func(opts *MiddlewareOptions, r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		tm.Middleware(opts)(next).ServeHTTP(w, r)
+		return w
+	}
+	newRequest := func(s *DPoPSigner, token string) *http.Request {
+		r := httptest.NewRequest("GET", "http://api.example.com/orders?page=2", nil)
+		if err := s.Authorize(r, token); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	opts := &MiddlewareOptions{DPoP: verifier}
+
+	// Tokens bound to the key of the proof are accepted, once
+	r := newRequest(signer, pair.AccessToken)
+	assert(t, http.StatusOK, serve(opts, r).Code)
+	w := serve(opts, r)
+	assert(t, http.StatusUnauthorized, w.Code)
+	assert(t, `DPoP error="invalid_dpop_proof", algs="ES256 ES384 ES512 RS256 PS256 EdDSA"`, w.Header().Get("WWW-Authenticate"))
+
+	// Proofs signed with another key are rejected
+	other, err := NewDPoPSigner(ES256, ES256.GenerateKeyPair())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = serve(opts, newRequest(other, pair.AccessToken))
+	assert(t, http.StatusUnauthorized, w.Code)
+	if !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), `DPoP error="invalid_token"`) {
+		t.Errorf("expected an invalid_token challenge, got %q", w.Header().Get("WWW-Authenticate"))
+	}
+
+	// Bound tokens are never accepted as bearer tokens, with or without
+	// DPoP enabled
+	for _, opts := range []*MiddlewareOptions{opts, nil} {
+		r = httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+		w = serve(opts, r)
+		assert(t, http.StatusUnauthorized, w.Code)
+		if !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), "DPoP ") {
+			t.Errorf("expected a DPoP challenge, got %q", w.Header().Get("WWW-Authenticate"))
+		}
+	}
+
+	// Bearer tokens are accepted, unless DPoP is required
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer "+bearer.AccessToken)
+	assert(t, http.StatusOK, serve(opts, r).Code)
+	w = serve(&MiddlewareOptions{DPoP: verifier, RequireDPoP: true}, r)
+	assert(t, http.StatusUnauthorized, w.Code)
+	assert(t, `DPoP algs="ES256 ES384 ES512 RS256 PS256 EdDSA"`, w.Header().Get("WWW-Authenticate"))
+
+	// Refresh tokens can only be used with a proof signed using the key
+	// the family is bound to, and the family survives the failed attempts
+	refreshRequest := func(s *DPoPSigner) *http.Request {
+		r := httptest.NewRequest("POST", "https://auth.example.com/oauth/token", nil)
+		if err := s.Authorize(r, ""); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	if _, err = tm.RefreshTokenPair(pair.RefreshToken); err != ErrDPoPProofMissing {
+		t.Errorf("expected %v, got %v", ErrDPoPProofMissing, err)
+	}
+	_, err = tm.RefreshDPoPTokenPair(verifier, refreshRequest(other), pair.RefreshToken)
+	if err != ErrDPoPBindingMismatch {
+		t.Errorf("expected %v, got %v", ErrDPoPBindingMismatch, err)
+	}
+	_, err = tm.RefreshDPoPTokenPair(verifier, refreshRequest(other), bearer.RefreshToken)
+	if err != ErrDPoPBindingMismatch {
+		t.Errorf("expected %v, got %v", ErrDPoPBindingMismatch, err)
+	}
+	_, err = tm.RefreshDPoPTokenPair(verifier, httptest.NewRequest("POST", "/oauth/token", nil), pair.RefreshToken)
+	if err != ErrDPoPProofMissing {
+		t.Errorf("expected %v, got %v", ErrDPoPProofMissing, err)
+	}
+
+	// Refreshed tokens stay bound to the key
+	pair, err = tm.RefreshDPoPTokenPair(verifier, refreshRequest(signer), pair.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, "DPoP", pair.TokenType)
+	assert(t, http.StatusOK, serve(opts, newRequest(signer, pair.AccessToken)).Code)
+
+	// Introspection reports the binding
+	h := tm.IntrospectionHandler(ClientCredentials{"rs": "s3cr:t"})
+	res := introspect(t, h, pair.AccessToken)
+	assert(t, "DPoP", res.TokenType)
+	assert(t, signer.Thumbprint(), res.Cnf["jkt"])
+}
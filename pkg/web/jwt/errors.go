@@ -16,21 +16,101 @@ var (
 	ErrTokenExpired             = errors.New("token expired")
 	ErrTokenNotValidYet         = errors.New("token not valid yet")
 	ErrTokenUsedBeforeIssued    = errors.New("token used before issued")
+	ErrTokenTooOld              = errors.New("token exceeds the maximum age")
 	ErrTokenInvalidAudience     = errors.New("token contains invalid audience")
 	ErrTokenInvalidIssuer       = errors.New("token contains invalid issuer")
 	ErrTokenInvalidSubject      = errors.New("token contains invalid subject")
 	ErrTokenInvalidCustomClaims = errors.New("token contains invalid custom claims")
 	ErrTokenClaimNotFound       = errors.New("token claim not found")
+	ErrTokenRevoked             = errors.New("token has been revoked")
+	ErrTokenClaimMismatch       = errors.New("token claim does not match the required value")
+	ErrTokenInsufficientScope   = errors.New("token does not have the required scope")
 )
 
 // Parser errors
 var (
 	ErrTokenMalformed        = errors.New("token is malformed")
+	ErrTokenEncrypted        = errors.New("token is encrypted and must be decrypted first")
+	ErrTokenTooLarge         = errors.New("token exceeds the size limit")
+	ErrTokenSegmentCount     = errors.New("token must have exactly three segments")
 	ErrTokenUnverifiable     = errors.New("token is unverifiable")
 	ErrTokenClaimsInvalid    = errors.New("token claims validation error")
 	ErrTokenSignatureInvalid = errors.New("token validation signature invalid")
 )
 
+// JWK errors
+var (
+	ErrJWKMalformed          = errors.New("jwk: key is malformed")
+	ErrJWKUnsupportedKeyType = errors.New("jwk: unsupported key type")
+	ErrJWKNotPrivate         = errors.New("jwk: key does not contain private key material")
+)
+
+// Revocation errors
+var (
+	ErrNoRevocationStore = errors.New("revocation: no revocation store configured")
+)
+
+// Refresh token errors
+var (
+	ErrNoRefreshTokenStore = errors.New("refresh: no refresh token store configured")
+	ErrRefreshTokenInvalid = errors.New("refresh: refresh token is invalid")
+	ErrRefreshTokenExpired = errors.New("refresh: refresh token has expired")
+	ErrRefreshTokenRevoked = errors.New("refresh: refresh token family has been revoked")
+	ErrRefreshTokenReused  = errors.New("refresh: refresh token has already been used")
+)
+
+// KeyRing errors
+var (
+	ErrKeyNotFound  = errors.New("keyring: key not found")
+	ErrKeyExists    = errors.New("keyring: key with the same key id already exists")
+	ErrKeyExpired   = errors.New("keyring: key has been retired and is past its grace window")
+	ErrKeyInUse     = errors.New("keyring: key is the current signing key")
+	ErrNoSigningKey = errors.New("keyring: no current signing key")
+)
+
+// JWS errors
+var (
+	ErrJWSPayloadRequired = errors.New("jws: detached payload must be provided")
+	ErrJWSPayloadMismatch = errors.New("jws: payload does not match the attached payload")
+	ErrJWSInvalidPayload  = errors.New("jws: unencoded payload must not contain a '.'")
+	ErrJWSUnsupportedCrit = errors.New("jws: unsupported critical header parameter")
+	ErrJWSNoSignatures    = errors.New("jws: no signatures")
+)
+
+// JWE errors
+var (
+	ErrJWEMalformed            = errors.New("jwe: token is malformed")
+	ErrJWEUnsupportedAlgorithm = errors.New("jwe: unsupported algorithm")
+	ErrJWEDecryption           = errors.New("jwe: decryption failed")
+	ErrJWENotNested            = errors.New("jwe: token does not contain a nested token")
+	ErrJWEUnsupportedCrit      = errors.New("jwe: unsupported critical header parameter")
+)
+
+// Remote key set errors
+var (
+	ErrJWKSFetch     = errors.New("jwks: failed to fetch key set")
+	ErrJWKSRateLimit = errors.New("jwks: key not found and refresh is rate limited")
+)
+
+// Authorization server errors
+var (
+	ErrInvalidClient      = errors.New("client authentication failed")
+	ErrUnauthorizedClient = errors.New("token was not issued to the client")
+)
+
+// DPoP errors
+var (
+	ErrDPoPProofMissing    = errors.New("dpop: no proof present in request")
+	ErrDPoPProofInvalid    = errors.New("dpop: proof is invalid")
+	ErrDPoPProofReplayed   = errors.New("dpop: proof has already been used")
+	ErrDPoPBindingMismatch = errors.New("dpop: token is not bound to the proof key")
+)
+
+// Remote signer errors
+var (
+	ErrRemoteSigner = errors.New("signer: remote signing failed")
+)
+
 var (
 	ErrNoTokenInRequest = errors.New("no token present in request")
 	ErrNoCookieFound    = errors.New("no cookie present with specified name in request")
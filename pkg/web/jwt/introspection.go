@@ -0,0 +1,343 @@
+package jwt
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxEndpointForm is the largest request body read by the introspection and
+// revocation endpoints.
+const maxEndpointForm = 64 << 10
+
+// Token type hints (RFC 7009, section 2.1 and RFC 7662, section 2.1)
+const (
+	TokenTypeHintAccessToken  = "access_token"
+	TokenTypeHintRefreshToken = "refresh_token"
+)
+
+// OAuth 2.0 error codes returned by the introspection and revocation
+// endpoints (RFC 6749, section 5.2 and RFC 7009, section 2.2.1)
+const (
+	OAuthErrorInvalidRequest       = "invalid_request"
+	OAuthErrorInvalidClient        = "invalid_client"
+	OAuthErrorUnauthorizedClient   = "unauthorized_client"
+	OAuthErrorUnsupportedTokenType = "unsupported_token_type"
+)
+
+// ClientAuthenticator authenticates the client making a request to the
+// introspection or revocation endpoint, returning its client id. It should
+// return ErrInvalidClient if the client cannot be authenticated.
+type ClientAuthenticator interface {
+	AuthenticateClient(r *http.Request) (clientID string, err error)
+}
+
+// ClientAuthenticatorFunc is an adapter to allow the use of ordinary
+// functions as a ClientAuthenticator.
+type ClientAuthenticatorFunc func(r *http.Request) (string, error)
+
+func (fn ClientAuthenticatorFunc) AuthenticateClient(r *http.Request) (string, error) {
+	return fn(r)
+}
+
+// ClientCredentials is a ClientAuthenticator holding a fixed set of client
+// ids and their secrets. Clients can authenticate using HTTP Basic
+// authentication or the client_id and client_secret form parameters, as
+// described in RFC 6749, section 2.3.1.
+type ClientCredentials map[string]string
+
+func (c ClientCredentials) AuthenticateClient(r *http.Request) (string, error) {
+	id, secret, ok := r.BasicAuth()
+	if ok {
+		// The credentials are form encoded before they are base64 encoded
+		var err1, err2 error
+		id, err1 = url.QueryUnescape(id)
+		secret, err2 = url.QueryUnescape(secret)
+		if err1 != nil || err2 != nil {
+			return "", ErrInvalidClient
+		}
+	} else {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	want, found := c[id]
+	if id == "" || !found {
+		return "", ErrInvalidClient
+	}
+	// Compare hashes, so the time taken does not depend on the length
+	// of the secret.
+	got, exp := sha256.Sum256([]byte(secret)), sha256.Sum256([]byte(want))
+	if subtle.ConstantTimeCompare(got[:], exp[:]) != 1 {
+		return "", ErrInvalidClient
+	}
+	return id, nil
+}
+
+// IntrospectionResponse is the response of the introspection endpoint, as
+// described in RFC 7662, section 2.2. Inactive tokens only report active
+// as false.
+type IntrospectionResponse struct {
+	Active    bool        `json:"active"`
+	Scope     string      `json:"scope,omitempty"`
+	ClientID  string      `json:"client_id,omitempty"`
+	Username  string      `json:"username,omitempty"`
+	TokenType string      `json:"token_type,omitempty"`
+	Exp       NumericDate `json:"exp,omitempty"`
+	Iat       NumericDate `json:"iat,omitempty"`
+	Nbf       NumericDate `json:"nbf,omitempty"`
+	Sub       string      `json:"sub,omitempty"`
+	Aud       Audience    `json:"aud,omitempty"`
+	Iss       string      `json:"iss,omitempty"`
+	Jti       string      `json:"jti,omitempty"`
+
+	// Cnf holds the confirmation of a token bound to a DPoP key
+	// (RFC 9449, section 6.2).
+	Cnf map[string]any `json:"cnf,omitempty"`
+}
+
+// IntrospectionHandler returns a http.Handler implementing the token
+// introspection endpoint described in RFC 7662, for resource servers that
+// cannot validate tokens themselves. Access tokens are validated using
+// ValidateToken, so revoked tokens are reported as inactive, and refresh
+// tokens are looked up in the RefreshTokenStore, if there is one. Callers
+// must authenticate using the provided ClientAuthenticator.
+func (m *TokenManager) IntrospectionHandler(clients ClientAuthenticator) http.Handler {
+	return m.endpointHandler(
+		clients, func(w http.ResponseWriter, r *http.Request, clientID string) {
+			token, hint := r.PostForm.Get("token"), r.PostForm.Get("token_type_hint")
+			res := m.introspect(token, hint)
+			b, err := json.Marshal(res)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write(b)
+		},
+	)
+}
+
+// introspect looks up the token, using the hint to decide which type of
+// token to try first.
+func (m *TokenManager) introspect(token, hint string) *IntrospectionResponse {
+	lookups := []func(string) *IntrospectionResponse{m.introspectAccessToken, m.introspectRefreshToken}
+	if hint == TokenTypeHintRefreshToken {
+		lookups[0], lookups[1] = lookups[1], lookups[0]
+	}
+	for _, lookup := range lookups {
+		if res := lookup(token); res != nil {
+			return res
+		}
+	}
+	return &IntrospectionResponse{Active: false}
+}
+
+func (m *TokenManager) introspectAccessToken(token string) *IntrospectionResponse {
+	t, err := m.ValidateToken(RawToken(token))
+	if err != nil {
+		return nil
+	}
+	res := &IntrospectionResponse{
+		Active:    true,
+		Scope:     strings.Join(claimScopes(t.Payload), " "),
+		ClientID:  clientIDClaim(t.Payload),
+		TokenType: "Bearer",
+	}
+	if jkt := dpopBinding(t.Payload); jkt != "" {
+		res.TokenType, res.Cnf = "DPoP", DPoPConfirmation(jkt)
+	}
+	res.Username, _ = t.Payload["username"].(string)
+	res.Exp, _ = t.Payload.GetEXP()
+	res.Iat, _ = t.Payload.GetIAT()
+	res.Nbf, _ = t.Payload.GetNBF()
+	res.Sub, _ = t.Payload.GetSUB()
+	res.Aud, _ = t.Payload.GetAUD()
+	res.Iss, _ = t.Payload.GetISS()
+	res.Jti, _ = t.Payload.GetJTI()
+	return res
+}
+
+func (m *TokenManager) introspectRefreshToken(token string) *IntrospectionResponse {
+	f, err := m.lookupRefreshToken(token)
+	if err != nil {
+		return nil
+	}
+	return &IntrospectionResponse{
+		Active:   true,
+		Scope:    strings.Join(claimScopes(f.Claims), " "),
+		ClientID: clientIDClaim(f.Claims),
+		Exp:      NumericDate(f.Expires.Unix()),
+		Sub:      f.Subject,
+	}
+}
+
+// RevocationHandler returns a http.Handler implementing the token
+// revocation endpoint described in RFC 7009. Refresh tokens are revoked
+// along with their whole family, and access tokens are revoked using the
+// RevocationStore. Tokens carrying a client_id (or azp) claim can only be
+// revoked by that client. As required by the RFC, invalid tokens are
+// treated as successfully revoked. Callers must authenticate using the
+// provided ClientAuthenticator.
+func (m *TokenManager) RevocationHandler(clients ClientAuthenticator) http.Handler {
+	return m.endpointHandler(
+		clients, func(w http.ResponseWriter, r *http.Request, clientID string) {
+			token, hint := r.PostForm.Get("token"), r.PostForm.Get("token_type_hint")
+			err := m.revoke(token, hint, clientID)
+			switch {
+			case errors.Is(err, ErrUnauthorizedClient):
+				writeOAuthError(w, http.StatusBadRequest, OAuthErrorUnauthorizedClient)
+			case errors.Is(err, ErrNoRevocationStore):
+				writeOAuthError(w, http.StatusBadRequest, OAuthErrorUnsupportedTokenType)
+			case err != nil:
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			default:
+				w.WriteHeader(http.StatusOK)
+			}
+		},
+	)
+}
+
+// revoke revokes the token if it is a refresh token (of an active family)
+// or an access token that can be verified. Invalid tokens are ignored.
+func (m *TokenManager) revoke(token, hint, clientID string) error {
+	if hint != TokenTypeHintAccessToken && m.RefreshTokenStore != nil {
+		if f, err := m.lookupRefreshToken(token); err == nil {
+			if !clientMatches(f.Claims, clientID) {
+				return ErrUnauthorizedClient
+			}
+			return m.revokeFamily(f)
+		}
+	}
+	t, err := m.parser().Parse(RawToken(token))
+	if err != nil {
+		return nil
+	}
+	if !clientMatches(t.Payload, clientID) {
+		return ErrUnauthorizedClient
+	}
+	err = m.Revoke(RawToken(token))
+	if errors.Is(err, ErrNoRevocationStore) {
+		return err
+	}
+	if errors.Is(err, ErrTokenMalformed) || errors.Is(err, ErrTokenSignatureInvalid) ||
+		errors.Is(err, ErrTokenUnverifiable) || errors.Is(err, ErrTokenClaimNotFound) ||
+		errors.Is(err, ErrKeyNotFound) || errors.Is(err, ErrKeyExpired) {
+		// Not a token we can revoke, which is not an error to the client
+		return nil
+	}
+	return err
+}
+
+// UserinfoHandler returns a http.Handler that responds with the claims of
+// the bearer token carried by the request, much like the OpenID Connect
+// UserInfo endpoint. Claims describing the token itself, rather than the
+// user (such as exp and jti), are left out. Tokens bound to a DPoP key
+// are rejected, since they must not be used as bearer tokens.
+func (m *TokenManager) UserinfoHandler() http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodPost {
+				w.Header().Set("Allow", "GET, POST")
+				http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+				return
+			}
+			raw, err := HeaderExtractor{}.Extract(r)
+			if err != nil {
+				WriteAuthError(w, r, &AuthError{http.StatusUnauthorized, "", "", err})
+				return
+			}
+			token, err := m.ValidateToken(raw)
+			if err == nil && dpopBinding(token.Payload) != "" {
+				err = &DPoPError{Err: ErrDPoPBindingMismatch}
+			}
+			if err != nil {
+				WriteAuthError(w, r, &AuthError{http.StatusUnauthorized, AuthErrorInvalidToken, "", err})
+				return
+			}
+			info := make(MapClaims, len(token.Payload))
+			for k, v := range token.Payload {
+				switch k {
+				case "iss", "aud", "exp", "nbf", "iat", "jti", "scope", "scp", "client_id", "azp", "cnf":
+					continue
+				}
+				info[k] = v
+			}
+			b, err := json.Marshal(info)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write(b)
+		},
+	)
+}
+
+// endpointHandler handles the parts that the introspection and revocation
+// endpoints have in common: only POST requests are allowed, the form is
+// parsed, the client is authenticated and the token parameter must be
+// present.
+func (m *TokenManager) endpointHandler(clients ClientAuthenticator, fn func(w http.ResponseWriter, r *http.Request, clientID string)) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				w.Header().Set("Allow", "POST")
+				http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+				return
+			}
+			// Responses may hold token details, and must never be cached
+			w.Header().Set("Cache-Control", "no-store")
+			r.Body = http.MaxBytesReader(w, r.Body, maxEndpointForm)
+			if err := r.ParseForm(); err != nil {
+				writeOAuthError(w, http.StatusBadRequest, OAuthErrorInvalidRequest)
+				return
+			}
+			if clients == nil {
+				writeOAuthError(w, http.StatusUnauthorized, OAuthErrorInvalidClient)
+				return
+			}
+			clientID, err := clients.AuthenticateClient(r)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", "Basic")
+				writeOAuthError(w, http.StatusUnauthorized, OAuthErrorInvalidClient)
+				return
+			}
+			if r.PostForm.Get("token") == "" {
+				writeOAuthError(w, http.StatusBadRequest, OAuthErrorInvalidRequest)
+				return
+			}
+			fn(w, r, clientID)
+		},
+	)
+}
+
+// writeOAuthError writes an error response as described in RFC 6749,
+// section 5.2.
+func writeOAuthError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": code})
+}
+
+// clientIDClaim returns the client the token was issued to, from either
+// the client_id (RFC 9068) or the azp claim.
+func clientIDClaim(claims MapClaims) string {
+	if id, ok := claims["client_id"].(string); ok {
+		return id
+	}
+	id, _ := claims["azp"].(string)
+	return id
+}
+
+// clientMatches reports whether the token with the claims may be revoked
+// by the client. Tokens that do not say which client they were issued to
+// can be revoked by any authenticated client.
+func clientMatches(claims MapClaims, clientID string) bool {
+	id := clientIDClaim(claims)
+	return id == "" || id == clientID
+}
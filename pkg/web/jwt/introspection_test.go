@@ -0,0 +1,183 @@
+package jwt
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// postForm sends the form to the handler, authenticating as the client
+// using HTTP Basic authentication if id is not empty.
+func postForm(h http.Handler, id, secret string, form url.Values) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if id != "" {
+		r.SetBasicAuth(url.QueryEscape(id), url.QueryEscape(secret))
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func introspect(t *testing.T, h http.Handler, token string) *IntrospectionResponse {
+	t.Helper()
+	w := postForm(h, "rs", "s3cr:t", url.Values{"token": {token}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("wanted status %d, got %d", http.StatusOK, w.Code)
+	}
+	assert(t, "no-store", w.Header().Get("Cache-Control"))
+	var res IntrospectionResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	return &res
+}
+
+func TestTokenManager_IntrospectionHandler(t *testing.T) {
+	tm := newRefreshTestManager(t)
+	clients := ClientCredentials{"rs": "s3cr:t", "app": "secret"}
+	h := tm.IntrospectionHandler(clients)
+
+	pair, err := tm.IssueTokenPair("alice", MapClaims{"scope": "orders:read", "client_id": "app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Access tokens
+	res := introspect(t, h, pair.AccessToken)
+	if !res.Active {
+		t.Fatalf("expected the access token to be active")
+	}
+	assert(t, "alice", res.Sub)
+	assert(t, "orders:read", res.Scope)
+	assert(t, "app", res.ClientID)
+	assert(t, "Bearer", res.TokenType)
+	if res.Exp == 0 || res.Jti == "" {
+		t.Errorf("expected exp and jti, got %+v", res)
+	}
+
+	// Refresh tokens
+	res = introspect(t, h, pair.RefreshToken)
+	if !res.Active {
+		t.Fatalf("expected the refresh token to be active")
+	}
+	assert(t, "alice", res.Sub)
+	assert(t, "app", res.ClientID)
+
+	// Invalid tokens only report active
+	w := postForm(h, "rs", "s3cr:t", url.Values{"token": {"garbage"}})
+	assert(t, `{"active":false}`, w.Body.String())
+
+	// Revoked access tokens are inactive
+	if err = tm.Revoke(RawToken(pair.AccessToken)); err != nil {
+		t.Fatal(err)
+	}
+	if introspect(t, h, pair.AccessToken).Active {
+		t.Errorf("expected the revoked access token to be inactive")
+	}
+
+	// Client authentication is required, using either method
+	tests := []struct {
+		id, secret string
+		form       url.Values
+		status     int
+	}{
+		{"", "", url.Values{"token": {"x"}}, http.StatusUnauthorized},
+		{"rs", "wrong", url.Values{"token": {"x"}}, http.StatusUnauthorized},
+		{"nobody", "s3cr:t", url.Values{"token": {"x"}}, http.StatusUnauthorized},
+		{"", "", url.Values{"token": {"x"}, "client_id": {"app"}, "client_secret": {"secret"}}, http.StatusOK},
+		{"rs", "s3cr:t", url.Values{}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := postForm(h, tt.id, tt.secret, tt.form)
+		if w.Code != tt.status {
+			t.Errorf("wanted status %d, got %d", tt.status, w.Code)
+		}
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/?token=x", nil))
+	assert(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestTokenManager_RevocationHandler(t *testing.T) {
+	tm := newRefreshTestManager(t)
+	clients := ClientCredentials{"app": "secret", "other": "secret"}
+	h := tm.RevocationHandler(clients)
+
+	// Revoking a refresh token revokes the family
+	pair, err := tm.IssueTokenPair("alice", MapClaims{"client_id": "app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := postForm(h, "other", "secret", url.Values{"token": {pair.RefreshToken}})
+	assert(t, http.StatusBadRequest, w.Code)
+	assert(t, `{"error":"unauthorized_client"}`+"\n", w.Body.String())
+	w = postForm(h, "app", "secret", url.Values{"token": {pair.RefreshToken}, "token_type_hint": {"refresh_token"}})
+	assert(t, http.StatusOK, w.Code)
+	if _, err = tm.RefreshTokenPair(pair.RefreshToken); err != ErrRefreshTokenRevoked {
+		t.Errorf("expected %v, got %v", ErrRefreshTokenRevoked, err)
+	}
+	// The most recent access token goes with it
+	if _, err = tm.ValidateToken(RawToken(pair.AccessToken)); err == nil {
+		t.Errorf("expected the access token to be revoked")
+	}
+
+	// Access tokens can be revoked on their own
+	pair, err = tm.IssueTokenPair("bob", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = postForm(h, "other", "secret", url.Values{"token": {pair.AccessToken}, "token_type_hint": {"access_token"}})
+	assert(t, http.StatusOK, w.Code)
+	if _, err = tm.ValidateToken(RawToken(pair.AccessToken)); err == nil {
+		t.Errorf("expected the access token to be revoked")
+	}
+	if _, err = tm.RefreshTokenPair(pair.RefreshToken); err != nil {
+		t.Errorf("expected the refresh token to still work, got %v", err)
+	}
+
+	// Invalid tokens are treated as revoked
+	w = postForm(h, "app", "secret", url.Values{"token": {"garbage"}})
+	assert(t, http.StatusOK, w.Code)
+
+	// Without a revocation store access tokens cannot be revoked
+	tm.RevocationStore = nil
+	raw, err := tm.GenerateToken(MapClaims{"jti": "1", "exp": NumericDateNow().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = postForm(h, "app", "secret", url.Values{"token": {string(raw)}})
+	assert(t, http.StatusBadRequest, w.Code)
+	assert(t, `{"error":"unsupported_token_type"}`+"\n", w.Body.String())
+}
+
+func TestTokenManager_UserinfoHandler(t *testing.T) {
+	tm := NewTokenManager(HS256, HS256.GenerateKeyPair())
+	raw, err := tm.GenerateToken(
+		MapClaims{
+			"sub":   "alice",
+			"email": "alice@example.com",
+			"scope": "openid email",
+			"exp":   NumericDateNow().Add(time.Hour),
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := tm.UserinfoHandler()
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer "+string(raw))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert(t, http.StatusOK, w.Code)
+	assert(t, `{"email":"alice@example.com","sub":"alice"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,182 @@
+package jwt
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// token = Base64URLEncode(protected header) + "." +
+// 		Base64URLEncode(encrypted key) + "." +
+// 		Base64URLEncode(initialization vector) + "." +
+// 		Base64URLEncode(ciphertext) + "." +
+// 		Base64URLEncode(authentication tag)
+
+// JWEHeader is the protected header of an encrypted token.
+type JWEHeader struct {
+	Alg string `json:"alg"`
+	Enc string `json:"enc"`
+	Typ string `json:"typ,omitempty"`
+	Cty string `json:"cty,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	Epk *JWK   `json:"epk,omitempty"`
+	Apu string `json:"apu,omitempty"`
+	Apv string `json:"apv,omitempty"`
+
+	// Crit lists the extension header parameters that must be understood.
+	// No extensions are supported, so tokens that list any of them cannot
+	// be decrypted.
+	Crit []string `json:"crit,omitempty"`
+}
+
+// RawEncryptedToken is an encrypted token (JWE) in the compact
+// serialization.
+type RawEncryptedToken []byte
+
+// IsEncrypted reports whether the raw token looks like an encrypted token
+// in the compact serialization, i.e. it has five parts instead of three.
+func IsEncrypted(raw []byte) bool {
+	return bytes.Count(raw, []byte{dot}) == 4
+}
+
+// Encrypt encrypts the plaintext using the provided key management and
+// content encryption algorithms, and returns the encrypted token in the
+// compact serialization. The alg and enc fields of the header are always
+// set using the provided algorithms.
+func Encrypt(alg KeyEncryptionMethod, enc *ContentEncryptionMethod, hdr JWEHeader, plaintext []byte, key crypto.PublicKey) (RawEncryptedToken, error) {
+	hdr.Alg = alg.Name()
+	hdr.Enc = enc.Name()
+
+	// Determine the content encryption key. This must happen before
+	// the header is encoded, because some algorithms add parameters to
+	// the header.
+	cek, encryptedKey, err := alg.EncryptKey(&hdr, enc.KeySize(), key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create and encode the protected header, which is also used as the
+	// additional authenticated data.
+	dat, err := json.Marshal(hdr)
+	if err != nil {
+		return nil, err
+	}
+	header := Base64Encode(dat)
+
+	// Encrypt the plaintext
+	iv, ciphertext, tag, err := enc.encrypt(cek, plaintext, header)
+	if err != nil {
+		return nil, err
+	}
+
+	// create and return token
+	return bytes.Join(
+		[][]byte{
+			header,
+			Base64Encode(encryptedKey),
+			Base64Encode(iv),
+			Base64Encode(ciphertext),
+			Base64Encode(tag),
+		}, []byte{dot},
+	), nil
+}
+
+// Decrypt decrypts an encrypted token in the compact serialization and
+// returns the protected header along with the plaintext. The algorithms
+// are taken from the header, so callers should check the returned header
+// against the algorithms they expect. The size limits of the DefaultParser
+// apply to the token.
+func Decrypt(raw RawEncryptedToken, key crypto.PrivateKey) (*JWEHeader, []byte, error) {
+	return decrypt(raw, key, DefaultParser)
+}
+
+func decrypt(raw RawEncryptedToken, key crypto.PrivateKey, p *Parser) (*JWEHeader, []byte, error) {
+	if len(raw) > p.maxTokenSize() {
+		return nil, nil, errors.Join(ErrJWEMalformed, ErrTokenTooLarge)
+	}
+	parts := bytes.Split(raw, []byte{dot})
+	if len(parts) != 5 {
+		return nil, nil, ErrJWEMalformed
+	}
+	if len(parts[0]) > p.maxHeaderSize() {
+		return nil, nil, errors.Join(ErrJWEMalformed, ErrTokenTooLarge)
+	}
+	var dec [5][]byte
+	for i := range parts {
+		dec[i] = make([]byte, len(parts[i]))
+		n, err := base64.RawURLEncoding.Decode(dec[i], parts[i])
+		if err != nil {
+			return nil, nil, ErrJWEMalformed
+		}
+		dec[i] = dec[i][:n]
+	}
+
+	// Parse the protected header
+	var hdr JWEHeader
+	err := json.Unmarshal(dec[0], &hdr)
+	if err != nil {
+		return nil, nil, errors.Join(ErrJWEMalformed, err)
+	}
+	// RFC 7516, section 4.1.13: extensions that are not understood must
+	// be rejected, and so must an empty list
+	if hdr.Crit != nil {
+		return nil, nil, ErrJWEUnsupportedCrit
+	}
+	alg := GetKeyEncryptionMethod(hdr.Alg)
+	enc := GetContentEncryptionMethod(hdr.Enc)
+	if alg == nil || enc == nil {
+		return nil, nil, ErrJWEUnsupportedAlgorithm
+	}
+
+	// Recover the content encryption key, and decrypt the ciphertext
+	cek, err := alg.DecryptKey(&hdr, enc.KeySize(), dec[1], key)
+	if err != nil {
+		return nil, nil, err
+	}
+	plaintext, err := enc.decrypt(cek, dec[2], dec[3], dec[4], parts[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	return &hdr, plaintext, nil
+}
+
+// NewEncryptedToken creates a signed token (just like NewToken) and then
+// encrypts it, creating a nested token. The cty header of the encrypted
+// token is set to "JWT" to indicate that it contains a nested token.
+func NewEncryptedToken(method SigningMethod, claims ClaimsSet, signingKey crypto.PrivateKey, alg KeyEncryptionMethod, enc *ContentEncryptionMethod, encryptionKey crypto.PublicKey) (RawEncryptedToken, error) {
+	signed, err := NewToken(method, claims, signingKey)
+	if err != nil {
+		return nil, err
+	}
+	return Encrypt(alg, enc, JWEHeader{Cty: "JWT"}, signed, encryptionKey)
+}
+
+// DecryptToken decrypts a nested token and returns the signed token that
+// it contains. The signed token still needs to be validated.
+func DecryptToken(raw RawEncryptedToken, key crypto.PrivateKey) (RawToken, error) {
+	return decryptToken(raw, key, DefaultParser)
+}
+
+func decryptToken(raw RawEncryptedToken, key crypto.PrivateKey, p *Parser) (RawToken, error) {
+	hdr, plaintext, err := decrypt(raw, key, p)
+	if err != nil {
+		return nil, err
+	}
+	if hdr.Cty != "JWT" && hdr.Cty != "jwt" {
+		return nil, ErrJWENotNested
+	}
+	return plaintext, nil
+}
+
+// ValidateEncryptedToken decrypts a nested token using the decryption key
+// and then validates the signed token it contains using the verification
+// key. The size limits of the Parser of the validator apply to both.
+func (v *Validator) ValidateEncryptedToken(raw RawEncryptedToken, decryptionKey crypto.PrivateKey, verificationKey crypto.PublicKey) (*Token, error) {
+	signed, err := decryptToken(raw, decryptionKey, v.parser())
+	if err != nil {
+		return nil, err
+	}
+	return v.ValidateToken(signed, verificationKey)
+}
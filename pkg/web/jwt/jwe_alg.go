@@ -0,0 +1,482 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"sync"
+)
+
+var keyEncryptionMethods sync.Map
+
+// GetKeyEncryptionMethod returns the registered key management algorithm
+// with the matching name, or nil if there is no such algorithm.
+func GetKeyEncryptionMethod(name string) KeyEncryptionMethod {
+	fn, found := keyEncryptionMethods.Load(name)
+	if !found {
+		return nil
+	}
+	methodFn, ok := fn.(func() KeyEncryptionMethod)
+	if !ok {
+		panic("could not get key encryption method: method func error")
+	}
+	return methodFn()
+}
+
+// RegisterKeyEncryptionMethod registers a key management algorithm so it
+// can be looked up using the alg header of an encrypted token.
+func RegisterKeyEncryptionMethod(name string, fn func() KeyEncryptionMethod) {
+	keyEncryptionMethods.Store(name, fn)
+}
+
+// KeyEncryptionMethod is an interface for implementing the key management
+// algorithms used to determine the content encryption key of a JWE.
+type KeyEncryptionMethod interface {
+
+	// Name should return the name of the key management algorithm.
+	Name() string
+
+	// EncryptKey should determine the content encryption key of the
+	// provided size, and return it along with the encrypted key. It may
+	// add algorithm specific parameters (e.g. epk) to the header.
+	EncryptKey(hdr *JWEHeader, cekSize int, key crypto.PublicKey) (cek []byte, encryptedKey []byte, err error)
+
+	// DecryptKey should recover the content encryption key of the provided
+	// size using the header and encrypted key.
+	DecryptKey(hdr *JWEHeader, cekSize int, encryptedKey []byte, key crypto.PrivateKey) (cek []byte, err error)
+}
+
+var (
+	DIR          *KeyEncryptionMethodDirect
+	A256KW       *KeyEncryptionMethodAESKW
+	RSA_OAEP_256 *KeyEncryptionMethodRSAOAEP
+	ECDH_ES      *KeyEncryptionMethodECDHES
+)
+
+func init() {
+	DIR = &KeyEncryptionMethodDirect{
+		name: "dir",
+	}
+	A256KW = &KeyEncryptionMethodAESKW{
+		name:    "A256KW",
+		keySize: 32,
+	}
+	RSA_OAEP_256 = &KeyEncryptionMethodRSAOAEP{
+		name: "RSA-OAEP-256",
+		hash: crypto.SHA256,
+	}
+	ECDH_ES = &KeyEncryptionMethodECDHES{
+		name: "ECDH-ES",
+	}
+
+	RegisterKeyEncryptionMethod(DIR.Name(), func() KeyEncryptionMethod { return DIR })
+	RegisterKeyEncryptionMethod(A256KW.Name(), func() KeyEncryptionMethod { return A256KW })
+	RegisterKeyEncryptionMethod(RSA_OAEP_256.Name(), func() KeyEncryptionMethod { return RSA_OAEP_256 })
+	RegisterKeyEncryptionMethod(ECDH_ES.Name(), func() KeyEncryptionMethod { return ECDH_ES })
+}
+
+// KeyEncryptionMethodDirect implements direct encryption (dir) using a
+// shared symmetric key as the content encryption key.
+// Expects a []byte of the content encryption key size for both encryption
+// and decryption
+type KeyEncryptionMethodDirect struct {
+	name string
+}
+
+func (k *KeyEncryptionMethodDirect) Name() string {
+	return k.name
+}
+
+func (k *KeyEncryptionMethodDirect) EncryptKey(hdr *JWEHeader, cekSize int, key crypto.PublicKey) ([]byte, []byte, error) {
+	cek, ok := key.([]byte)
+	if !ok || len(cek) != cekSize {
+		return nil, nil, ErrInvalidKeyType
+	}
+	return cek, nil, nil
+}
+
+func (k *KeyEncryptionMethodDirect) DecryptKey(hdr *JWEHeader, cekSize int, encryptedKey []byte, key crypto.PrivateKey) ([]byte, error) {
+	cek, ok := key.([]byte)
+	if !ok || len(cek) != cekSize {
+		return nil, ErrInvalidKeyType
+	}
+	if len(encryptedKey) != 0 {
+		return nil, ErrJWEMalformed
+	}
+	return cek, nil
+}
+
+// KeyEncryptionMethodAESKW implements AES key wrap (RFC 3394) of a random
+// content encryption key.
+// Expects a []byte of the key wrap size for both encryption and decryption
+type KeyEncryptionMethodAESKW struct {
+	name    string
+	keySize int
+}
+
+func (k *KeyEncryptionMethodAESKW) Name() string {
+	return k.name
+}
+
+func (k *KeyEncryptionMethodAESKW) EncryptKey(hdr *JWEHeader, cekSize int, key crypto.PublicKey) ([]byte, []byte, error) {
+	kek, ok := key.([]byte)
+	if !ok || len(kek) != k.keySize {
+		return nil, nil, ErrInvalidKeyType
+	}
+	cek, err := randomBytes(cekSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	encryptedKey, err := aesKeyWrap(kek, cek)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cek, encryptedKey, nil
+}
+
+func (k *KeyEncryptionMethodAESKW) DecryptKey(hdr *JWEHeader, cekSize int, encryptedKey []byte, key crypto.PrivateKey) ([]byte, error) {
+	kek, ok := key.([]byte)
+	if !ok || len(kek) != k.keySize {
+		return nil, ErrInvalidKeyType
+	}
+	cek, err := aesKeyUnwrap(kek, encryptedKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(cek) != cekSize {
+		return nil, ErrJWEDecryption
+	}
+	return cek, nil
+}
+
+// KeyEncryptionMethodRSAOAEP implements RSAES-OAEP encryption of a random
+// content encryption key.
+// Expects *rsa.PublicKey for encryption and *rsa.PrivateKey for decryption
+type KeyEncryptionMethodRSAOAEP struct {
+	name string
+	hash crypto.Hash
+}
+
+func (k *KeyEncryptionMethodRSAOAEP) Name() string {
+	return k.name
+}
+
+func (k *KeyEncryptionMethodRSAOAEP) EncryptKey(hdr *JWEHeader, cekSize int, key crypto.PublicKey) ([]byte, []byte, error) {
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, nil, ErrInvalidKeyType
+	}
+	if !k.hash.Available() {
+		return nil, nil, ErrHashUnavailable
+	}
+	cek, err := randomBytes(cekSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	encryptedKey, err := rsa.EncryptOAEP(k.hash.New(), rand.Reader, rsaKey, cek, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cek, encryptedKey, nil
+}
+
+func (k *KeyEncryptionMethodRSAOAEP) DecryptKey(hdr *JWEHeader, cekSize int, encryptedKey []byte, key crypto.PrivateKey) ([]byte, error) {
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, ErrInvalidKeyType
+	}
+	if !k.hash.Available() {
+		return nil, ErrHashUnavailable
+	}
+	cek, err := rsa.DecryptOAEP(k.hash.New(), rand.Reader, rsaKey, encryptedKey, nil)
+	if err != nil || len(cek) != cekSize {
+		return nil, ErrJWEDecryption
+	}
+	return cek, nil
+}
+
+// KeyEncryptionMethodECDHES implements Elliptic Curve Diffie-Hellman
+// Ephemeral Static key agreement in direct key agreement mode, using the
+// Concat KDF to derive the content encryption key.
+// Expects *ecdsa.PublicKey for encryption and *ecdsa.PrivateKey for
+// decryption
+type KeyEncryptionMethodECDHES struct {
+	name string
+}
+
+func (k *KeyEncryptionMethodECDHES) Name() string {
+	return k.name
+}
+
+func (k *KeyEncryptionMethodECDHES) EncryptKey(hdr *JWEHeader, cekSize int, key crypto.PublicKey) ([]byte, []byte, error) {
+	pub, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, nil, ErrInvalidKeyType
+	}
+	// Generate the ephemeral key on the same curve as the recipient key
+	eph, err := ecdsa.GenerateKey(pub.Curve, rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	z, err := ecdhSharedSecret(eph, pub)
+	if err != nil {
+		return nil, nil, err
+	}
+	hdr.Epk, err = NewJWK(&eph.PublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	cek, err := concatKDF(z, hdr, cekSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cek, nil, nil
+}
+
+func (k *KeyEncryptionMethodECDHES) DecryptKey(hdr *JWEHeader, cekSize int, encryptedKey []byte, key crypto.PrivateKey) ([]byte, error) {
+	pri, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, ErrInvalidKeyType
+	}
+	if hdr.Epk == nil || len(encryptedKey) != 0 {
+		return nil, ErrJWEMalformed
+	}
+	epk, err := hdr.Epk.PublicKey()
+	if err != nil {
+		return nil, ErrJWEMalformed
+	}
+	pub, ok := epk.(*ecdsa.PublicKey)
+	if !ok || pub.Curve != pri.Curve {
+		return nil, ErrJWEMalformed
+	}
+	z, err := ecdhSharedSecret(pri, pub)
+	if err != nil {
+		return nil, ErrJWEDecryption
+	}
+	return concatKDF(z, hdr, cekSize)
+}
+
+func ecdhSharedSecret(pri *ecdsa.PrivateKey, pub *ecdsa.PublicKey) ([]byte, error) {
+	ecdhPri, err := pri.ECDH()
+	if err != nil {
+		return nil, ErrInvalidKeyType
+	}
+	ecdhPub, err := pub.ECDH()
+	if err != nil {
+		return nil, ErrInvalidKeyType
+	}
+	return ecdhPri.ECDH(ecdhPub)
+}
+
+// concatKDF implements the Concat KDF from NIST SP 800-56A as it is
+// described in RFC 7518, section 4.6.2 for direct key agreement, where
+// the AlgorithmID is the content encryption algorithm.
+func concatKDF(z []byte, hdr *JWEHeader, keySize int) ([]byte, error) {
+	apu, err := base64.RawURLEncoding.DecodeString(hdr.Apu)
+	if err != nil {
+		return nil, ErrJWEMalformed
+	}
+	apv, err := base64.RawURLEncoding.DecodeString(hdr.Apv)
+	if err != nil {
+		return nil, ErrJWEMalformed
+	}
+	var info []byte
+	info = appendLengthPrefixed(info, []byte(hdr.Enc))
+	info = appendLengthPrefixed(info, apu)
+	info = appendLengthPrefixed(info, apv)
+	info = binary.BigEndian.AppendUint32(info, uint32(keySize*8))
+
+	out := make([]byte, 0, keySize+sha256.Size)
+	for counter := uint32(1); len(out) < keySize; counter++ {
+		hasher := sha256.New()
+		var ctr [4]byte
+		binary.BigEndian.PutUint32(ctr[:], counter)
+		hasher.Write(ctr[:])
+		hasher.Write(z)
+		hasher.Write(info)
+		out = hasher.Sum(out)
+	}
+	return out[:keySize], nil
+}
+
+func appendLengthPrefixed(dst, data []byte) []byte {
+	dst = binary.BigEndian.AppendUint32(dst, uint32(len(data)))
+	return append(dst, data...)
+}
+
+// aesKeyWrapIV is the default initial value from RFC 3394, section 2.2.3.1
+var aesKeyWrapIV = []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+
+// aesKeyWrap wraps the provided key using the key encryption key as it is
+// described in RFC 3394, section 2.2.1.
+func aesKeyWrap(kek, key []byte) ([]byte, error) {
+	if len(key)%8 != 0 || len(key) < 16 {
+		return nil, ErrInvalidKeyType
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, ErrInvalidKeyType
+	}
+	n := len(key) / 8
+	out := make([]byte, 8+len(key))
+	copy(out, aesKeyWrapIV)
+	copy(out[8:], key)
+	buf := make([]byte, 16)
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			copy(buf, out[:8])
+			copy(buf[8:], out[i*8:i*8+8])
+			block.Encrypt(buf, buf)
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(out[:8], binary.BigEndian.Uint64(buf[:8])^t)
+			copy(out[i*8:], buf[8:])
+		}
+	}
+	return out, nil
+}
+
+// aesKeyUnwrap unwraps the provided wrapped key using the key encryption
+// key as it is described in RFC 3394, section 2.2.2.
+func aesKeyUnwrap(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped)%8 != 0 || len(wrapped) < 24 {
+		return nil, ErrJWEDecryption
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, ErrInvalidKeyType
+	}
+	n := len(wrapped)/8 - 1
+	out := make([]byte, len(wrapped))
+	copy(out, wrapped)
+	buf := make([]byte, 16)
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(buf[:8], binary.BigEndian.Uint64(out[:8])^t)
+			copy(buf[8:], out[i*8:i*8+8])
+			block.Decrypt(buf, buf)
+			copy(out[:8], buf[:8])
+			copy(out[i*8:], buf[8:])
+		}
+	}
+	if subtle.ConstantTimeCompare(out[:8], aesKeyWrapIV) != 1 {
+		return nil, ErrJWEDecryption
+	}
+	return out[8:], nil
+}
+
+// ContentEncryptionMethod implements the AES GCM family of content
+// encryption algorithms.
+type ContentEncryptionMethod struct {
+	name    string
+	keySize int
+}
+
+var (
+	A128GCM *ContentEncryptionMethod
+	A192GCM *ContentEncryptionMethod
+	A256GCM *ContentEncryptionMethod
+)
+
+func init() {
+	A128GCM = &ContentEncryptionMethod{
+		name:    "A128GCM",
+		keySize: 16,
+	}
+	A192GCM = &ContentEncryptionMethod{
+		name:    "A192GCM",
+		keySize: 24,
+	}
+	A256GCM = &ContentEncryptionMethod{
+		name:    "A256GCM",
+		keySize: 32,
+	}
+}
+
+// GetContentEncryptionMethod returns the content encryption algorithm with
+// the matching name, or nil if there is no such algorithm.
+func GetContentEncryptionMethod(name string) *ContentEncryptionMethod {
+	switch name {
+	case A128GCM.name:
+		return A128GCM
+	case A192GCM.name:
+		return A192GCM
+	case A256GCM.name:
+		return A256GCM
+	}
+	return nil
+}
+
+func (c *ContentEncryptionMethod) Name() string {
+	return c.name
+}
+
+// KeySize returns the size of the content encryption key in bytes.
+func (c *ContentEncryptionMethod) KeySize() int {
+	return c.keySize
+}
+
+// GenerateKey generates a random key that can be used as a shared key
+// with the dir key management algorithm.
+func (c *ContentEncryptionMethod) GenerateKey() ([]byte, error) {
+	return randomBytes(c.keySize)
+}
+
+func (c *ContentEncryptionMethod) encrypt(cek, plaintext, aad []byte) (iv, ciphertext, tag []byte, err error) {
+	aead, err := c.aead(cek)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	iv, err = randomBytes(aead.NonceSize())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	out := aead.Seal(nil, iv, plaintext, aad)
+	n := len(out) - aead.Overhead()
+	return iv, out[:n], out[n:], nil
+}
+
+func (c *ContentEncryptionMethod) decrypt(cek, iv, ciphertext, tag, aad []byte) ([]byte, error) {
+	aead, err := c.aead(cek)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != aead.NonceSize() || len(tag) != aead.Overhead() {
+		return nil, ErrJWEMalformed
+	}
+	in := make([]byte, 0, len(ciphertext)+len(tag))
+	in = append(in, ciphertext...)
+	in = append(in, tag...)
+	plaintext, err := aead.Open(nil, iv, in, aad)
+	if err != nil {
+		return nil, ErrJWEDecryption
+	}
+	return plaintext, nil
+}
+
+func (c *ContentEncryptionMethod) aead(cek []byte) (cipher.AEAD, error) {
+	if len(cek) != c.keySize {
+		return nil, ErrInvalidKeyType
+	}
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func randomBytes(n int) ([]byte, error) {
+	buf := make([]byte, n)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
@@ -0,0 +1,178 @@
+package jwt
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAESKeyWrap(t *testing.T) {
+	// Test vector from RFC 3394, section 4.1
+	kek, _ := hex.DecodeString("000102030405060708090A0B0C0D0E0F")
+	key, _ := hex.DecodeString("00112233445566778899AABBCCDDEEFF")
+	want, _ := hex.DecodeString("1FA68B0A8112B447AEF34BD8FB5A7B829D3E862371D2CFE5")
+
+	got, err := aesKeyWrap(kek, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(want, got) {
+		t.Errorf("wrapped key mismatch:\nwanted=%X\ngot=%X", want, got)
+	}
+	got, err = aesKeyUnwrap(kek, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, got) {
+		t.Errorf("unwrapped key mismatch:\nwanted=%X\ngot=%X", key, got)
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, rsaBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sharedKey, err := A256GCM.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		alg    KeyEncryptionMethod
+		encKey crypto.PublicKey
+		decKey crypto.PrivateKey
+	}{
+		{DIR, sharedKey, sharedKey},
+		{A256KW, sharedKey, sharedKey},
+		{RSA_OAEP_256, &rsaKey.PublicKey, rsaKey},
+		{ECDH_ES, &ecKey.PublicKey, ecKey},
+	}
+
+	plaintext := []byte(`{"ssn":"123-45-6789"}`)
+	for _, tt := range tests {
+		raw, err := Encrypt(tt.alg, A256GCM, JWEHeader{}, plaintext, tt.encKey)
+		if err != nil {
+			t.Fatalf("[%s] error encrypting: %v", tt.alg.Name(), err)
+		}
+		if !IsEncrypted(raw) {
+			t.Errorf("[%s] expected five parts", tt.alg.Name())
+		}
+		if bytes.Contains(raw, []byte("123-45-6789")) {
+			t.Errorf("[%s] plaintext leaked into the token", tt.alg.Name())
+		}
+		hdr, got, err := Decrypt(raw, tt.decKey)
+		if err != nil {
+			t.Fatalf("[%s] error decrypting: %v", tt.alg.Name(), err)
+		}
+		assert(t, tt.alg.Name(), hdr.Alg)
+		assert(t, "A256GCM", hdr.Enc)
+		if !bytes.Equal(plaintext, got) {
+			t.Errorf("[%s] plaintext mismatch: %s", tt.alg.Name(), got)
+		}
+
+		// Tampering with the ciphertext must be detected
+		tampered := append(RawEncryptedToken{}, raw...)
+		i := bytes.LastIndexByte(tampered, dot) - 2
+		if tampered[i] == 'A' {
+			tampered[i] = 'B'
+		} else {
+			tampered[i] = 'A'
+		}
+		if _, _, err = Decrypt(tampered, tt.decKey); err == nil {
+			t.Errorf("[%s] tampered token decrypted successfully", tt.alg.Name())
+		}
+	}
+}
+
+func TestNestedToken(t *testing.T) {
+	signingKeys := ES256.GenerateKeyPair()
+	encryptionKeys := RS256.GenerateKeyPair()
+
+	claims := &RegisteredClaims{
+		Subject:        "jon doe",
+		ExpirationTime: NumericDate(time.Now().Add(time.Hour).Unix()),
+	}
+	raw, err := NewEncryptedToken(ES256, claims, signingKeys.PrivateKey, RSA_OAEP_256, A256GCM, encryptionKeys.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A signed token parser should refuse the encrypted token
+	_, err = ParseRawToken(RawToken(raw))
+	if !errors.Is(err, ErrTokenEncrypted) {
+		t.Errorf("expected %v, got %v", ErrTokenEncrypted, err)
+	}
+
+	validator := &Validator{Method: ES256, ExpectedSUB: "jon doe"}
+	tok, err := validator.ValidateEncryptedToken(raw, encryptionKeys.PrivateKey, signingKeys.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, _ := tok.Payload.GetSUB()
+	assert(t, "jon doe", sub)
+
+	// Plain encrypted content is not a nested token
+	raw, err = Encrypt(RSA_OAEP_256, A256GCM, JWEHeader{}, []byte("hello"), encryptionKeys.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = DecryptToken(raw, encryptionKeys.PrivateKey); err != ErrJWENotNested {
+		t.Errorf("expected %v, got %v", ErrJWENotNested, err)
+	}
+}
+
+func TestDecrypt_Limits(t *testing.T) {
+	key, err := A256GCM.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tokens over the size limit of the parser are rejected before they
+	// are decoded
+	raw, err := Encrypt(DIR, A256GCM, JWEHeader{}, make([]byte, DefaultMaxTokenSize), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = Decrypt(raw, key); !errors.Is(err, ErrTokenTooLarge) {
+		t.Errorf("expected %v, got %v", ErrTokenTooLarge, err)
+	}
+	validator := &Validator{Method: HS256, Parser: &Parser{MaxTokenSize: 64}}
+	raw, err = Encrypt(DIR, A256GCM, JWEHeader{Cty: "JWT"}, []byte("a.b.c"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = validator.ValidateEncryptedToken(raw, key, nil); !errors.Is(err, ErrTokenTooLarge) {
+		t.Errorf("expected %v, got %v", ErrTokenTooLarge, err)
+	}
+	raw, err = Encrypt(DIR, A256GCM, JWEHeader{Kid: strings.Repeat("k", DefaultMaxHeaderSize)}, []byte("hello"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = Decrypt(raw, key); !errors.Is(err, ErrTokenTooLarge) {
+		t.Errorf("expected %v, got %v", ErrTokenTooLarge, err)
+	}
+
+	// No extensions are understood, so any critical header is rejected
+	for _, crit := range [][]string{{"exp"}, {"kid"}} {
+		raw, err = Encrypt(DIR, A256GCM, JWEHeader{Crit: crit}, []byte("hello"), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err = Decrypt(raw, key); err != ErrJWEUnsupportedCrit {
+			t.Errorf("[%q] expected %v, got %v", crit, ErrJWEUnsupportedCrit, err)
+		}
+	}
+}
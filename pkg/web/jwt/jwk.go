@@ -0,0 +1,429 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+)
+
+// Key types (kty) as defined in RFC 7518 and RFC 8037
+const (
+	KeyTypeRSA = "RSA"
+	KeyTypeEC  = "EC"
+	KeyTypeOKP = "OKP"
+	KeyTypeOct = "oct"
+)
+
+// JWK represents a single JSON Web Key as described in RFC 7517. Only
+// the members that are relevant to the key type (kty) are populated.
+type JWK struct {
+	Kty    string   `json:"kty"`
+	Use    string   `json:"use,omitempty"`
+	KeyOps []string `json:"key_ops,omitempty"`
+	Alg    string   `json:"alg,omitempty"`
+	Kid    string   `json:"kid,omitempty"`
+
+	// RSA public and private members
+	N  string `json:"n,omitempty"`
+	E  string `json:"e,omitempty"`
+	D  string `json:"d,omitempty"`
+	P  string `json:"p,omitempty"`
+	Q  string `json:"q,omitempty"`
+	DP string `json:"dp,omitempty"`
+	DQ string `json:"dq,omitempty"`
+	QI string `json:"qi,omitempty"`
+
+	// EC and OKP members (the private member is D)
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+
+	// Symmetric key member
+	K string `json:"k,omitempty"`
+}
+
+// NewJWK creates a JWK from the provided public, private or symmetric key.
+// Supported key types are *rsa.PublicKey, *rsa.PrivateKey, *ecdsa.PublicKey,
+// *ecdsa.PrivateKey, ed25519.PublicKey, ed25519.PrivateKey and []byte.
+func NewJWK(key any) (*JWK, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return &JWK{
+			Kty: KeyTypeRSA,
+			N:   encodeBigInt(k.N),
+			E:   encodeBigInt(big.NewInt(int64(k.E))),
+		}, nil
+	case *rsa.PrivateKey:
+		if len(k.Primes) != 2 {
+			return nil, ErrJWKUnsupportedKeyType
+		}
+		// The CRT values are computed here rather than by Precompute,
+		// which would modify a key the caller may be sharing.
+		p, q := k.Primes[0], k.Primes[1]
+		one := big.NewInt(1)
+		dp := new(big.Int).Mod(k.D, new(big.Int).Sub(p, one))
+		dq := new(big.Int).Mod(k.D, new(big.Int).Sub(q, one))
+		qi := new(big.Int).ModInverse(q, p)
+		if qi == nil {
+			return nil, ErrJWKUnsupportedKeyType
+		}
+		return &JWK{
+			Kty: KeyTypeRSA,
+			N:   encodeBigInt(k.N),
+			E:   encodeBigInt(big.NewInt(int64(k.E))),
+			D:   encodeBigInt(k.D),
+			P:   encodeBigInt(p),
+			Q:   encodeBigInt(q),
+			DP:  encodeBigInt(dp),
+			DQ:  encodeBigInt(dq),
+			QI:  encodeBigInt(qi),
+		}, nil
+	case *ecdsa.PublicKey:
+		crv, size, err := curveParams(k.Curve)
+		if err != nil {
+			return nil, err
+		}
+		return &JWK{
+			Kty: KeyTypeEC,
+			Crv: crv,
+			X:   encodeFixed(k.X, size),
+			Y:   encodeFixed(k.Y, size),
+		}, nil
+	case *ecdsa.PrivateKey:
+		jwk, err := NewJWK(&k.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		_, size, _ := curveParams(k.Curve)
+		jwk.D = encodeFixed(k.D, size)
+		return jwk, nil
+	case ed25519.PublicKey:
+		return &JWK{
+			Kty: KeyTypeOKP,
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(k),
+		}, nil
+	case ed25519.PrivateKey:
+		return &JWK{
+			Kty: KeyTypeOKP,
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(k.Public().(ed25519.PublicKey)),
+			D:   base64.RawURLEncoding.EncodeToString(k.Seed()),
+		}, nil
+	case []byte:
+		return &JWK{
+			Kty: KeyTypeOct,
+			K:   base64.RawURLEncoding.EncodeToString(k),
+		}, nil
+	}
+	return nil, ErrJWKUnsupportedKeyType
+}
+
+// ParseJWK parses a single JSON encoded JWK.
+func ParseJWK(data []byte) (*JWK, error) {
+	var jwk JWK
+	err := json.Unmarshal(data, &jwk)
+	if err != nil {
+		return nil, ErrJWKMalformed
+	}
+	if jwk.Kty == "" {
+		return nil, ErrJWKMalformed
+	}
+	return &jwk, nil
+}
+
+// IsPrivate reports whether the JWK contains private (or symmetric) key
+// material.
+func (j *JWK) IsPrivate() bool {
+	return j.D != "" || j.K != ""
+}
+
+// Public returns a copy of the JWK with all the private key members
+// removed. It returns nil for symmetric keys, which have no public part.
+func (j *JWK) Public() *JWK {
+	if j.Kty == KeyTypeOct {
+		return nil
+	}
+	return &JWK{
+		Kty:    j.Kty,
+		Use:    j.Use,
+		KeyOps: j.KeyOps,
+		Alg:    j.Alg,
+		Kid:    j.Kid,
+		N:      j.N,
+		E:      j.E,
+		Crv:    j.Crv,
+		X:      j.X,
+		Y:      j.Y,
+	}
+}
+
+// Key decodes the JWK and returns the key it contains. If the JWK has
+// private members, the private key is returned, otherwise the public key
+// is returned. Symmetric keys are returned as a []byte.
+func (j *JWK) Key() (any, error) {
+	if j.IsPrivate() {
+		return j.PrivateKey()
+	}
+	return j.PublicKey()
+}
+
+// PublicKey decodes and returns the public key held by the JWK.
+func (j *JWK) PublicKey() (crypto.PublicKey, error) {
+	switch j.Kty {
+	case KeyTypeRSA:
+		n, err := decodeBigInt(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(j.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, ErrJWKMalformed
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case KeyTypeEC:
+		curve, err := curveByName(j.Crv)
+		if err != nil {
+			return nil, err
+		}
+		x, err := decodeBigInt(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, ErrJWKMalformed
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case KeyTypeOKP:
+		if j.Crv != "Ed25519" {
+			return nil, ErrJWKUnsupportedKeyType
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, ErrJWKMalformed
+		}
+		return ed25519.PublicKey(x), nil
+	case KeyTypeOct:
+		return j.symmetricKey()
+	}
+	return nil, ErrJWKUnsupportedKeyType
+}
+
+// PrivateKey decodes and returns the private key held by the JWK.
+func (j *JWK) PrivateKey() (crypto.PrivateKey, error) {
+	if !j.IsPrivate() {
+		return nil, ErrJWKNotPrivate
+	}
+	switch j.Kty {
+	case KeyTypeRSA:
+		pub, err := j.PublicKey()
+		if err != nil {
+			return nil, err
+		}
+		key := &rsa.PrivateKey{PublicKey: *pub.(*rsa.PublicKey)}
+		key.D, err = decodeBigInt(j.D)
+		if err != nil {
+			return nil, err
+		}
+		p, err := decodeBigInt(j.P)
+		if err != nil {
+			return nil, err
+		}
+		q, err := decodeBigInt(j.Q)
+		if err != nil {
+			return nil, err
+		}
+		key.Primes = []*big.Int{p, q}
+		if err = key.Validate(); err != nil {
+			return nil, ErrJWKMalformed
+		}
+		key.Precompute()
+		return key, nil
+	case KeyTypeEC:
+		pub, err := j.PublicKey()
+		if err != nil {
+			return nil, err
+		}
+		d, err := decodeBigInt(j.D)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PrivateKey{PublicKey: *pub.(*ecdsa.PublicKey), D: d}, nil
+	case KeyTypeOKP:
+		if j.Crv != "Ed25519" {
+			return nil, ErrJWKUnsupportedKeyType
+		}
+		d, err := base64.RawURLEncoding.DecodeString(j.D)
+		if err != nil || len(d) != ed25519.SeedSize {
+			return nil, ErrJWKMalformed
+		}
+		return ed25519.NewKeyFromSeed(d), nil
+	case KeyTypeOct:
+		return j.symmetricKey()
+	}
+	return nil, ErrJWKUnsupportedKeyType
+}
+
+func (j *JWK) symmetricKey() ([]byte, error) {
+	k, err := base64.RawURLEncoding.DecodeString(j.K)
+	if err != nil || len(k) == 0 {
+		return nil, ErrJWKMalformed
+	}
+	return k, nil
+}
+
+// Thumbprint computes the RFC 7638 thumbprint of the JWK using the
+// provided hash. The thumbprint only covers the required public members
+// of the key, so the public and private form of a key share the same
+// thumbprint.
+func (j *JWK) Thumbprint(hash crypto.Hash) ([]byte, error) {
+	if !hash.Available() {
+		return nil, ErrHashUnavailable
+	}
+	// The members must be in lexicographic order, which is what
+	// encoding/json does when marshaling a map.
+	var m map[string]string
+	switch j.Kty {
+	case KeyTypeRSA:
+		m = map[string]string{"e": j.E, "kty": j.Kty, "n": j.N}
+	case KeyTypeEC:
+		m = map[string]string{"crv": j.Crv, "kty": j.Kty, "x": j.X, "y": j.Y}
+	case KeyTypeOKP:
+		m = map[string]string{"crv": j.Crv, "kty": j.Kty, "x": j.X}
+	case KeyTypeOct:
+		m = map[string]string{"k": j.K, "kty": j.Kty}
+	default:
+		return nil, ErrJWKUnsupportedKeyType
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	hasher := hash.New()
+	hasher.Write(b)
+	return hasher.Sum(nil), nil
+}
+
+// JWKSet represents a JSON Web Key Set as described in RFC 7517.
+type JWKSet struct {
+	Keys []*JWK `json:"keys"`
+}
+
+// ParseJWKSet parses a JSON encoded JWK set.
+func ParseJWKSet(data []byte) (*JWKSet, error) {
+	var set JWKSet
+	err := json.Unmarshal(data, &set)
+	if err != nil {
+		return nil, ErrJWKMalformed
+	}
+	return &set, nil
+}
+
+// Add appends the provided key to the set.
+func (s *JWKSet) Add(jwk *JWK) {
+	s.Keys = append(s.Keys, jwk)
+}
+
+// Key returns the first key in the set with a matching key id, or nil if
+// no key in the set has a matching key id.
+func (s *JWKSet) Key(kid string) *JWK {
+	for _, jwk := range s.Keys {
+		if jwk.Kid == kid {
+			return jwk
+		}
+	}
+	return nil
+}
+
+// Public returns a new set holding the public form of every asymmetric
+// key in the set. Symmetric keys are never published, so they are left
+// out.
+func (s *JWKSet) Public() *JWKSet {
+	set := &JWKSet{Keys: make([]*JWK, 0, len(s.Keys))}
+	for _, jwk := range s.Keys {
+		if pub := jwk.Public(); pub != nil {
+			set.Add(pub)
+		}
+	}
+	return set
+}
+
+// JWK returns the JWK representation of the private key in the key pair.
+func (k *KeyPair) JWK() (*JWK, error) {
+	return NewJWK(k.PrivateKey)
+}
+
+// PublicJWK returns the JWK representation of the public key in the key
+// pair.
+func (k *KeyPair) PublicJWK() (*JWK, error) {
+	return NewJWK(k.PublicKey)
+}
+
+// KeyPairFromJWK creates a key pair from a JWK. If the JWK only holds a
+// public key, the private key of the returned key pair will be nil.
+func KeyPairFromJWK(jwk *JWK) (*KeyPair, error) {
+	pub, err := jwk.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	kp := &KeyPair{PublicKey: pub}
+	if jwk.IsPrivate() {
+		kp.PrivateKey, err = jwk.PrivateKey()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return kp, nil
+}
+
+func encodeBigInt(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.Bytes())
+}
+
+func encodeFixed(n *big.Int, size int) string {
+	return base64.RawURLEncoding.EncodeToString(n.FillBytes(make([]byte, size)))
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, ErrJWKMalformed
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func curveParams(curve elliptic.Curve) (string, int, error) {
+	switch curve {
+	case elliptic.P256():
+		return "P-256", 32, nil
+	case elliptic.P384():
+		return "P-384", 48, nil
+	case elliptic.P521():
+		return "P-521", 66, nil
+	}
+	return "", 0, ErrJWKUnsupportedKeyType
+}
+
+func curveByName(name string) (elliptic.Curve, error) {
+	switch name {
+	case "P-256":
+		return elliptic.P256(), nil
+	case "P-384":
+		return elliptic.P384(), nil
+	case "P-521":
+		return elliptic.P521(), nil
+	}
+	return nil, ErrJWKUnsupportedKeyType
+}
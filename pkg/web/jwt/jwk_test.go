@@ -0,0 +1,164 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestJWK_RoundTrip(t *testing.T) {
+	methods := []SigningMethod{RS256, ES256, ES384, ES512, EdDSA}
+	for _, method := range methods {
+		keys := method.GenerateKeyPair()
+
+		// Encode the private key
+		jwk, err := keys.JWK()
+		if err != nil {
+			t.Fatalf("[%s] error encoding jwk: %v", method.Name(), err)
+		}
+		b, err := json.Marshal(jwk)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Decode the private key
+		jwk, err = ParseJWK(b)
+		if err != nil {
+			t.Fatalf("[%s] error parsing jwk: %v", method.Name(), err)
+		}
+		kp, err := KeyPairFromJWK(jwk)
+		if err != nil {
+			t.Fatalf("[%s] error decoding jwk: %v", method.Name(), err)
+		}
+
+		// Sign using the decoded private key, and verify using the original
+		// public key as well as the decoded public form of the key.
+		tok, err := NewToken(method, nil, kp.PrivateKey)
+		if err != nil {
+			t.Fatalf("[%s] error signing token: %v", method.Name(), err)
+		}
+		pub, err := jwk.Public().PublicKey()
+		if err != nil {
+			t.Fatalf("[%s] error decoding public jwk: %v", method.Name(), err)
+		}
+		for _, key := range []crypto.PublicKey{keys.PublicKey, kp.PublicKey, pub} {
+			err = method.Verify(tok.SigningSection(), tok.Signature(), key)
+			if err != nil {
+				t.Errorf("[%s] error verifying token: %v", method.Name(), err)
+			}
+		}
+	}
+}
+
+func TestJWK_RSAPrecomputed(t *testing.T) {
+	key := RS256.GenerateKeyPair().PrivateKey.(*rsa.PrivateKey)
+
+	// A key without the CRT values is left untouched, and gets the same
+	// values in its JWK
+	bare := &rsa.PrivateKey{PublicKey: key.PublicKey, D: key.D, Primes: key.Primes}
+	jwk, err := NewJWK(bare)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bare.Precomputed.Dp != nil {
+		t.Errorf("expected the key not to be modified")
+	}
+	assert(t, encodeBigInt(key.Precomputed.Dp), jwk.DP)
+	assert(t, encodeBigInt(key.Precomputed.Dq), jwk.DQ)
+	assert(t, encodeBigInt(key.Precomputed.Qinv), jwk.QI)
+}
+
+func TestJWK_Symmetric(t *testing.T) {
+	jwk, err := NewJWK(hmacTestKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jwk.Kty != KeyTypeOct || jwk.Public() != nil {
+		t.Errorf("expected a symmetric key with no public part")
+	}
+	key, err := jwk.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err := NewToken(HS256, nil, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = HS256.Verify(tok.SigningSection(), tok.Signature(), hmacTestKey)
+	if err != nil {
+		t.Errorf("error verifying token: %v", err)
+	}
+}
+
+func TestJWK_Thumbprint(t *testing.T) {
+	// Example key and thumbprint from RFC 7638, section 3.1
+	jwk := &JWK{
+		Kty: "RSA",
+		N: "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMs" +
+			"tn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91" +
+			"CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		E:   "AQAB",
+		Alg: "RS256",
+		Kid: "2011-04-29",
+	}
+	b, err := jwk.Thumbprint(crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", base64.RawURLEncoding.EncodeToString(b))
+}
+
+func TestTokenManager_JWKSHandler(t *testing.T) {
+	keys := ES256.GenerateKeyPair()
+	tm := NewTokenManager(ES256, keys)
+
+	srv := httptest.NewServer(tm.JWKSHandler())
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + JWKSPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status: %d", res.StatusCode)
+	}
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := ParseJWKSet(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Keys) != 1 {
+		t.Fatalf("expected 1 key, got %d", len(set.Keys))
+	}
+	jwk := set.Keys[0]
+	if jwk.IsPrivate() {
+		t.Errorf("published key contains private key material")
+	}
+	kid, _ := KeyID(keys.PublicKey)
+	if set.Key(kid) == nil {
+		t.Errorf("expected key with kid %q in the set", kid)
+	}
+
+	// Verify a token issued by the manager using the published key
+	tok, err := tm.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := jwk.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ValidateToken(tok, ES256, pub)
+	if err != nil {
+		t.Errorf("error validating token using published key: %v", err)
+	}
+}
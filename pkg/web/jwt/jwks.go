@@ -0,0 +1,76 @@
+package jwt
+
+import (
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+)
+
+// JWKSPath is the well-known path that a JWK set is usually published at.
+const JWKSPath = "/.well-known/jwks.json"
+
+// KeyID returns a key id for the provided public key. The key id is the
+// base64url encoded RFC 7638 SHA-256 thumbprint of the key.
+func KeyID(key crypto.PublicKey) (string, error) {
+	jwk, err := NewJWK(key)
+	if err != nil {
+		return "", err
+	}
+	b, err := jwk.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// JWKSet returns a JWK set containing the public keys used by the manager
+// to verify tokens. Symmetric keys are never published, so for the HMAC
+// signing methods the returned set is empty.
+func (m *TokenManager) JWKSet() (*JWKSet, error) {
+	return m.ring.jwkSet(m.now())
+}
+
+// JWKSHandler returns a http.Handler that publishes the public keys of
+// the manager as a JWK set. It is intended to be mounted at JWKSPath.
+func (m *TokenManager) JWKSHandler() http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			set, err := m.JWKSet()
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			serveJWKSet(w, r, set)
+		},
+	)
+}
+
+// JWKSetHandler returns a http.Handler that publishes the public form of
+// the keys in the provided set.
+func JWKSetHandler(set *JWKSet) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			serveJWKSet(w, r, set.Public())
+		},
+	)
+}
+
+func serveJWKSet(w http.ResponseWriter, r *http.Request, set *JWKSet) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	b, err := json.Marshal(set)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		w.Write(b)
+	}
+}
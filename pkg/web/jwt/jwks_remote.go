@@ -0,0 +1,279 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxJWKSBytes is the largest JWK set document that will be read from a
+// remote key set endpoint.
+const maxJWKSBytes = 1 << 20
+
+// RemoteKeySetOptions holds the optional configuration for a RemoteKeySet.
+// Any fields left as their zero value will use the defaults.
+type RemoteKeySetOptions struct {
+
+	// Client is the http client used to fetch the key set. It defaults
+	// to a client with a 10 second timeout.
+	Client *http.Client
+
+	// RefreshInterval is how often the key set is refreshed in the
+	// background, once Start has been called. It defaults to 1 hour.
+	RefreshInterval time.Duration
+
+	// MinRefreshInterval is the minimum amount of time between two
+	// refreshes that are triggered by a token carrying an unknown kid. It
+	// prevents tokens with made up key ids from hammering the remote
+	// endpoint. It defaults to 1 minute.
+	MinRefreshInterval time.Duration
+
+	// KeyTTL is how long a fetched key is cached for. If the key set
+	// cannot be refreshed before a key expires, the key will no longer be
+	// used. It defaults to 24 hours.
+	KeyTTL time.Duration
+
+	// Clock is the source of the current time, which decides when keys
+	// expire and when a refresh is allowed; if it is left as nil,
+	// SystemClock will be used.
+	Clock Clock
+}
+
+// cachedKey is a key held by the RemoteKeySet cache.
+type cachedKey struct {
+	jwk     *JWK
+	key     crypto.PublicKey
+	expires time.Time
+}
+
+// RemoteKeySet is a verification key source backed by a JWK set that is
+// published by a remote service. The keys are cached by key id and are
+// refreshed in the background, as well as on demand whenever a token
+// carrying an unknown key id shows up. Its KeyFunc method can be used
+// with Validator.ValidateTokenWithKeyFunc.
+type RemoteKeySet struct {
+	url  string
+	opts RemoteKeySetOptions
+
+	mu          sync.RWMutex
+	keys        map[string]*cachedKey
+	lastRefresh time.Time
+
+	// refreshMu makes sure only one refresh is in flight at any time.
+	refreshMu sync.Mutex
+
+	ticker     *time.Ticker
+	tickerStop chan bool
+	isRunning  bool
+}
+
+// NewRemoteKeySet initializes and returns a new RemoteKeySet for the JWK
+// set published at url. No keys are fetched until the first time a key
+// is requested, or until Refresh or Start is called. The options may be
+// nil.
+func NewRemoteKeySet(url string, opts *RemoteKeySetOptions) *RemoteKeySet {
+	s := &RemoteKeySet{
+		url:  url,
+		keys: make(map[string]*cachedKey),
+	}
+	if opts != nil {
+		s.opts = *opts
+	}
+	if s.opts.Client == nil {
+		s.opts.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if s.opts.RefreshInterval <= 0 {
+		s.opts.RefreshInterval = time.Hour
+	}
+	if s.opts.MinRefreshInterval <= 0 {
+		s.opts.MinRefreshInterval = time.Minute
+	}
+	if s.opts.KeyTTL <= 0 {
+		s.opts.KeyTTL = 24 * time.Hour
+	}
+	return s
+}
+
+func (s *RemoteKeySet) now() time.Time {
+	return clockNow(s.opts.Clock)
+}
+
+// Refresh fetches the remote key set and replaces the cached keys with
+// the keys it contains. Keys that cannot be decoded, or that are not
+// meant for signature verification are skipped.
+func (s *RemoteKeySet) Refresh(ctx context.Context) error {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+	return s.refresh(ctx)
+}
+
+func (s *RemoteKeySet) refresh(ctx context.Context) error {
+	// Record the attempt up front, so a failing endpoint is rate limited
+	// just like a working one.
+	s.mu.Lock()
+	s.lastRefresh = s.now()
+	s.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return errors.Join(ErrJWKSFetch, err)
+	}
+	req.Header.Set("Accept", "application/json")
+	res, err := s.opts.Client.Do(req)
+	if err != nil {
+		return errors.Join(ErrJWKSFetch, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return errors.Join(ErrJWKSFetch, fmt.Errorf("unexpected status code %d", res.StatusCode))
+	}
+	b, err := io.ReadAll(io.LimitReader(res.Body, maxJWKSBytes))
+	if err != nil {
+		return errors.Join(ErrJWKSFetch, err)
+	}
+	set, err := ParseJWKSet(b)
+	if err != nil {
+		return errors.Join(ErrJWKSFetch, err)
+	}
+
+	// Decode the keys
+	expires := s.now().Add(s.opts.KeyTTL)
+	keys := make(map[string]*cachedKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if jwk.Kty == KeyTypeOct {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = &cachedKey{
+			jwk:     jwk.Public(),
+			key:     key,
+			expires: expires,
+		}
+	}
+
+	// Swap out the cached keys
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+	return nil
+}
+
+// lookup returns the cached key with the matching key id. If kid is empty
+// and the cache only holds a single key, that key is returned.
+func (s *RemoteKeySet) lookup(kid string) (*cachedKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k, found := s.keys[kid]
+	if !found && kid == "" && len(s.keys) == 1 {
+		for _, k = range s.keys {
+			found = true
+		}
+	}
+	if !found || !s.now().Before(k.expires) {
+		return nil, false
+	}
+	return k, true
+}
+
+// Key returns the public JWK with the matching key id. If the key is not
+// in the cache, the key set is refreshed, unless the last refresh happened
+// less than MinRefreshInterval ago.
+func (s *RemoteKeySet) Key(ctx context.Context, kid string) (*JWK, error) {
+	k, err := s.key(ctx, kid)
+	if err != nil {
+		return nil, err
+	}
+	return k.jwk, nil
+}
+
+func (s *RemoteKeySet) key(ctx context.Context, kid string) (*cachedKey, error) {
+	if k, found := s.lookup(kid); found {
+		return k, nil
+	}
+
+	// We have a miss, so refresh unless we are rate limited.
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	// Another caller may have refreshed while we were waiting.
+	if k, found := s.lookup(kid); found {
+		return k, nil
+	}
+	s.mu.RLock()
+	since := s.now().Sub(s.lastRefresh)
+	s.mu.RUnlock()
+	if since < s.opts.MinRefreshInterval {
+		return nil, errors.Join(ErrKeyNotFound, ErrJWKSRateLimit)
+	}
+	if err := s.refresh(ctx); err != nil {
+		return nil, err
+	}
+	if k, found := s.lookup(kid); found {
+		return k, nil
+	}
+	return nil, ErrKeyNotFound
+}
+
+// KeyFunc looks up the verification key for the token using the kid
+// header of the token. If the matching key specifies an algorithm, it
+// must match the alg header of the token.
+func (s *RemoteKeySet) KeyFunc(token *Token) (crypto.PublicKey, error) {
+	k, err := s.key(context.Background(), token.Header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if k.jwk.Alg != "" && k.jwk.Alg != token.Header.Alg {
+		return nil, ErrTokenUnverifiable
+	}
+	return k.key, nil
+}
+
+// Start starts refreshing the key set in the background at the configured
+// RefreshInterval. The key set is also refreshed right away.
+func (s *RemoteKeySet) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// already running
+	if s.isRunning {
+		return
+	}
+	s.ticker = time.NewTicker(s.opts.RefreshInterval)
+	s.tickerStop = make(chan bool)
+	// spawn a new goroutine
+	go func(ticker *time.Ticker, stop chan bool) {
+		s.Refresh(context.Background())
+		for {
+			select {
+			case <-ticker.C: // a new tick
+				s.Refresh(context.Background())
+			case <-stop: // stopping the ticker
+				return
+			}
+		}
+	}(s.ticker, s.tickerStop)
+	s.isRunning = true
+}
+
+// Stop stops the background refresh of the key set.
+func (s *RemoteKeySet) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// already stopped
+	if !s.isRunning {
+		return
+	}
+	s.ticker.Stop()
+	close(s.tickerStop)
+	s.isRunning = false
+}
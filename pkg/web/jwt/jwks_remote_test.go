@@ -0,0 +1,179 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newRemoteKeySetTestServer(t *testing.T, tm *TokenManager) (*httptest.Server, *int32) {
+	t.Helper()
+	var hits int32
+	handler := tm.JWKSHandler()
+	srv := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&hits, 1)
+				handler.ServeHTTP(w, r)
+			},
+		),
+	)
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func TestRemoteKeySet_KeyFunc(t *testing.T) {
+	ring := NewKeyRing(RS256, time.Hour)
+	if _, err := ring.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	issuer := NewTokenManagerWithKeyRing(ring)
+	srv, hits := newRemoteKeySetTestServer(t, issuer)
+
+	keys := NewRemoteKeySet(srv.URL+JWKSPath, &RemoteKeySetOptions{MinRefreshInterval: time.Hour})
+	validator := &Validator{Method: RS256}
+
+	// The first validation fetches the key set
+	t1, err := issuer.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = validator.ValidateTokenWithKeyFunc(t1, keys.KeyFunc); err != nil {
+		t.Fatalf("error validating token: %v", err)
+	}
+	if _, err = validator.ValidateTokenWithKeyFunc(t1, keys.KeyFunc); err != nil {
+		t.Fatalf("error validating token: %v", err)
+	}
+	if n := atomic.LoadInt32(hits); n != 1 {
+		t.Errorf("expected the key set to be fetched once, got %d", n)
+	}
+
+	// After a rotation the new kid is unknown, but the refresh is
+	// rate limited so validation should fail without a new fetch.
+	if _, err = issuer.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	t2, err := issuer.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = validator.ValidateTokenWithKeyFunc(t2, keys.KeyFunc)
+	if !errors.Is(err, ErrJWKSRateLimit) {
+		t.Errorf("expected %v, got %v", ErrJWKSRateLimit, err)
+	}
+	if n := atomic.LoadInt32(hits); n != 1 {
+		t.Errorf("expected the key set to be fetched once, got %d", n)
+	}
+}
+
+func TestRemoteKeySet_RefreshOnMiss(t *testing.T) {
+	ring := NewKeyRing(EdDSA, time.Hour)
+	if _, err := ring.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	issuer := NewTokenManagerWithKeyRing(ring)
+	srv, hits := newRemoteKeySetTestServer(t, issuer)
+
+	keys := NewRemoteKeySet(srv.URL, &RemoteKeySetOptions{MinRefreshInterval: time.Nanosecond})
+	if err := keys.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotate the key, the unknown kid should trigger a refresh
+	kid, err := issuer.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err := issuer.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	validator := &Validator{Method: EdDSA}
+	if _, err = validator.ValidateTokenWithKeyFunc(tok, keys.KeyFunc); err != nil {
+		t.Fatalf("error validating token: %v", err)
+	}
+	if n := atomic.LoadInt32(hits); n != 2 {
+		t.Errorf("expected the key set to be fetched twice, got %d", n)
+	}
+	jwk, err := keys.Key(context.Background(), kid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, "EdDSA", jwk.Alg)
+
+	// A made up kid should not be found
+	_, err = keys.Key(context.Background(), "made-up")
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("expected %v, got %v", ErrKeyNotFound, err)
+	}
+}
+
+func TestRemoteKeySet_Clock(t *testing.T) {
+	issuer := NewTokenManager(ES256, ES256.GenerateKeyPair())
+	srv, hits := newRemoteKeySetTestServer(t, issuer)
+	kid, _, _, err := issuer.KeyRing().Current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := NewFakeClock(time.Now())
+	keys := NewRemoteKeySet(
+		srv.URL, &RemoteKeySetOptions{
+			MinRefreshInterval: time.Minute,
+			KeyTTL:             time.Hour,
+			Clock:              clock,
+		},
+	)
+	fetch := func(kid string, want int32) {
+		t.Helper()
+		keys.Key(context.Background(), kid)
+		if n := atomic.LoadInt32(hits); n != want {
+			t.Errorf("expected the key set to be fetched %d times, got %d", want, n)
+		}
+	}
+
+	// Refreshes on a miss are rate limited according to the fake clock
+	fetch(kid, 1)
+	clock.Advance(30 * time.Second)
+	fetch("made-up", 1)
+	clock.Advance(30 * time.Second)
+	fetch("made-up", 2)
+
+	// And so is the lifetime of the cached keys
+	clock.Advance(time.Hour - time.Second)
+	fetch(kid, 2)
+	clock.Advance(time.Second)
+	fetch(kid, 3)
+}
+
+func TestRemoteKeySet_Start(t *testing.T) {
+	ring := NewKeyRing(ES384, time.Hour)
+	if _, err := ring.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	issuer := NewTokenManagerWithKeyRing(ring)
+	srv, hits := newRemoteKeySetTestServer(t, issuer)
+
+	keys := NewRemoteKeySet(srv.URL, &RemoteKeySetOptions{RefreshInterval: 10 * time.Millisecond})
+	keys.Start()
+	time.Sleep(55 * time.Millisecond)
+	keys.Stop()
+
+	if n := atomic.LoadInt32(hits); n < 3 {
+		t.Errorf("expected the key set to be refreshed in the background, got %d fetches", n)
+	}
+}
+
+func TestRemoteKeySet_FetchError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	keys := NewRemoteKeySet(srv.URL, nil)
+	err := keys.Refresh(context.Background())
+	if !errors.Is(err, ErrJWKSFetch) {
+		t.Errorf("expected %v, got %v", ErrJWKSFetch, err)
+	}
+}
@@ -0,0 +1,175 @@
+package jwt
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+)
+
+// JWSOptions holds the options for signing an arbitrary payload using
+// SignPayload.
+type JWSOptions struct {
+
+	// Header holds any additional header fields. The alg field is always
+	// set using the signing method, and the b64 and crit fields are set
+	// when Unencoded is true.
+	Header TokenHeader
+
+	// Detached leaves the payload out of the serialized JWS, producing a
+	// "header..signature" value. The payload must be transmitted
+	// separately, and handed to VerifyPayload.
+	Detached bool
+
+	// Unencoded signs the payload as-is instead of base64url encoding it
+	// first, as described in RFC 7797. If the payload is not detached, it
+	// must not contain a '.' character.
+	Unencoded bool
+}
+
+// SignPayload signs an arbitrary payload (e.g. a webhook body) using the
+// provided signing method, and returns the JWS in the compact
+// serialization. The options may be nil.
+func SignPayload(method SigningMethod, payload []byte, key crypto.PrivateKey, opts *JWSOptions) ([]byte, error) {
+	if opts == nil {
+		opts = &JWSOptions{}
+	}
+	hdr := opts.Header
+	hdr.Alg = method.Name()
+	if opts.Unencoded {
+		if !opts.Detached && bytes.IndexByte(payload, dot) != -1 {
+			return nil, ErrJWSInvalidPayload
+		}
+		b64 := false
+		hdr.B64 = &b64
+		if !containsString(hdr.Crit, "b64") {
+			hdr.Crit = append(append([]string{}, hdr.Crit...), "b64")
+		}
+	}
+	// create and encode the header
+	dat, err := json.Marshal(hdr)
+	if err != nil {
+		return nil, err
+	}
+	header := Base64Encode(dat)
+	// create signing input
+	if !opts.Unencoded {
+		payload = Base64Encode(payload)
+	}
+	signingInput := bytes.Join([][]byte{header, payload}, []byte{dot})
+	// create and encode the signature
+	sig, err := method.Sign(signingInput, key)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Detached {
+		return bytes.Join([][]byte{header, nil, sig}, []byte{dot}), nil
+	}
+	return bytes.Join([][]byte{signingInput, sig}, []byte{dot}), nil
+}
+
+// VerifyPayload verifies a JWS in the compact serialization produced by
+// SignPayload (or any other RFC 7515 implementation) and returns the
+// header along with the payload. If the JWS has a detached payload, the
+// payload must be provided. If it does not, the payload should be nil; a
+// payload that is provided anyway must match the attached one. The alg
+// header must match the provided signing method.
+func VerifyPayload(method SigningMethod, jws []byte, payload []byte, key crypto.PublicKey) (*TokenHeader, []byte, error) {
+	i := bytes.IndexByte(jws, dot)
+	j := bytes.LastIndexByte(jws, dot)
+	if i == -1 || i == j {
+		return nil, nil, ErrTokenMalformed
+	}
+	header, body, sig := jws[:i], jws[i+1:j], jws[j+1:]
+
+	// Parse the header
+	var hdr TokenHeader
+	dat, err := base64Decode(header)
+	if err != nil {
+		return nil, nil, errors.Join(ErrTokenMalformed, err)
+	}
+	err = json.Unmarshal(dat, &hdr)
+	if err != nil {
+		return nil, nil, errors.Join(ErrTokenMalformed, err)
+	}
+	if hdr.Alg != method.Name() {
+		return nil, nil, ErrTokenUnverifiable
+	}
+	unencoded, err := checkPayloadHeader(&hdr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Figure out the payload as it appears in the signing input
+	detached := len(body) == 0
+	if detached {
+		if payload == nil {
+			return nil, nil, ErrJWSPayloadRequired
+		}
+		body = payload
+		if !unencoded {
+			body = Base64Encode(payload)
+		}
+	} else if payload != nil {
+		// The caller is checking a signature over its own content, so an
+		// attached payload signed by the same key is not good enough
+		want := payload
+		if !unencoded {
+			want = Base64Encode(payload)
+		}
+		if subtle.ConstantTimeCompare(body, want) != 1 {
+			return nil, nil, ErrJWSPayloadMismatch
+		}
+	}
+
+	// Verify the signature
+	signingInput := make([]byte, 0, len(header)+1+len(body))
+	signingInput = append(signingInput, header...)
+	signingInput = append(signingInput, dot)
+	signingInput = append(signingInput, body...)
+	err = method.Verify(signingInput, sig, key)
+	if err != nil {
+		return nil, nil, errors.Join(err, ErrTokenSignatureInvalid)
+	}
+
+	// Return the payload
+	if detached {
+		return &hdr, payload, nil
+	}
+	if unencoded {
+		return &hdr, body[:len(body):len(body)], nil
+	}
+	payload, err = base64Decode(body)
+	if err != nil {
+		return nil, nil, errors.Join(ErrTokenMalformed, err)
+	}
+	return &hdr, payload, nil
+}
+
+// checkPayloadHeader checks the b64 and crit header fields, and reports
+// whether the payload is unencoded.
+func checkPayloadHeader(hdr *TokenHeader) (bool, error) {
+	for _, name := range hdr.Crit {
+		if name != "b64" {
+			return false, ErrJWSUnsupportedCrit
+		}
+	}
+	if hdr.B64 == nil {
+		return false, nil
+	}
+	// RFC 7797, section 6: b64 must be listed as critical
+	if !containsString(hdr.Crit, "b64") {
+		return false, ErrTokenMalformed
+	}
+	return !*hdr.B64, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
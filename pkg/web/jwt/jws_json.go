@@ -0,0 +1,210 @@
+package jwt
+
+import (
+	"crypto"
+	"encoding/json"
+	"errors"
+)
+
+// JWSSigner holds everything needed to add a single signature to a JWS in
+// the JSON serialization.
+type JWSSigner struct {
+
+	// Method is the signing method used to create the signature.
+	Method SigningMethod
+
+	// Key is the private key used to create the signature.
+	Key crypto.PrivateKey
+
+	// Header holds any additional protected header fields, such as the
+	// key id. The alg field is always set using the signing method.
+	Header TokenHeader
+
+	// Unprotected holds the unprotected header fields, which are not
+	// covered by the signature.
+	Unprotected map[string]any
+}
+
+// JWSSignature is a single signature of a JWS in the JSON serialization.
+type JWSSignature struct {
+	Protected string         `json:"protected,omitempty"`
+	Header    map[string]any `json:"header,omitempty"`
+	Signature string         `json:"signature"`
+}
+
+// JSONWebSignature is a JWS in the general JSON serialization described in
+// RFC 7515, section 7.2.1. It can carry any number of signatures over the
+// same payload.
+type JSONWebSignature struct {
+	Payload    string         `json:"payload"`
+	Signatures []JWSSignature `json:"signatures"`
+}
+
+// flattenedJWS is a JWS in the flattened JSON serialization described in
+// RFC 7515, section 7.2.2.
+type flattenedJWS struct {
+	Payload string `json:"payload"`
+	JWSSignature
+}
+
+// SignJSON signs the payload once for every signer, and returns the JWS
+// in the general JSON serialization.
+func SignJSON(payload []byte, signers ...JWSSigner) (*JSONWebSignature, error) {
+	if len(signers) == 0 {
+		return nil, ErrJWSNoSignatures
+	}
+	jws := &JSONWebSignature{
+		Payload:    string(Base64Encode(payload)),
+		Signatures: make([]JWSSignature, 0, len(signers)),
+	}
+	for _, signer := range signers {
+		hdr := signer.Header
+		hdr.Alg = signer.Method.Name()
+		if len(hdr.Crit) > 0 || hdr.B64 != nil {
+			return nil, ErrJWSUnsupportedCrit
+		}
+		dat, err := json.Marshal(hdr)
+		if err != nil {
+			return nil, err
+		}
+		protected := string(Base64Encode(dat))
+		sig, err := signer.Method.Sign([]byte(protected+"."+jws.Payload), signer.Key)
+		if err != nil {
+			return nil, err
+		}
+		jws.Signatures = append(
+			jws.Signatures, JWSSignature{
+				Protected: protected,
+				Header:    signer.Unprotected,
+				Signature: string(sig),
+			},
+		)
+	}
+	return jws, nil
+}
+
+// ParseJSON parses a JWS in either the general or the flattened JSON
+// serialization. A flattened JWS is returned as a general JWS with a
+// single signature.
+func ParseJSON(data []byte) (*JSONWebSignature, error) {
+	var raw map[string]json.RawMessage
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, errors.Join(ErrTokenMalformed, err)
+	}
+	var jws JSONWebSignature
+	if _, general := raw["signatures"]; general {
+		err = json.Unmarshal(data, &jws)
+		if err != nil {
+			return nil, errors.Join(ErrTokenMalformed, err)
+		}
+	} else {
+		var flat flattenedJWS
+		err = json.Unmarshal(data, &flat)
+		if err != nil {
+			return nil, errors.Join(ErrTokenMalformed, err)
+		}
+		jws.Payload = flat.Payload
+		jws.Signatures = []JWSSignature{flat.JWSSignature}
+	}
+	if len(jws.Signatures) == 0 {
+		return nil, ErrJWSNoSignatures
+	}
+	return &jws, nil
+}
+
+// MarshalJSON marshals the JWS using the flattened JSON serialization if
+// it only has one signature, and the general JSON serialization otherwise.
+func (j *JSONWebSignature) MarshalJSON() ([]byte, error) {
+	if len(j.Signatures) == 1 {
+		return json.Marshal(
+			flattenedJWS{
+				Payload:      j.Payload,
+				JWSSignature: j.Signatures[0],
+			},
+		)
+	}
+	type general JSONWebSignature
+	return json.Marshal((*general)(j))
+}
+
+// MarshalGeneral marshals the JWS using the general JSON serialization,
+// regardless of the number of signatures.
+func (j *JSONWebSignature) MarshalGeneral() ([]byte, error) {
+	type general JSONWebSignature
+	return json.Marshal((*general)(j))
+}
+
+// Header returns the protected header of the signature at index i.
+func (j *JSONWebSignature) Header(i int) (*TokenHeader, error) {
+	if i < 0 || i >= len(j.Signatures) {
+		return nil, ErrJWSNoSignatures
+	}
+	var hdr TokenHeader
+	dat, err := base64Decode([]byte(j.Signatures[i].Protected))
+	if err != nil {
+		return nil, errors.Join(ErrTokenMalformed, err)
+	}
+	if len(dat) > 0 {
+		err = json.Unmarshal(dat, &hdr)
+		if err != nil {
+			return nil, errors.Join(ErrTokenMalformed, err)
+		}
+	}
+	// The alg and kid may also be in the unprotected header
+	if hdr.Alg == "" {
+		hdr.Alg, _ = j.Signatures[i].Header["alg"].(string)
+	}
+	if hdr.Kid == "" {
+		hdr.Kid, _ = j.Signatures[i].Header["kid"].(string)
+	}
+	return &hdr, nil
+}
+
+// Verify verifies the JWS using the provided signing method and key. Only
+// the signatures using the provided signing method are checked, and the
+// index of the first signature that verifies is returned. Callers that
+// require several parties to have signed should call Verify once for
+// each of them.
+func (j *JSONWebSignature) Verify(method SigningMethod, key crypto.PublicKey) (int, error) {
+	verr := ErrTokenUnverifiable
+	for i, sig := range j.Signatures {
+		hdr, err := j.Header(i)
+		if err != nil {
+			return -1, err
+		}
+		if hdr.Alg != method.Name() {
+			continue
+		}
+		if len(hdr.Crit) > 0 || hdr.B64 != nil {
+			return -1, ErrJWSUnsupportedCrit
+		}
+		signingInput := []byte(sig.Protected + "." + j.Payload)
+		err = method.Verify(signingInput, []byte(sig.Signature), key)
+		if err == nil {
+			return i, nil
+		}
+		verr = errors.Join(err, ErrTokenSignatureInvalid)
+	}
+	return -1, verr
+}
+
+// DecodePayload returns the decoded payload. The payload is not verified.
+func (j *JSONWebSignature) DecodePayload() ([]byte, error) {
+	payload, err := base64Decode([]byte(j.Payload))
+	if err != nil {
+		return nil, errors.Join(ErrTokenMalformed, err)
+	}
+	return payload, nil
+}
+
+// Compact returns the signature at index i in the compact serialization.
+// Unprotected header fields are dropped, as they cannot be represented in
+// the compact serialization.
+func (j *JSONWebSignature) Compact(i int) ([]byte, error) {
+	if i < 0 || i >= len(j.Signatures) {
+		return nil, ErrJWSNoSignatures
+	}
+	sig := j.Signatures[i]
+	return []byte(sig.Protected + "." + j.Payload + "." + sig.Signature), nil
+}
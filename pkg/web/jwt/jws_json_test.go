@@ -0,0 +1,182 @@
+package jwt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestSignJSON(t *testing.T) {
+	ecKeys := ES256.GenerateKeyPair()
+	edKeys := EdDSA.GenerateKeyPair()
+	payload := []byte(`{"event":"order_created","id":42}`)
+
+	jws, err := SignJSON(
+		payload,
+		JWSSigner{Method: ES256, Key: ecKeys.PrivateKey, Header: TokenHeader{Kid: "ec-1"}},
+		JWSSigner{Method: EdDSA, Key: edKeys.PrivateKey, Unprotected: map[string]any{"kid": "ed-1"}},
+		JWSSigner{Method: HS256, Key: hmacTestKey},
+	)
+	if err != nil {
+		t.Fatalf("error signing payload: %v", err)
+	}
+	assert(t, 3, len(jws.Signatures))
+
+	// Round trip through the general serialization
+	dat, err := json.Marshal(jws)
+	if err != nil {
+		t.Fatalf("error marshaling jws: %v", err)
+	}
+	if !bytes.Contains(dat, []byte(`"signatures"`)) {
+		t.Errorf("expected the general serialization: %s", dat)
+	}
+	jws, err = ParseJSON(dat)
+	if err != nil {
+		t.Fatalf("error parsing jws: %v", err)
+	}
+
+	// Every signer must be able to verify its own signature
+	tests := []struct {
+		method SigningMethod
+		key    any
+		index  int
+		kid    string
+	}{
+		{ES256, ecKeys.PublicKey, 0, "ec-1"},
+		{EdDSA, edKeys.PublicKey, 1, "ed-1"},
+		{HS256, hmacTestKey, 2, ""},
+	}
+	for _, tt := range tests {
+		i, err := jws.Verify(tt.method, tt.key)
+		if err != nil {
+			t.Fatalf("[%s] error verifying jws: %v", tt.method.Name(), err)
+		}
+		assert(t, tt.index, i)
+		hdr, err := jws.Header(i)
+		if err != nil {
+			t.Fatalf("[%s] error reading header: %v", tt.method.Name(), err)
+		}
+		assert(t, tt.kid, hdr.Kid)
+
+		// Each signature is also a valid compact jws
+		compact, err := jws.Compact(i)
+		if err != nil {
+			t.Fatalf("[%s] error converting to compact: %v", tt.method.Name(), err)
+		}
+		_, got, err := VerifyPayload(tt.method, compact, nil, tt.key)
+		if err != nil {
+			t.Fatalf("[%s] error verifying compact jws: %v", tt.method.Name(), err)
+		}
+		assert(t, string(payload), string(got))
+	}
+
+	got, err := jws.DecodePayload()
+	if err != nil {
+		t.Fatalf("error decoding payload: %v", err)
+	}
+	assert(t, string(payload), string(got))
+
+	// A key that did not sign must be rejected
+	_, err = jws.Verify(ES256, ES256.GenerateKeyPair().PublicKey)
+	if !errors.Is(err, ErrTokenSignatureInvalid) {
+		t.Errorf("expected %v, got %v", ErrTokenSignatureInvalid, err)
+	}
+	// And so must an algorithm that was not used
+	_, err = jws.Verify(RS256, RS256.GenerateKeyPair().PublicKey)
+	if !errors.Is(err, ErrTokenUnverifiable) {
+		t.Errorf("expected %v, got %v", ErrTokenUnverifiable, err)
+	}
+
+	// A modified payload must be rejected by every signer
+	jws.Payload = string(Base64Encode([]byte(`{"event":"order_deleted","id":42}`)))
+	for _, tt := range tests {
+		_, err = jws.Verify(tt.method, tt.key)
+		if !errors.Is(err, ErrTokenSignatureInvalid) {
+			t.Errorf("[%s] expected %v, got %v", tt.method.Name(), ErrTokenSignatureInvalid, err)
+		}
+	}
+}
+
+func TestSignJSON_Flattened(t *testing.T) {
+	payload := []byte(`{"event":"order_created","id":42}`)
+	jws, err := SignJSON(payload, JWSSigner{Method: HS256, Key: hmacTestKey})
+	if err != nil {
+		t.Fatalf("error signing payload: %v", err)
+	}
+	dat, err := json.Marshal(jws)
+	if err != nil {
+		t.Fatalf("error marshaling jws: %v", err)
+	}
+	if bytes.Contains(dat, []byte(`"signatures"`)) {
+		t.Errorf("expected the flattened serialization: %s", dat)
+	}
+	general, err := jws.MarshalGeneral()
+	if err != nil {
+		t.Fatalf("error marshaling jws: %v", err)
+	}
+	if !bytes.Contains(general, []byte(`"signatures"`)) {
+		t.Errorf("expected the general serialization: %s", general)
+	}
+
+	for _, dat := range [][]byte{dat, general} {
+		parsed, err := ParseJSON(dat)
+		if err != nil {
+			t.Fatalf("error parsing jws: %v", err)
+		}
+		_, err = parsed.Verify(HS256, hmacTestKey)
+		if err != nil {
+			t.Errorf("error verifying jws: %v", err)
+		}
+	}
+}
+
+// TestParseJSON_RFC7515 verifies the HS256 example from RFC 7515, appendix
+// A.1, in the flattened JSON serialization with an unprotected kid.
+func TestParseJSON_RFC7515(t *testing.T) {
+	key := `{"kty":"oct","k":"AyM1SysPpbyDfgZld3umj1qzKObwVMkoqQ-EstJQLr_T-1qS0gZH75aKtMN3Yj0iPS4hcgUuTwjAzZr1Z9CAow"}`
+	doc := `{
+		"payload": "eyJpc3MiOiJqb2UiLA0KICJleHAiOjEzMDA4MTkzODAsDQogImh0dHA6Ly9leGFtcGxlLmNvbS9pc19yb290Ijp0cnVlfQ",
+		"protected": "eyJ0eXAiOiJKV1QiLA0KICJhbGciOiJIUzI1NiJ9",
+		"header": {"kid": "e9bc097a-ce51-4036-9562-d2ade882db0d"},
+		"signature": "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	}`
+	jwk, err := ParseJWK([]byte(key))
+	if err != nil {
+		t.Fatalf("error parsing jwk: %v", err)
+	}
+	hmacKey, err := jwk.Key()
+	if err != nil {
+		t.Fatalf("error decoding jwk: %v", err)
+	}
+	jws, err := ParseJSON([]byte(doc))
+	if err != nil {
+		t.Fatalf("error parsing jws: %v", err)
+	}
+	hdr, err := jws.Header(0)
+	if err != nil {
+		t.Fatalf("error reading header: %v", err)
+	}
+	assert(t, "e9bc097a-ce51-4036-9562-d2ade882db0d", hdr.Kid)
+	_, err = jws.Verify(HS256, hmacKey)
+	if err != nil {
+		t.Errorf("error verifying jws: %v", err)
+	}
+}
+
+func TestParseJSON_Malformed(t *testing.T) {
+	tests := []struct {
+		doc  string
+		want error
+	}{
+		{`not json`, ErrTokenMalformed},
+		{`{"payload":"e30","signatures":[]}`, ErrJWSNoSignatures},
+		{`{"payload":"e30","signatures":"x"}`, ErrTokenMalformed},
+	}
+	for _, tt := range tests {
+		_, err := ParseJSON([]byte(tt.doc))
+		if !errors.Is(err, tt.want) {
+			t.Errorf("[%s] expected %v, got %v", tt.doc, tt.want, err)
+		}
+	}
+}
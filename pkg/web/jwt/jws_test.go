@@ -0,0 +1,163 @@
+package jwt
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSignPayload(t *testing.T) {
+	keys := ES256.GenerateKeyPair()
+	payload := []byte(`{"event":"order_created","id":42}`)
+
+	tests := []struct {
+		name string
+		opts *JWSOptions
+	}{
+		{"attached", nil},
+		{"detached", &JWSOptions{Detached: true}},
+		{"unencoded", &JWSOptions{Unencoded: true}},
+		{"unencoded detached", &JWSOptions{Unencoded: true, Detached: true}},
+	}
+	for _, tt := range tests {
+		jws, err := SignPayload(ES256, payload, keys.PrivateKey, tt.opts)
+		if err != nil {
+			t.Fatalf("[%s] error signing payload: %v", tt.name, err)
+		}
+		detached := tt.opts != nil && tt.opts.Detached
+		if detached && !bytes.Contains(jws, []byte("..")) {
+			t.Errorf("[%s] expected a detached payload: %s", tt.name, jws)
+		}
+
+		var provided []byte
+		if detached {
+			provided = payload
+		}
+		hdr, got, err := VerifyPayload(ES256, jws, provided, keys.PublicKey)
+		if err != nil {
+			t.Fatalf("[%s] error verifying payload: %v", tt.name, err)
+		}
+		if !bytes.Equal(payload, got) {
+			t.Errorf("[%s] payload mismatch: %s", tt.name, got)
+		}
+		if tt.opts != nil && tt.opts.Unencoded {
+			if hdr.B64 == nil || *hdr.B64 || hdr.Crit[0] != "b64" {
+				t.Errorf("[%s] expected b64:false and crit:[b64] headers", tt.name)
+			}
+		}
+
+		// A modified payload must be rejected
+		if detached {
+			_, _, err = VerifyPayload(ES256, jws, []byte(`{"event":"order_deleted","id":42}`), keys.PublicKey)
+			if !errors.Is(err, ErrTokenSignatureInvalid) {
+				t.Errorf("[%s] expected %v, got %v", tt.name, ErrTokenSignatureInvalid, err)
+			}
+			_, _, err = VerifyPayload(ES256, jws, nil, keys.PublicKey)
+			if err != ErrJWSPayloadRequired {
+				t.Errorf("[%s] expected %v, got %v", tt.name, ErrJWSPayloadRequired, err)
+			}
+		}
+	}
+}
+
+func TestVerifyPayload_MalformedSignature(t *testing.T) {
+	payload := []byte(`{"event":"order_created","id":42}`)
+	rsaKeys := RS256.GenerateKeyPair()
+	tests := []struct {
+		method SigningMethod
+		keys   *KeyPair
+	}{
+		{HS256, HS256.GenerateKeyPair()},
+		{RS256, rsaKeys},
+		{PS256, rsaKeys},
+		{ES256, ES256.GenerateKeyPair()},
+		{EdDSA, EdDSA.GenerateKeyPair()},
+	}
+	for _, tt := range tests {
+		method, keys := tt.method, tt.keys
+		jws, err := SignPayload(method, payload, keys.PrivateKey, &JWSOptions{Detached: true})
+		if err != nil {
+			t.Fatalf("[%s] error signing payload: %v", method.Name(), err)
+		}
+		// Signatures that are not valid base64url must be rejected, and
+		// must never panic
+		for _, sig := range []string{"!!!", "a", "abc=", "ab+/"} {
+			i := bytes.LastIndexByte(jws, '.')
+			malformed := append(jws[:i+1:i+1], sig...)
+			_, _, err = VerifyPayload(method, malformed, payload, keys.PublicKey)
+			if !errors.Is(err, ErrTokenSignatureInvalid) {
+				t.Errorf("[%s] expected %v for signature %q, got %v", method.Name(), ErrTokenSignatureInvalid, sig, err)
+			}
+		}
+	}
+}
+
+func TestSignPayload_RFC7797(t *testing.T) {
+	// Example from RFC 7797, section 4.2
+	key := []byte{
+		3, 35, 53, 75, 43, 15, 165, 188, 131, 126, 6, 101, 119, 123, 166, 143,
+		90, 179, 40, 230, 240, 84, 201, 40, 169, 15, 132, 178, 210, 80, 46, 191,
+		211, 251, 90, 146, 210, 6, 71, 239, 150, 138, 180, 195, 119, 98, 61, 34,
+		61, 46, 33, 114, 5, 46, 79, 8, 192, 205, 154, 245, 103, 208, 128, 163,
+	}
+	jws := []byte("eyJhbGciOiJIUzI1NiIsImI2NCI6ZmFsc2UsImNyaXQiOlsiYjY0Il19..A5dxf2s96_n5FLueVuW1Z_vh161FwXZC4YLPff6dmDY")
+	_, _, err := VerifyPayload(HS256, jws, []byte("$.02"), key)
+	if err != nil {
+		t.Errorf("error verifying RFC 7797 example: %v", err)
+	}
+}
+
+func TestVerifyPayload_Errors(t *testing.T) {
+	keys := HS256.GenerateKeyPair()
+
+	// Unencoded attached payloads cannot contain a '.'
+	_, err := SignPayload(HS256, []byte("a.b"), keys.PrivateKey, &JWSOptions{Unencoded: true})
+	if err != ErrJWSInvalidPayload {
+		t.Errorf("expected %v, got %v", ErrJWSInvalidPayload, err)
+	}
+
+	// Unknown critical headers must be rejected
+	jws, err := SignPayload(HS256, []byte("hello"), keys.PrivateKey, &JWSOptions{Header: TokenHeader{Crit: []string{"exp"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = VerifyPayload(HS256, jws, nil, keys.PublicKey)
+	if err != ErrJWSUnsupportedCrit {
+		t.Errorf("expected %v, got %v", ErrJWSUnsupportedCrit, err)
+	}
+
+	// The algorithm must match
+	jws, err = SignPayload(HS256, []byte("hello"), keys.PrivateKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = VerifyPayload(HS384, jws, nil, keys.PublicKey)
+	if err != ErrTokenUnverifiable {
+		t.Errorf("expected %v, got %v", ErrTokenUnverifiable, err)
+	}
+
+	// A payload provided for an attached JWS must match it
+	for _, opts := range []*JWSOptions{nil, {Unencoded: true}} {
+		jws, err = SignPayload(HS256, []byte("signed"), keys.PrivateKey, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _, err = VerifyPayload(HS256, jws, []byte("mine"), keys.PublicKey)
+		if err != ErrJWSPayloadMismatch {
+			t.Errorf("expected %v, got %v", ErrJWSPayloadMismatch, err)
+		}
+		_, payload, err := VerifyPayload(HS256, jws, []byte("signed"), keys.PublicKey)
+		if err != nil {
+			t.Errorf("error verifying matching payload: %v", err)
+		}
+		assert(t, "signed", string(payload))
+	}
+
+	// Garbage should not panic
+	for _, garbage := range []string{"", ".", "..", "!!.!!.!!", "abc"} {
+		_, _, err = VerifyPayload(HS256, []byte(garbage), nil, keys.PublicKey)
+		if err == nil {
+			t.Errorf("[%q] expected an error", garbage)
+		}
+	}
+}
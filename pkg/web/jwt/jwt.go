@@ -1,10 +1,7 @@
 package jwt
 
 import (
-	"bytes"
 	"encoding/base64"
-	"encoding/json"
-	"errors"
 )
 
 // token = SigningMethod(
@@ -21,49 +18,17 @@ type Token struct {
 	Valid     bool
 }
 
+// ParseRawToken parses the raw token using the DefaultParser. The token
+// is not validated.
 func ParseRawToken(raw RawToken) (*Token, error) {
+	return DefaultParser.Parse(raw)
+}
 
-	// Create error type
-	var verr, err error
-
-	// Split the raw token
-	parts := bytes.Split(raw, []byte{'.'})
-	if len(parts) != 3 {
-		return nil, ErrTokenMalformed
-	}
-
-	// Initialize a token instance
-	var token Token
-	token.RawToken = raw
-
-	// Parse the raw token header
-	headerBytes := Base64Decode(parts[0])
-	err = json.Unmarshal(headerBytes, &token.Header)
-	if err != nil {
-		verr = errors.Join(ErrTokenMalformed, err)
-		return nil, verr
-	}
-
-	// Parse the raw token claims
-	claimsBytes := Base64Decode(parts[1])
-	err = json.Unmarshal(claimsBytes, &token.Payload)
-	if err != nil {
-		verr = errors.Join(ErrTokenMalformed, err)
-		return nil, verr
-	}
-
-	// Get the signing method (even though it's not verifiable, yet)
-	token.Method = GetSigningMethod(token.Header.Alg)
-	if token.Method == nil {
-		verr = errors.Join(ErrTokenMalformed, err)
-		return nil, verr
-	}
-
-	// Add the signature
-	token.Signature = parts[2]
-
-	// Return out token (unverified raw)
-	return &token, verr
+// parseRawToken parses the raw token using the DefaultParser, decoding the
+// payload into the provided claims. The returned token holds everything
+// except for the payload, which is left to the caller.
+func parseRawToken(raw RawToken, claims any) (*Token, error) {
+	return DefaultParser.parse(raw, claims)
 }
 
 func Base64Encode(src []byte) []byte {
@@ -72,6 +37,10 @@ func Base64Encode(src []byte) []byte {
 	return buf
 }
 
+// Base64Decode decodes base64url encoded data without padding.
+//
+// Deprecated: Base64Decode panics on invalid input. Nothing in this package
+// uses it anymore; use base64.RawURLEncoding instead.
 func Base64Decode(src []byte) []byte {
 	buf := make([]byte, base64.RawURLEncoding.DecodedLen(len(src)))
 	n, err := base64.RawURLEncoding.Decode(buf, src)
@@ -80,3 +49,14 @@ func Base64Decode(src []byte) []byte {
 	}
 	return buf[:n]
 }
+
+// base64Decode works just like Base64Decode, except that it returns an
+// error instead of panicking on invalid input.
+func base64Decode(src []byte) ([]byte, error) {
+	buf := make([]byte, base64.RawURLEncoding.DecodedLen(len(src)))
+	n, err := base64.RawURLEncoding.Decode(buf, src)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
@@ -0,0 +1,337 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ringKey is a single named key held by a KeyRing.
+type ringKey struct {
+	kid     string
+	method  SigningMethod
+	keys    *KeyPair
+	added   time.Time
+	retired time.Time // zero while the key is active
+}
+
+// expired reports whether the key has been retired for longer than the
+// provided grace window.
+func (k *ringKey) expired(now time.Time, grace time.Duration) bool {
+	return !k.retired.IsZero() && !now.Before(k.retired.Add(grace))
+}
+
+// KeyRing holds a set of named signing keys. One of the keys is the
+// current signing key, and its key id is written into the header of every
+// token the ring is used to sign. The remaining keys are only used for
+// verification. When the current key is replaced it is retired, and it
+// can still be used to verify tokens until the grace window has passed.
+// This makes it possible to rotate keys without invalidating every token
+// that is still live.
+type KeyRing struct {
+	mu      sync.RWMutex
+	method  SigningMethod
+	grace   time.Duration
+	keys    map[string]*ringKey
+	current string
+
+	ticker     *time.Ticker
+	tickerStop chan bool
+	isRotating bool
+
+	// Clock is the source of the current time, which decides when the
+	// grace window of a retired key has passed; if it is left as nil,
+	// SystemClock will be used. A TokenManager passes its own Clock to
+	// the ring instead.
+	Clock Clock
+}
+
+// DefaultKeyGrace is the grace window of the KeyRing used by a TokenManager
+// created with NewTokenManager. It covers the default lifetime of tokens
+// issued without an exp claim, as well as the default lifetime of access
+// tokens. Tokens that live longer need a KeyRing with a longer grace
+// window, see NewTokenManagerWithKeyRing.
+const DefaultKeyGrace = time.Hour
+
+// NewKeyRing initializes and returns a new, empty KeyRing. The method is
+// used to generate new keys when the ring is rotated, and grace is how
+// long a retired key can still be used for verification. The grace window
+// should be at least as long as the lifetime of the tokens being issued.
+func NewKeyRing(method SigningMethod, grace time.Duration) *KeyRing {
+	return &KeyRing{
+		method: method,
+		grace:  grace,
+		keys:   make(map[string]*ringKey),
+	}
+}
+
+// Add adds a named key pair to the ring. If the ring does not have a
+// current signing key yet, the added key becomes the current signing key.
+// If kid is empty, a key id is derived from the key pair.
+func (r *KeyRing) Add(kid string, method SigningMethod, keys *KeyPair) (string, error) {
+	if method == nil || keys == nil {
+		return "", ErrInvalidKeyType
+	}
+	var err error
+	if kid == "" {
+		kid, err = newKeyID(keys)
+		if err != nil {
+			return "", err
+		}
+	}
+	// RSA keys that were put together by hand are missing the CRT values,
+	// which makes every signature roughly twice as slow. Precompute them
+	// once, before the key is shared.
+	if rsaKey, ok := keys.PrivateKey.(*rsa.PrivateKey); ok {
+		rsaKey.Precompute()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, found := r.keys[kid]; found {
+		return "", ErrKeyExists
+	}
+	r.keys[kid] = &ringKey{
+		kid:    kid,
+		method: method,
+		keys:   keys,
+		added:  r.now(),
+	}
+	if r.current == "" {
+		r.current = kid
+	}
+	return kid, nil
+}
+
+// SetCurrent makes the key with the matching key id the current signing
+// key. The previous signing key is retired, and the grace window for that
+// key starts now.
+func (r *KeyRing) SetCurrent(kid string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.setCurrent(kid, r.now())
+}
+
+func (r *KeyRing) setCurrent(kid string, now time.Time) error {
+	k, found := r.keys[kid]
+	if !found || k.expired(now, r.grace) {
+		return ErrKeyNotFound
+	}
+	if r.current == kid {
+		return nil
+	}
+	if old, found := r.keys[r.current]; found {
+		old.retired = now
+	}
+	k.retired = time.Time{}
+	r.current = kid
+	return nil
+}
+
+// Rotate generates a new key pair using the signing method of the ring,
+// adds it to the ring and makes it the current signing key. Any keys that
+// have been retired for longer than the grace window are removed. It
+// returns the key id of the new signing key.
+func (r *KeyRing) Rotate() (string, error) {
+	return r.rotate(r.now())
+}
+
+func (r *KeyRing) rotate(now time.Time) (string, error) {
+	keys := r.method.GenerateKeyPair()
+	if keys == nil {
+		return "", ErrInvalidKeyType
+	}
+	kid, err := newKeyID(keys)
+	if err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys[kid] = &ringKey{
+		kid:    kid,
+		method: r.method,
+		keys:   keys,
+		added:  now,
+	}
+	if err = r.setCurrent(kid, now); err != nil {
+		return "", err
+	}
+	r.prune(now)
+	return kid, nil
+}
+
+// Remove removes the key with the matching key id from the ring. The
+// current signing key cannot be removed.
+func (r *KeyRing) Remove(kid string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, found := r.keys[kid]; !found {
+		return ErrKeyNotFound
+	}
+	if kid == r.current {
+		return ErrKeyInUse
+	}
+	delete(r.keys, kid)
+	return nil
+}
+
+// Prune removes all the keys that have been retired for longer than the
+// grace window.
+func (r *KeyRing) Prune() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prune(r.now())
+}
+
+func (r *KeyRing) prune(now time.Time) {
+	for kid, k := range r.keys {
+		if k.expired(now, r.grace) {
+			delete(r.keys, kid)
+		}
+	}
+}
+
+// Current returns the key id, signing method and key pair of the current
+// signing key.
+func (r *KeyRing) Current() (string, SigningMethod, *KeyPair, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	k, found := r.keys[r.current]
+	if !found {
+		return "", nil, nil, ErrNoSigningKey
+	}
+	return k.kid, k.method, k.keys, nil
+}
+
+// Lookup returns the signing method and key pair for the key with the
+// matching key id. Retired keys are returned until their grace window has
+// passed. If kid is empty, the current signing key is returned.
+func (r *KeyRing) Lookup(kid string) (SigningMethod, *KeyPair, error) {
+	return r.lookup(kid, r.now())
+}
+
+func (r *KeyRing) lookup(kid string, now time.Time) (SigningMethod, *KeyPair, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if kid == "" {
+		kid = r.current
+	}
+	k, found := r.keys[kid]
+	if !found {
+		return nil, nil, ErrKeyNotFound
+	}
+	if k.expired(now, r.grace) {
+		return nil, nil, ErrKeyExpired
+	}
+	return k.method, k.keys, nil
+}
+
+// KeyIDs returns the key ids of all the keys that can currently be used
+// for verification, ordered from the most to the least recently added.
+func (r *KeyRing) KeyIDs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.keyIDs(r.now())
+}
+
+func (r *KeyRing) keyIDs(now time.Time) []string {
+	kids := make([]string, 0, len(r.keys))
+	for kid, k := range r.keys {
+		if !k.expired(now, r.grace) {
+			kids = append(kids, kid)
+		}
+	}
+	sort.Slice(
+		kids, func(i, j int) bool {
+			return r.keys[kids[i]].added.After(r.keys[kids[j]].added)
+		},
+	)
+	return kids
+}
+
+// JWKSet returns a JWK set containing the public keys of all the keys in
+// the ring that can currently be used for verification. Symmetric keys
+// are never published, so they are left out.
+func (r *KeyRing) JWKSet() (*JWKSet, error) {
+	return r.jwkSet(r.now())
+}
+
+func (r *KeyRing) jwkSet(now time.Time) (*JWKSet, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	kids := r.keyIDs(now)
+	set := &JWKSet{Keys: make([]*JWK, 0, len(kids))}
+	for _, kid := range kids {
+		k := r.keys[kid]
+		jwk, err := k.keys.PublicJWK()
+		if err != nil {
+			return nil, err
+		}
+		if jwk.Kty == KeyTypeOct {
+			continue
+		}
+		jwk.Kid = k.kid
+		jwk.Alg = k.method.Name()
+		jwk.Use = "sig"
+		set.Add(jwk)
+	}
+	return set, nil
+}
+
+func (r *KeyRing) now() time.Time {
+	return clockNow(r.Clock)
+}
+
+// StartRotation starts rotating the current signing key at the provided
+// interval. It will keep rotating until StopRotation is called.
+func (r *KeyRing) StartRotation(interval time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// already running
+	if r.isRotating {
+		return
+	}
+	r.ticker = time.NewTicker(interval)
+	r.tickerStop = make(chan bool)
+	// spawn a new goroutine
+	go func(ticker *time.Ticker, stop chan bool) {
+		for {
+			select {
+			case <-ticker.C: // a new tick
+				r.Rotate()
+			case <-stop: // stopping the ticker
+				return
+			}
+		}
+	}(r.ticker, r.tickerStop)
+	r.isRotating = true
+}
+
+// StopRotation stops the scheduled rotation of the current signing key.
+func (r *KeyRing) StopRotation() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// already stopped
+	if !r.isRotating {
+		return
+	}
+	r.ticker.Stop()
+	close(r.tickerStop)
+	r.isRotating = false
+}
+
+// newKeyID returns a key id for the provided key pair. Asymmetric keys
+// use the thumbprint of the public key. Symmetric keys use a random key
+// id, so that nothing about the secret is leaked through the header.
+func newKeyID(keys *KeyPair) (string, error) {
+	if _, ok := keys.PublicKey.([]byte); !ok {
+		return KeyID(keys.PublicKey)
+	}
+	buf := make([]byte, 16)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	return string(Base64Encode(buf)), nil
+}
@@ -0,0 +1,185 @@
+package jwt
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestKeyRing_Rotate(t *testing.T) {
+	ring := NewKeyRing(ES256, time.Hour)
+	kid1, err := ring.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm := NewTokenManagerWithKeyRing(ring)
+
+	// Issue a token with the first key
+	t1, err := tm.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, kid1, t1.Header().Kid)
+
+	// Rotate, and issue a token with the second key
+	kid2, err := tm.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid1 == kid2 {
+		t.Fatalf("expected a new key id after rotation")
+	}
+	t2, err := tm.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, kid2, t2.Header().Kid)
+
+	// Both tokens should be valid, the first one is within the grace window
+	if _, err = tm.ValidateToken(t1); err != nil {
+		t.Errorf("error validating token signed by retired key: %v", err)
+	}
+	if _, err = tm.ValidateToken(t2); err != nil {
+		t.Errorf("error validating token signed by current key: %v", err)
+	}
+
+	// Both keys should be published
+	set, err := tm.JWKSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.Key(kid1) == nil || set.Key(kid2) == nil {
+		t.Errorf("expected both keys to be published")
+	}
+}
+
+func TestKeyRing_GraceWindow(t *testing.T) {
+	ring := NewKeyRing(HS256, 0)
+	if _, err := ring.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	tm := NewTokenManagerWithKeyRing(ring)
+	t1, err := tm.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// With no grace window, the retired key is pruned right away
+	if _, err = tm.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	_, err = tm.ValidateToken(t1)
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("expected %v, got %v", ErrKeyNotFound, err)
+	}
+	if len(ring.KeyIDs()) != 1 {
+		t.Errorf("expected 1 key in the ring, got %d", len(ring.KeyIDs()))
+	}
+
+	// Symmetric keys should never be published
+	set, err := tm.JWKSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Keys) != 0 {
+		t.Errorf("expected no published keys, got %d", len(set.Keys))
+	}
+}
+
+func TestNewTokenManager_Rotate(t *testing.T) {
+	tm := NewTokenManager(ES256, ES256.GenerateKeyPair())
+	t1, err := tm.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tm.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	t2, err := tm.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if t1.Header().Kid == t2.Header().Kid {
+		t.Fatalf("expected a new key id after rotation")
+	}
+
+	// Tokens issued before the rotation are still valid
+	if _, err = tm.ValidateToken(t1); err != nil {
+		t.Errorf("error validating token issued before rotation: %v", err)
+	}
+	if _, err = tm.ValidateToken(t2); err != nil {
+		t.Errorf("error validating token issued after rotation: %v", err)
+	}
+}
+
+func TestKeyRing_AddAndSetCurrent(t *testing.T) {
+	ring := NewKeyRing(RS256, time.Hour)
+	kid1, err := ring.Add("first", HS256, &KeyPair{PrivateKey: hmacTestKey, PublicKey: hmacTestKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid2, err := ring.Add("second", EdDSA, EdDSA.GenerateKeyPair())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ring.Add("second", EdDSA, EdDSA.GenerateKeyPair()); err != ErrKeyExists {
+		t.Errorf("expected %v, got %v", ErrKeyExists, err)
+	}
+
+	tm := NewTokenManagerWithKeyRing(ring)
+	t1, err := tm.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, kid1, t1.Header().Kid)
+	assert(t, "HS256", t1.Header().Alg)
+
+	// Switch to a key using a different signing method
+	if err = ring.SetCurrent(kid2); err != nil {
+		t.Fatal(err)
+	}
+	t2, err := tm.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, kid2, t2.Header().Kid)
+	assert(t, "EdDSA", t2.Header().Alg)
+
+	for _, tok := range []RawToken{t1, t2} {
+		if _, err = tm.ValidateToken(tok); err != nil {
+			t.Errorf("error validating token: %v", err)
+		}
+	}
+
+	if err = ring.Remove(kid2); err != ErrKeyInUse {
+		t.Errorf("expected %v, got %v", ErrKeyInUse, err)
+	}
+	if err = ring.Remove(kid1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tm.ValidateToken(t1); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("expected %v, got %v", ErrKeyNotFound, err)
+	}
+}
+
+func TestKeyRing_StartRotation(t *testing.T) {
+	ring := NewKeyRing(HS256, time.Hour)
+	kid, err := ring.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ring.StartRotation(10 * time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	ring.StopRotation()
+
+	cur, _, _, err := ring.Current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cur == kid {
+		t.Errorf("expected the signing key to have been rotated")
+	}
+	if len(ring.KeyIDs()) < 2 {
+		t.Errorf("expected retired keys to remain in the ring")
+	}
+}
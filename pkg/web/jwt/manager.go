@@ -1,20 +1,61 @@
 package jwt
 
 import (
+	"encoding/json"
 	"net/http"
+	"sync/atomic"
 	"time"
 )
 
 type TokenManager struct {
-	method SigningMethod
-	keys   *KeyPair
+	ring *KeyRing
+
+	// header caches the encoded header of the tokens signed using the
+	// current key, which only changes when the key is rotated.
+	header atomic.Pointer[encodedHeader]
+
+	// AccessTokenTTL is the lifetime of the access tokens issued as
+	// part of a token pair.
+	AccessTokenTTL time.Duration
+
+	// RefreshTokenTTL is the lifetime of the refresh tokens issued as
+	// part of a token pair. Every time a refresh token is used, the
+	// new refresh token gets a full lifetime.
+	RefreshTokenTTL time.Duration
+
+	// RefreshTokenStore holds the refresh token families. It must be
+	// set in order to issue token pairs.
+	RefreshTokenStore RefreshTokenStore
+
+	// Extractor is used by ValidateTokenFromRequest to extract the
+	// token from the request. It defaults to DefaultExtractor.
+	Extractor Extractor
+
+	// Validator holds the validation options. Its Clock is used when
+	// issuing tokens as well.
 	Validator
 }
 
+// NewTokenManager returns a new TokenManager that signs and validates tokens
+// using the provided key pair. When the manager is rotated, the key pair is
+// retired but can still be used to validate tokens for DefaultKeyGrace.
 func NewTokenManager(method SigningMethod, keys *KeyPair) *TokenManager {
+	ring := NewKeyRing(method, DefaultKeyGrace)
+	_, err := ring.Add("", method, keys)
+	if err != nil {
+		panic(err)
+	}
+	return NewTokenManagerWithKeyRing(ring)
+}
+
+// NewTokenManagerWithKeyRing returns a new TokenManager that signs tokens
+// using the current key of the provided KeyRing, and validates tokens using
+// the key in the ring that matches the kid header of the token.
+func NewTokenManagerWithKeyRing(ring *KeyRing) *TokenManager {
 	m := &TokenManager{
-		method: method,
-		keys:   keys,
+		ring:            ring,
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: 7 * 24 * time.Hour,
 	}
 	m.Validator = Validator{
 		Margin:      time.Minute,
@@ -22,48 +63,99 @@ func NewTokenManager(method SigningMethod, keys *KeyPair) *TokenManager {
 		ExpectedAUD: "",
 		ExpectedISS: "",
 		ExpectedSUB: "",
-		Method:      method,
+		Method:      ring.method,
 	}
 	return m
 }
 
+// KeyRing returns the KeyRing used by the manager.
+func (m *TokenManager) KeyRing() *KeyRing {
+	return m.ring
+}
+
+// Rotate rotates the current signing key of the manager. See KeyRing.Rotate
+// for more details.
+func (m *TokenManager) Rotate() (string, error) {
+	return m.ring.rotate(m.now())
+}
+
 func (m *TokenManager) GenerateToken(claims ClaimsSet) (RawToken, error) {
-	token, err := NewToken(m.method, claims, m.keys.PrivateKey)
+	kid, method, keys, err := m.ring.Current()
 	if err != nil {
 		return nil, err
 	}
-	return token, nil
-}
-
-func (m *TokenManager) ValidateToken(raw RawToken) (*Token, error) {
-	token, err := m.Validator.ValidateToken(raw, m.keys.PublicKey)
+	header, err := m.encodedHeader(kid, method)
+	if err != nil {
+		return nil, err
+	}
+	if claims == nil {
+		claims = defaultClaims(m.now())
+	}
+	token, err := signToken(method, header, claims, keys.PrivateKey)
 	if err != nil {
 		return nil, err
 	}
 	return token, nil
 }
 
-func (m *TokenManager) ValidateTokenFromRequest(r *http.Request) (*Token, error) {
-	var err error
-	var raw RawToken
-	var tok *Token
+// encodedHeader is the encoded header of the tokens signed using a key.
+type encodedHeader struct {
+	kid string
+	alg string
+	b   []byte
+}
 
-	raw, err = ExtractTokenFromRequest(r)
-	if err != nil && err == ErrNoTokenInRequest {
-		goto tryCookie
+// encodedHeader returns the encoded header for tokens signed using the key
+// with the kid, which is cached until the current key changes.
+func (m *TokenManager) encodedHeader(kid string, method SigningMethod) ([]byte, error) {
+	alg := method.Name()
+	if h := m.header.Load(); h != nil && h.kid == kid && h.alg == alg {
+		return h.b, nil
+	}
+	dat, err := json.Marshal(TokenHeader{Typ: "JWT", Alg: alg, Kid: kid})
+	if err != nil {
+		return nil, err
 	}
+	h := &encodedHeader{kid: kid, alg: alg, b: Base64Encode(dat)}
+	m.header.Store(h)
+	return h.b, nil
+}
 
-tryCookie:
-	raw, err = ExtractTokenFromCookie("token", r)
-	if err != nil && err == ErrNoCookieFound {
+func (m *TokenManager) ValidateToken(raw RawToken) (*Token, error) {
+	token, err := m.parser().Parse(raw)
+	if err != nil {
 		return nil, err
 	}
+	return m.validateToken(token, token.Payload)
+}
 
-	// we have a raw token, lets try to validate it
-	tok, err = m.Validator.ValidateToken(raw, m.keys.PublicKey)
+// validateToken looks up the verification key using the kid header of
+// the parsed token and validates the token and its claims using it. The
+// claims are passed in separately, just like Validator.validate, so typed
+// tokens go through the same checks.
+func (m *TokenManager) validateToken(token *Token, claims ClaimsSet) (*Token, error) {
+	method, keys, err := m.ring.lookup(token.Header.Kid, m.now())
 	if err != nil {
 		return nil, err
 	}
+	v := m.Validator
+	v.Method = method
+	err = v.validate(token, claims, keys.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	token.Valid = true
+	return token, nil
+}
 
-	return tok, nil
+func (m *TokenManager) ValidateTokenFromRequest(r *http.Request) (*Token, error) {
+	extractor := m.Extractor
+	if extractor == nil {
+		extractor = DefaultExtractor
+	}
+	raw, err := extractor.Extract(r)
+	if err != nil {
+		return nil, err
+	}
+	return m.ValidateToken(raw)
 }
@@ -28,6 +28,9 @@ func TestNewTokenManager(t *testing.T) {
 	_ = PS384
 	_ = PS512
 
+	// EdDSA signing method
+	_ = EdDSA
+
 	// generate key pair using RSA 512
 	keys := RS512.GenerateKeyPair()
 
@@ -51,7 +54,7 @@ func TestNewTokenManager(t *testing.T) {
 		&RegisteredClaims{
 			Issuer:         "jon doe",
 			Subject:        "your mom goes to college",
-			Audience:       "anyone",
+			Audience:       Audience{"anyone"},
 			ExpirationTime: NumericDate(time.Now().Add(4 * time.Hour).Unix()),
 			NotBeforeTime:  NumericDate(time.Now().Unix()),
 			IssuedAtTime:   NumericDate(time.Now().Unix()),
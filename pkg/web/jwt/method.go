@@ -2,6 +2,10 @@ package jwt
 
 import (
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"hash"
 	"sync"
 )
 
@@ -49,3 +53,74 @@ type SigningMethod interface {
 	// provided signature.
 	Verify(partialToken []byte, signature []byte, key crypto.PublicKey) error
 }
+
+// checkKeyType checks that the key belongs to the same algorithm family as
+// the signing method, so a key can never be used with an algorithm it was
+// not meant for. Signing methods registered by the user are not checked.
+func checkKeyType(method SigningMethod, key crypto.PublicKey) error {
+	var ok bool
+	switch m := method.(type) {
+	case *SigningMethodHMAC:
+		var k []byte
+		k, ok = key.([]byte)
+		ok = ok && isSymmetricKey(k)
+	case *SigningMethodRSA, *SigningMethodRSAPSS:
+		_, ok = key.(*rsa.PublicKey)
+	case *SigningMethodECDSA:
+		var k *ecdsa.PublicKey
+		k, ok = key.(*ecdsa.PublicKey)
+		ok = ok && k.Curve == m.curve
+	case *SigningMethodEdDSA:
+		_, ok = key.(ed25519.PublicKey)
+	default:
+		ok = true
+	}
+	if !ok {
+		return ErrInvalidKeyType
+	}
+	return nil
+}
+
+// signerFor returns the key as a crypto.Signer, checking that its public
+// key suits the signing method. It allows keys that are not held in
+// memory, such as keys in an HSM, a KMS or a signing daemon, to be used
+// in place of a concrete private key.
+func signerFor(method SigningMethod, key crypto.PrivateKey) (crypto.Signer, error) {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, ErrInvalidKeyType
+	}
+	if err := checkKeyType(method, signer.Public()); err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
+// rawSigner is implemented by the built-in signing methods. It works just
+// like Sign, except that it returns the raw signature, so the signature
+// can be encoded straight into the token instead of being encoded twice.
+type rawSigner interface {
+	signRaw(partialToken []byte, key crypto.PrivateKey) ([]byte, error)
+}
+
+// hashPools holds a pool of hashers for each of the hash functions, so a
+// new hasher does not have to be allocated for every token.
+var hashPools [crypto.BLAKE2b_512 + 1]sync.Pool
+
+// digest returns the digest of the data, using a pooled hasher.
+func digest(h crypto.Hash, data []byte) []byte {
+	if int(h) >= len(hashPools) {
+		hasher := h.New()
+		hasher.Write(data)
+		return hasher.Sum(nil)
+	}
+	hasher, ok := hashPools[h].Get().(hash.Hash)
+	if !ok {
+		hasher = h.New()
+	}
+	hasher.Write(data)
+	sum := hasher.Sum(nil)
+	hasher.Reset()
+	hashPools[h].Put(hasher)
+	return sum
+}
@@ -5,10 +5,15 @@ import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"encoding/asn1"
+	"math/big"
 )
 
 // SigningMethodECDSA implements the ECDSA family of signing methods.
-// Expects *ecdsa.PrivateKey for signing and *ecdsa.PublicKey for verification
+// Expects *ecdsa.PrivateKey (or any crypto.Signer holding an ECDSA key on
+// the same curve) for signing and *ecdsa.PublicKey for verification. The
+// signatures are the fixed width concatenation of R and S described in
+// RFC 7518, section 3.4, not the ASN.1 encoding used by crypto/ecdsa.
 type SigningMethodECDSA struct {
 	name      string
 	hash      crypto.Hash
@@ -25,19 +30,25 @@ var (
 
 func init() {
 	ES256 = &SigningMethodECDSA{
-		name:  "ES256",
-		hash:  crypto.SHA256,
-		curve: elliptic.P256(),
+		name:      "ES256",
+		hash:      crypto.SHA256,
+		KeySize:   32,
+		CurveBits: 256,
+		curve:     elliptic.P256(),
 	}
 	ES384 = &SigningMethodECDSA{
-		name:  "ES384",
-		hash:  crypto.SHA384,
-		curve: elliptic.P384(),
+		name:      "ES384",
+		hash:      crypto.SHA384,
+		KeySize:   48,
+		CurveBits: 384,
+		curve:     elliptic.P384(),
 	}
 	ES512 = &SigningMethodECDSA{
-		name:  "ES512",
-		hash:  crypto.SHA512,
-		curve: elliptic.P521(),
+		name:      "ES512",
+		hash:      crypto.SHA512,
+		KeySize:   66,
+		CurveBits: 521,
+		curve:     elliptic.P521(),
 	}
 
 	RegisterSigningMethod(ES256.Name(), func() SigningMethod { return ES256 })
@@ -61,35 +72,88 @@ func (s *SigningMethodECDSA) GenerateKeyPair() *KeyPair {
 }
 
 func (s *SigningMethodECDSA) Sign(partialToken []byte, key crypto.PrivateKey) ([]byte, error) {
-	ecdsaKey, ok := key.(*ecdsa.PrivateKey)
-	if !ok {
-		return nil, ErrInvalidKeyType
+	sig, err := s.signRaw(partialToken, key)
+	if err != nil {
+		return nil, err
 	}
+	return Base64Encode(sig), nil
+}
+
+func (s *SigningMethodECDSA) signRaw(partialToken []byte, key crypto.PrivateKey) ([]byte, error) {
 	if !s.hash.Available() {
 		return nil, ErrHashUnavailable
 	}
-	hasher := s.hash.New()
-	hasher.Write(partialToken)
-	sig, err := ecdsa.SignASN1(rand.Reader, ecdsaKey, hasher.Sum(nil))
+	var der []byte
+	var err error
+	if ecdsaKey, ok := key.(*ecdsa.PrivateKey); ok {
+		if ecdsaKey.Curve != s.curve {
+			return nil, ErrInvalidKeyType
+		}
+		der, err = ecdsa.SignASN1(rand.Reader, ecdsaKey, digest(s.hash, partialToken))
+	} else {
+		var signer crypto.Signer
+		signer, err = signerFor(s, key)
+		if err != nil {
+			return nil, err
+		}
+		// A crypto.Signer returns ASN.1 encoded signatures for ECDSA,
+		// just like SignASN1
+		der, err = signer.Sign(rand.Reader, digest(s.hash, partialToken), s.hash)
+	}
 	if err != nil {
 		return nil, err
 	}
-	return Base64Encode(sig), nil
+	return s.fixedSignature(der)
+}
+
+// ecdsaSignature is the ASN.1 encoding of an ECDSA signature.
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// keyBytes returns the size of R and S in a signature, which is the size
+// of the curve order in bytes.
+func (s *SigningMethodECDSA) keyBytes() int {
+	return (s.curve.Params().BitSize + 7) / 8
+}
+
+// fixedSignature converts an ASN.1 encoded signature to the concatenation
+// of R and S, each left padded with zeros to the size of the curve.
+func (s *SigningMethodECDSA) fixedSignature(der []byte) ([]byte, error) {
+	var sig ecdsaSignature
+	rest, err := asn1.Unmarshal(der, &sig)
+	if err != nil || len(rest) > 0 || sig.R == nil || sig.S == nil {
+		return nil, ErrSignatureInvalid
+	}
+	size := s.keyBytes()
+	if sig.R.Sign() <= 0 || sig.S.Sign() <= 0 || sig.R.BitLen() > size*8 || sig.S.BitLen() > size*8 {
+		return nil, ErrSignatureInvalid
+	}
+	out := make([]byte, 2*size)
+	sig.R.FillBytes(out[:size])
+	sig.S.FillBytes(out[size:])
+	return out, nil
 }
 
 func (s *SigningMethodECDSA) Verify(partialToken []byte, signature []byte, key crypto.PublicKey) error {
 	ecdsaKey, ok := key.(*ecdsa.PublicKey)
-	if !ok {
+	if !ok || ecdsaKey.Curve != s.curve {
 		return ErrInvalidKeyType
 	}
 	if !s.hash.Available() {
 		return ErrHashUnavailable
 	}
-	hasher := s.hash.New()
-	hasher.Write(partialToken)
-	sig := Base64Decode(signature)
-	valid := ecdsa.VerifyASN1(ecdsaKey, hasher.Sum(nil), sig)
-	if !valid {
+	sig, err := base64Decode(signature)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+	size := s.keyBytes()
+	if len(sig) != 2*size {
+		return ErrSignatureInvalid
+	}
+	sigR := new(big.Int).SetBytes(sig[:size])
+	sigS := new(big.Int).SetBytes(sig[size:])
+	if !ecdsa.Verify(ecdsaKey, digest(s.hash, partialToken), sigR, sigS) {
 		return ErrSignatureInvalid
 	}
 	return nil
@@ -0,0 +1,97 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"testing"
+)
+
+// TestSigningMethodECDSA_RFC7515 verifies the ES256 example from RFC 7515,
+// appendix A.3.
+func TestSigningMethodECDSA_RFC7515(t *testing.T) {
+	key := `{"kty":"EC","crv":"P-256",
+		"x":"f83OJ3D2xF1Bg8vub9tLe1gHMzV76e8Tus9uPHvRVEU",
+		"y":"x_FEzRu9m36HLN_tue659LNpXW6pCyStikYjKIWI5a0",
+		"d":"jpsQnnGQmL-YBIffH1136cspYG6-0iY7X1fCE9-E9LI"}`
+	raw := RawToken(
+		"eyJhbGciOiJFUzI1NiJ9" +
+			".eyJpc3MiOiJqb2UiLA0KICJleHAiOjEzMDA4MTkzODAsDQogImh0dHA6Ly9leGFtcGxlLmNvbS9pc19yb290Ijp0cnVlfQ" +
+			".DtEhU3ljbEg8L38VWAfUAqOyKAM6-Xx-F4GawxaepmXFCgfTjDxw5djxLa8ISlSApmWQxfKTUJqPP3-Kg6NU1Q",
+	)
+	jwk, err := ParseJWK([]byte(key))
+	if err != nil {
+		t.Fatalf("error parsing jwk: %v", err)
+	}
+	pub, err := jwk.PublicKey()
+	if err != nil {
+		t.Fatalf("error decoding jwk: %v", err)
+	}
+	err = ES256.Verify(raw.SigningSection(), raw.Signature(), pub)
+	if err != nil {
+		t.Errorf("error verifying the example: %v", err)
+	}
+
+	// Signatures made by the method are in the same format
+	pri, err := jwk.PrivateKey()
+	if err != nil {
+		t.Fatalf("error decoding jwk: %v", err)
+	}
+	sig, err := ES256.Sign(raw.SigningSection(), pri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec, err := base64Decode(sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, 64, len(dec))
+	if err = ES256.Verify(raw.SigningSection(), sig, pub); err != nil {
+		t.Errorf("error verifying signature: %v", err)
+	}
+}
+
+func TestSigningMethodECDSA_SignatureSize(t *testing.T) {
+	tests := []struct {
+		method *SigningMethodECDSA
+		size   int
+	}{
+		{ES256, 64},
+		{ES384, 96},
+		{ES512, 132},
+	}
+	for _, tt := range tests {
+		keys := tt.method.GenerateKeyPair()
+		partialToken := []byte("eyJhbGciOiJFUzI1NiJ9.e30")
+		// Sign a few times, so R or S will be shorter than the curve
+		// size at least once in a while
+		for i := 0; i < 8; i++ {
+			sig, err := tt.method.Sign(partialToken, keys.PrivateKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dec, err := base64Decode(sig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(dec) != tt.size {
+				t.Fatalf("[%s] expected a %d byte signature, got %d", tt.method.Name(), tt.size, len(dec))
+			}
+			if err = tt.method.Verify(partialToken, sig, keys.PublicKey); err != nil {
+				t.Errorf("[%s] error verifying signature: %v", tt.method.Name(), err)
+			}
+		}
+
+		// ASN.1 encoded signatures, and signatures of the wrong size, are
+		// rejected
+		der, err := ecdsa.SignASN1(rand.Reader, keys.PrivateKey.(*ecdsa.PrivateKey), digest(tt.method.hash, partialToken))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, sig := range [][]byte{der, make([]byte, tt.size-1), make([]byte, tt.size)} {
+			err = tt.method.Verify(partialToken, Base64Encode(sig), keys.PublicKey)
+			if err != ErrSignatureInvalid {
+				t.Errorf("[%s] expected %v, got %v", tt.method.Name(), ErrSignatureInvalid, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+)
+
+// SigningMethodEdDSA implements the EdDSA family of signing methods.
+// Expects ed25519.PrivateKey (or any crypto.Signer holding an Ed25519 key)
+// for signing and ed25519.PublicKey for validation
+type SigningMethodEdDSA struct {
+	name string
+}
+
+var (
+	EdDSA *SigningMethodEdDSA
+)
+
+func init() {
+	EdDSA = &SigningMethodEdDSA{
+		name: "EdDSA",
+	}
+
+	RegisterSigningMethod(EdDSA.Name(), func() SigningMethod { return EdDSA })
+}
+
+func (s *SigningMethodEdDSA) Name() string {
+	return s.name
+}
+
+func (s *SigningMethodEdDSA) GenerateKeyPair() *KeyPair {
+	pub, pri, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil
+	}
+	return &KeyPair{
+		PrivateKey: pri,
+		PublicKey:  pub,
+	}
+}
+
+func (s *SigningMethodEdDSA) Sign(partialToken []byte, key crypto.PrivateKey) ([]byte, error) {
+	sig, err := s.signRaw(partialToken, key)
+	if err != nil {
+		return nil, err
+	}
+	return Base64Encode(sig), nil
+}
+
+func (s *SigningMethodEdDSA) signRaw(partialToken []byte, key crypto.PrivateKey) ([]byte, error) {
+	// Ed25519 hashes the message internally (using SHA-512), so
+	// unlike the other methods there is no hasher to set up here.
+	if edKey, ok := key.(ed25519.PrivateKey); ok {
+		if len(edKey) != ed25519.PrivateKeySize {
+			return nil, ErrInvalidKeyType
+		}
+		return ed25519.Sign(edKey, partialToken), nil
+	}
+	signer, err := signerFor(s, key)
+	if err != nil {
+		return nil, err
+	}
+	// A crypto.Signer signs the whole message for Ed25519, which is
+	// signalled by a zero hash
+	return signer.Sign(rand.Reader, partialToken, crypto.Hash(0))
+}
+
+func (s *SigningMethodEdDSA) Verify(partialToken []byte, signature []byte, key crypto.PublicKey) error {
+	edKey, ok := key.(ed25519.PublicKey)
+	if !ok || len(edKey) != ed25519.PublicKeySize {
+		return ErrInvalidKeyType
+	}
+	sig, err := base64Decode(signature)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+	if !ed25519.Verify(edKey, partialToken, sig) {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package jwt
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+)
+
+func TestSigningMethodEdDSA_SignAndVerify(t *testing.T) {
+	keys := EdDSA.GenerateKeyPair()
+	if keys == nil {
+		t.Fatal("error generating key pair")
+	}
+
+	method := GetSigningMethod("EdDSA")
+	if method != EdDSA {
+		t.Fatalf("expected registered EdDSA method, got %v", method)
+	}
+
+	tok, err := NewToken(method, nil, keys.PrivateKey)
+	if err != nil {
+		t.Fatalf("error signing token: %v", err)
+	}
+
+	err = method.Verify(tok.SigningSection(), tok.Signature(), keys.PublicKey)
+	if err != nil {
+		t.Errorf("error verifying token: %v", err)
+	}
+
+	// Verifying with a different key pair should fail
+	other := EdDSA.GenerateKeyPair()
+	err = method.Verify(tok.SigningSection(), tok.Signature(), other.PublicKey)
+	if err == nil {
+		t.Errorf("token verified using the wrong public key")
+	}
+
+	// Using a key of the wrong type should fail
+	_, err = method.Sign(tok.SigningSection(), hmacTestKey)
+	if err != ErrInvalidKeyType {
+		t.Errorf("expected %v, got %v", ErrInvalidKeyType, err)
+	}
+}
+
+func TestSigningMethodEdDSA_PEM(t *testing.T) {
+	keys := EdDSA.GenerateKeyPair()
+
+	// Encode the key pair to PEM
+	der, err := x509.MarshalPKCS8PrivateKey(keys.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	priPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	der, err = x509.MarshalPKIXPublicKey(keys.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	// Parse the key pair back in
+	pri, err := ParsePrivateKeyFromPEM(priPEM)
+	if err != nil {
+		t.Fatalf("error parsing private key: %v", err)
+	}
+	pub, err := ParsePublicKeyFromPEM(pubPEM)
+	if err != nil {
+		t.Fatalf("error parsing public key: %v", err)
+	}
+
+	// Make sure the parsed keys can be used with the manager
+	tm := NewTokenManager(EdDSA, &KeyPair{PrivateKey: pri, PublicKey: pub})
+	tok, err := tm.GenerateToken(
+		&RegisteredClaims{
+			Issuer:         "jon doe",
+			ExpirationTime: NumericDate(time.Now().Add(time.Hour).Unix()),
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tm.ValidateToken(tok)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func BenchmarkEdDSASigning(b *testing.B) {
+	benchmarkSigning(b, EdDSA, EdDSA.GenerateKeyPair().PrivateKey)
+}
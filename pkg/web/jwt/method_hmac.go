@@ -1,10 +1,14 @@
 package jwt
 
 import (
+	"bytes"
+	"container/list"
 	"crypto"
 	"crypto/hmac"
 	"crypto/rand"
-	"encoding/base64"
+	"crypto/sha256"
+	"hash"
+	"sync"
 )
 
 // SigningMethodHMAC implements the HMAC-SHA family of signing methods.
@@ -12,8 +16,25 @@ import (
 type SigningMethodHMAC struct {
 	name string
 	hash crypto.Hash
+
+	mu    sync.Mutex
+	pools map[[sha256.Size]byte]*list.Element // keyed by a hash of the secret
+	lru   *list.List                          // most recently used first
+}
+
+// hmacPool is a pool of hashers for a single secret.
+type hmacPool struct {
+	id   [sha256.Size]byte
+	pool sync.Pool
 }
 
+// maxHMACPools is the maximum number of secrets each HMAC signing method
+// keeps a pool of hashers for. Once the limit is reached, the pool of the
+// least recently used secret is dropped, so a verifier handed an endless
+// stream of secrets (e.g. by a KeyFunc) does not grow without bound, and
+// secrets that are no longer used are not kept around.
+const maxHMACPools = 64
+
 var (
 	HS256 *SigningMethodHMAC
 	HS384 *SigningMethodHMAC
@@ -49,42 +70,100 @@ func (s *SigningMethodHMAC) GenerateKeyPair() *KeyPair {
 	if err != nil {
 		return nil
 	}
-	base64.RawURLEncoding.Encode(buf, buf)
+	key := Base64Encode(buf)
 	return &KeyPair{
-		PrivateKey: buf,
-		PublicKey:  buf,
+		PrivateKey: key,
+		PublicKey:  key,
 	}
 }
 
 func (s *SigningMethodHMAC) Sign(partialToken []byte, key crypto.PrivateKey) ([]byte, error) {
+	sig, err := s.signRaw(partialToken, key)
+	if err != nil {
+		return nil, err
+	}
+	return Base64Encode(sig), nil
+}
+
+func (s *SigningMethodHMAC) signRaw(partialToken []byte, key crypto.PrivateKey) ([]byte, error) {
 	k, ok := key.([]byte)
-	if !ok {
+	if !ok || !isSymmetricKey(k) {
 		return nil, ErrInvalidKeyType
 	}
 	if !s.hash.Available() {
 		return nil, ErrHashUnavailable
 	}
-	hasher := hmac.New(s.hash.New, k)
-	hasher.Write(partialToken)
-	return Base64Encode(hasher.Sum(nil)), nil
+	return s.mac(k, partialToken), nil
 }
 
 func (s *SigningMethodHMAC) Verify(partialToken []byte, signature []byte, key crypto.PublicKey) error {
 	k, ok := key.([]byte)
-	if !ok {
+	if !ok || !isSymmetricKey(k) {
 		return ErrInvalidKeyType
 	}
 	if !s.hash.Available() {
 		return ErrHashUnavailable
 	}
-	sig := Base64Decode(signature)
+	sig, err := base64Decode(signature)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
 	// The HMAC signing method is a symmetric one. We will validate the
 	// signature by reproducing the signature from the partial token,
 	// then compare it against the provided signature.
-	hasher := hmac.New(s.hash.New, k)
-	hasher.Write(partialToken)
-	if !hmac.Equal(sig, hasher.Sum(nil)) {
+	if !hmac.Equal(sig, s.mac(k, partialToken)) {
 		return ErrSignatureInvalid
 	}
 	return nil
 }
+
+// mac returns the HMAC of the data using a pooled hasher for the secret.
+// Setting up a hasher hashes the padded secret, so reusing hashers saves
+// both that work and the allocations on every token.
+func (s *SigningMethodHMAC) mac(k, data []byte) []byte {
+	pool := s.pool(k)
+	hasher := pool.Get().(hash.Hash)
+	hasher.Write(data)
+	sum := hasher.Sum(nil)
+	hasher.Reset()
+	pool.Put(hasher)
+	return sum
+}
+
+// pool returns the pool of hashers for the secret, creating it if needed.
+// When there are too many pools, the least recently used one is dropped.
+func (s *SigningMethodHMAC) pool(k []byte) *sync.Pool {
+	id := sha256.Sum256(k)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, found := s.pools[id]; found {
+		s.lru.MoveToFront(e)
+		return &e.Value.(*hmacPool).pool
+	}
+	if s.pools == nil {
+		s.pools = make(map[[sha256.Size]byte]*list.Element)
+		s.lru = list.New()
+	}
+	if s.lru.Len() >= maxHMACPools {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.pools, oldest.Value.(*hmacPool).id)
+	}
+	// Copy the secret, the caller is free to modify it afterwards
+	secret := append([]byte(nil), k...)
+	p := &hmacPool{id: id}
+	p.pool.New = func() any {
+		return hmac.New(s.hash.New, secret)
+	}
+	s.pools[id] = s.lru.PushFront(p)
+	return &p.pool
+}
+
+// isSymmetricKey reports whether the key can be used as an HMAC secret. An
+// empty key is rejected, and so is anything that looks like a PEM encoded
+// key. The latter prevents the classic key confusion attack, where a token
+// signed with HS256 using the PEM encoded RSA public key as the secret is
+// accepted by a verifier that hands the same PEM bytes to every method.
+func isSymmetricKey(k []byte) bool {
+	return len(k) > 0 && !bytes.HasPrefix(bytes.TrimSpace(k), []byte("-----BEGIN"))
+}
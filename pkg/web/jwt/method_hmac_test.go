@@ -2,6 +2,9 @@ package jwt
 
 import (
 	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"fmt"
 	"testing"
 )
 
@@ -10,7 +13,7 @@ var hmacTestKey = []byte("0hmac1super2secret3code4right5here6buddy7so8what9you10
 var testClaims = RegisteredClaims{
 	Issuer:         "joe",
 	Subject:        "history",
-	Audience:       "your mom",
+	Audience:       Audience{"your mom"},
 	ExpirationTime: 1300819380,
 	NotBeforeTime:  0,
 	IssuedAtTime:   0,
@@ -119,3 +122,46 @@ func BenchmarkHS384Signing(b *testing.B) {
 func BenchmarkHS512Signing(b *testing.B) {
 	benchmarkSigning(b, HS512, hmacTestKey)
 }
+
+func TestSigningMethodHMAC_Pools(t *testing.T) {
+	method := &SigningMethodHMAC{name: "HS256", hash: crypto.SHA256}
+	sign := func(key []byte) {
+		t.Helper()
+		sig, err := method.Sign([]byte("a.b"), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = method.Verify([]byte("a.b"), sig, key); err != nil {
+			t.Errorf("error verifying signature: %v", err)
+		}
+	}
+
+	// Secrets beyond the pool limit still sign and verify, and push out
+	// the pools of the least recently used secrets
+	first := []byte("secret-first")
+	sign(first)
+	for i := 0; i < maxHMACPools+8; i++ {
+		sign([]byte(fmt.Sprintf("secret-%d", i)))
+		// Keep the first secret in use
+		sign(first)
+	}
+	assert(t, maxHMACPools, len(method.pools))
+	assert(t, maxHMACPools, method.lru.Len())
+	if _, found := method.pools[sha256.Sum256(first)]; !found {
+		t.Errorf("expected the pool of a recently used secret to be kept")
+	}
+	if _, found := method.pools[sha256.Sum256([]byte("secret-0"))]; found {
+		t.Errorf("expected the pool of the least recently used secret to be dropped")
+	}
+
+	// The pool holds a copy of the secret
+	key := []byte("mutable-secret")
+	sig, err := method.Sign([]byte("a.b"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key[0] = 'M'
+	if err = method.Verify([]byte("a.b"), sig, key); err == nil {
+		t.Errorf("expected the signature to be invalid for a modified secret")
+	}
+}
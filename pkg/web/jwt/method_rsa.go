@@ -7,7 +7,8 @@ import (
 )
 
 // SigningMethodRSA implements the RSA family of signing methods.
-// Expects *rsa.PrivateKey for signing and *rsa.PublicKey for validation
+// Expects *rsa.PrivateKey (or any crypto.Signer holding an RSA key) for
+// signing and *rsa.PublicKey for validation
 type SigningMethodRSA struct {
 	name string
 	hash crypto.Hash
@@ -56,20 +57,26 @@ func (s *SigningMethodRSA) GenerateKeyPair() *KeyPair {
 }
 
 func (s *SigningMethodRSA) Sign(partialToken []byte, key crypto.PrivateKey) ([]byte, error) {
-	rsaKey, ok := key.(*rsa.PrivateKey)
-	if !ok {
-		return nil, ErrInvalidKeyType
+	sig, err := s.signRaw(partialToken, key)
+	if err != nil {
+		return nil, err
 	}
+	return Base64Encode(sig), nil
+}
+
+func (s *SigningMethodRSA) signRaw(partialToken []byte, key crypto.PrivateKey) ([]byte, error) {
 	if !s.hash.Available() {
 		return nil, ErrHashUnavailable
 	}
-	hasher := s.hash.New()
-	hasher.Write(partialToken)
-	sig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, s.hash, hasher.Sum(nil))
+	if rsaKey, ok := key.(*rsa.PrivateKey); ok {
+		return rsa.SignPKCS1v15(rand.Reader, rsaKey, s.hash, digest(s.hash, partialToken))
+	}
+	signer, err := signerFor(s, key)
 	if err != nil {
 		return nil, err
 	}
-	return Base64Encode(sig), nil
+	// A crypto.Signer uses PKCS #1 v1.5 when opts is a crypto.Hash
+	return signer.Sign(rand.Reader, digest(s.hash, partialToken), s.hash)
 }
 
 func (s *SigningMethodRSA) Verify(partialToken []byte, signature []byte, key crypto.PublicKey) error {
@@ -80,8 +87,9 @@ func (s *SigningMethodRSA) Verify(partialToken []byte, signature []byte, key cry
 	if !s.hash.Available() {
 		return ErrHashUnavailable
 	}
-	hasher := s.hash.New()
-	hasher.Write(partialToken)
-	sig := Base64Decode(signature)
-	return rsa.VerifyPKCS1v15(rsaKey, s.hash, hasher.Sum(nil), sig)
+	sig, err := base64Decode(signature)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+	return rsa.VerifyPKCS1v15(rsaKey, s.hash, digest(s.hash, partialToken), sig)
 }
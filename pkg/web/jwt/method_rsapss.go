@@ -6,8 +6,9 @@ import (
 	"crypto/rsa"
 )
 
-// SigningMethodRSA implements the RSAPSS family of signing methods.
-// Expects *rsa.PrivateKey for signing and *rsa.PublicKey for validation
+// SigningMethodRSAPSS implements the RSAPSS family of signing methods.
+// Expects *rsa.PrivateKey (or any crypto.Signer holding an RSA key) for
+// signing and *rsa.PublicKey for validation
 type SigningMethodRSAPSS struct {
 	name string
 	hash crypto.Hash
@@ -24,17 +25,17 @@ func init() {
 	PS256 = &SigningMethodRSAPSS{
 		name: "PS256",
 		hash: crypto.SHA256,
-		opts: &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash},
+		opts: &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256},
 	}
 	PS384 = &SigningMethodRSAPSS{
 		name: "PS384",
 		hash: crypto.SHA384,
-		opts: &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash},
+		opts: &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA384},
 	}
 	PS512 = &SigningMethodRSAPSS{
 		name: "PS512",
 		hash: crypto.SHA512,
-		opts: &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash},
+		opts: &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA512},
 	}
 
 	RegisterSigningMethod(PS256.Name(), func() SigningMethod { return PS256 })
@@ -47,7 +48,7 @@ func (s *SigningMethodRSAPSS) Name() string {
 }
 
 func (s *SigningMethodRSAPSS) GenerateKeyPair() *KeyPair {
-	key, err := rsa.GenerateKey(rand.Reader, s.hash.Size()*8)
+	key, err := rsa.GenerateKey(rand.Reader, rsaBits)
 	if err != nil {
 		panic(err)
 	}
@@ -58,20 +59,26 @@ func (s *SigningMethodRSAPSS) GenerateKeyPair() *KeyPair {
 }
 
 func (s *SigningMethodRSAPSS) Sign(partialToken []byte, key crypto.PrivateKey) ([]byte, error) {
-	rsaKey, ok := key.(*rsa.PrivateKey)
-	if !ok {
-		return nil, ErrInvalidKeyType
+	sig, err := s.signRaw(partialToken, key)
+	if err != nil {
+		return nil, err
 	}
+	return Base64Encode(sig), nil
+}
+
+func (s *SigningMethodRSAPSS) signRaw(partialToken []byte, key crypto.PrivateKey) ([]byte, error) {
 	if !s.hash.Available() {
 		return nil, ErrHashUnavailable
 	}
-	hasher := s.hash.New()
-	hasher.Write(partialToken)
-	sig, err := rsa.SignPSS(rand.Reader, rsaKey, s.hash, hasher.Sum(nil), s.opts)
+	if rsaKey, ok := key.(*rsa.PrivateKey); ok {
+		return rsa.SignPSS(rand.Reader, rsaKey, s.hash, digest(s.hash, partialToken), s.opts)
+	}
+	signer, err := signerFor(s, key)
 	if err != nil {
 		return nil, err
 	}
-	return Base64Encode(sig), nil
+	// A crypto.Signer uses PSS when opts is a *rsa.PSSOptions
+	return signer.Sign(rand.Reader, digest(s.hash, partialToken), s.opts)
 }
 
 func (s *SigningMethodRSAPSS) Verify(partialToken []byte, signature []byte, key crypto.PublicKey) error {
@@ -82,8 +89,9 @@ func (s *SigningMethodRSAPSS) Verify(partialToken []byte, signature []byte, key
 	if !s.hash.Available() {
 		return ErrHashUnavailable
 	}
-	hasher := s.hash.New()
-	hasher.Write(partialToken)
-	sig := Base64Decode(signature)
-	return rsa.VerifyPSS(rsaKey, s.hash, hasher.Sum(nil), sig, s.opts)
+	sig, err := base64Decode(signature)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+	return rsa.VerifyPSS(rsaKey, s.hash, digest(s.hash, partialToken), sig, s.opts)
 }
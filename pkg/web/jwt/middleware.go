@@ -0,0 +1,288 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// MiddlewareOptions holds the configuration for TokenManager.Middleware.
+// Any fields left as their zero value will use the defaults.
+type MiddlewareOptions struct {
+
+	// Extractor is used to extract the token from the request. If it
+	// is set, the DisableHeader, CookieName, QueryParam and FormField
+	// options are ignored.
+	Extractor Extractor
+
+	// DisableHeader disables looking for a bearer token in the
+	// Authorization header, which is otherwise always checked first.
+	DisableHeader bool
+
+	// CookieName is the name of a cookie holding the token. If it is
+	// left empty, cookies are not checked.
+	CookieName string
+
+	// QueryParam is the name of a query string parameter holding the
+	// token. If it is left empty, the query string is not checked.
+	QueryParam string
+
+	// FormField is the name of a form field holding the token. If it
+	// is left empty, the form body is not checked.
+	FormField string
+
+	// Optional allows requests without a token to pass through to the
+	// next handler. Requests with an invalid token are still rejected.
+	Optional bool
+
+	// DPoP enables the DPoP authorization scheme (RFC 9449). Tokens sent
+	// using the DPoP scheme must come with a proof that is verified by
+	// the DPoPVerifier, and they must be bound to the key of the proof.
+	DPoP *DPoPVerifier
+
+	// RequireDPoP rejects requests that do not use the DPoP scheme, so
+	// bearer tokens are never accepted. It requires DPoP to be set.
+	RequireDPoP bool
+
+	// Realm is the realm reported in the WWW-Authenticate header.
+	Realm string
+
+	// Requirements holds additional checks the validated token must pass
+	// for the request to be allowed through. A failed requirement results
+	// in a 403 instead of a 401.
+	Requirements []ClaimRequirement
+
+	// ErrorHandler writes the error response. It defaults to
+	// WriteAuthError.
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, err *AuthError)
+}
+
+// ClaimRequirement is a check that is run against the claims of a
+// validated token. It should return a non-nil error if the claims do not
+// meet the requirement.
+type ClaimRequirement func(claims MapClaims) error
+
+// RequireClaim returns a ClaimRequirement that checks that the claim with
+// the provided name is present and equal to the provided value. Since the
+// claims are decoded from JSON, numbers can be provided as any numeric type
+// and slices as any slice type. Nested claims can be referred to using a
+// dotted path, such as "address.country".
+func RequireClaim(name string, value any) ClaimRequirement {
+	return func(claims MapClaims) error {
+		v, found := lookupClaim(claims, name)
+		if !found {
+			return fmt.Errorf("%w: %q", ErrTokenClaimNotFound, name)
+		}
+		if !claimEqual(v, value) {
+			return fmt.Errorf("%w: %q", ErrTokenClaimMismatch, name)
+		}
+		return nil
+	}
+}
+
+// claimEqual reports whether the claim is equal to the value. The value is
+// normalized the same way as a decoded claim, so numbers, slices and
+// structs can be compared as well.
+func claimEqual(claim, value any) bool {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return false
+	}
+	var normalized any
+	if err = json.Unmarshal(b, &normalized); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(claim, normalized)
+}
+
+// RFC 6750 and RFC 9449 error codes
+const (
+	AuthErrorInvalidRequest    = "invalid_request"
+	AuthErrorInvalidToken      = "invalid_token"
+	AuthErrorInsufficientScope = "insufficient_scope"
+	AuthErrorInvalidDPoPProof  = "invalid_dpop_proof"
+)
+
+// AuthError is the error that is handed to the error handler of the
+// middleware. It carries the status code and RFC 6750 error code that
+// should be used in the response.
+type AuthError struct {
+	StatusCode int
+	Code       string
+	Realm      string
+	Err        error
+}
+
+func (e *AuthError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// WriteAuthError writes an error response as described in RFC 6750,
+// section 3, including the WWW-Authenticate header. If the token failed
+// validation, the failed checks are listed in the error_description.
+func WriteAuthError(w http.ResponseWriter, r *http.Request, err *AuthError) {
+	var params []string
+	if err.Realm != "" {
+		params = append(params, fmt.Sprintf("realm=%q", err.Realm))
+	}
+	if err.Code != "" {
+		params = append(params, fmt.Sprintf("error=%q", err.Code))
+	}
+	// Report which checks failed, so clients can tell an expired token
+	// from a forged one.
+	var verr *ValidationError
+	if errors.As(err.Err, &verr) {
+		params = append(params, fmt.Sprintf("error_description=%q", "token failed validation: "+verr.Checks.String()))
+	}
+	// Report the scopes that are required (RFC 6750, section 3)
+	var serr *ScopeError
+	if errors.As(err.Err, &serr) {
+		params = append(params, fmt.Sprintf("scope=%q", strings.Join(serr.Scopes, " ")))
+	}
+	// Requests that failed DPoP are challenged to use the DPoP scheme,
+	// listing the algorithms accepted for proofs (RFC 9449, section 7.1)
+	challenge := "Bearer"
+	var derr *DPoPError
+	if errors.As(err.Err, &derr) {
+		challenge = "DPoP"
+		if len(derr.Algs) > 0 {
+			params = append(params, fmt.Sprintf("algs=%q", strings.Join(derr.Algs, " ")))
+		}
+	}
+	if len(params) > 0 {
+		challenge += " " + strings.Join(params, ", ")
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	http.Error(w, http.StatusText(err.StatusCode), err.StatusCode)
+}
+
+type contextKey struct{}
+
+// ContextWithToken returns a copy of the context holding the token.
+func ContextWithToken(ctx context.Context, token *Token) context.Context {
+	return context.WithValue(ctx, contextKey{}, token)
+}
+
+// TokenFromContext returns the validated token placed in the context by
+// the middleware, and reports whether there was one.
+func TokenFromContext(ctx context.Context) (*Token, bool) {
+	token, ok := ctx.Value(contextKey{}).(*Token)
+	return token, ok
+}
+
+// extractor returns the Extractor to use, building one from the options
+// if none was provided. The places enabled by the options are checked in
+// the following order: header, cookie, query string and form body.
+func (o *MiddlewareOptions) extractor() Extractor {
+	if o.Extractor != nil {
+		return o.Extractor
+	}
+	var e MultiExtractor
+	if !o.DisableHeader {
+		e = append(e, HeaderExtractor{})
+	}
+	if o.CookieName != "" {
+		e = append(e, CookieExtractor{Name: o.CookieName})
+	}
+	if o.QueryParam != "" {
+		e = append(e, QueryExtractor{Param: o.QueryParam})
+	}
+	if o.FormField != "" {
+		e = append(e, FormExtractor{Field: o.FormField})
+	}
+	return e
+}
+
+// Middleware returns http middleware that validates the token carried by
+// each request. Valid tokens are placed in the request context, where
+// they can be retrieved using TokenFromContext. Requests without a valid
+// token are rejected with a 401, and requests with a token that does not
+// meet the requirements are rejected with a 403. The options may be nil.
+func (m *TokenManager) Middleware(opts *MiddlewareOptions) func(http.Handler) http.Handler {
+	if opts == nil {
+		opts = &MiddlewareOptions{}
+	}
+	errorHandler := opts.ErrorHandler
+	if errorHandler == nil {
+		errorHandler = WriteAuthError
+	}
+	extractor := opts.extractor()
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				var token *Token
+				if opts.DPoP != nil {
+					raw, err := dpopExtractor.Extract(r)
+					if err == nil {
+						token, err = m.validateDPoPRequest(opts.DPoP, r, raw)
+						if err != nil {
+							code := AuthErrorInvalidToken
+							if errors.Is(err, ErrDPoPProofMissing) || errors.Is(err, ErrDPoPProofInvalid) || errors.Is(err, ErrDPoPProofReplayed) {
+								code = AuthErrorInvalidDPoPProof
+							}
+							errorHandler(w, r, &AuthError{http.StatusUnauthorized, code, opts.Realm, opts.dpopError(err)})
+							return
+						}
+					}
+				}
+				if token == nil {
+					raw, err := extractor.Extract(r)
+					if opts.RequireDPoP {
+						raw, err = nil, ErrNoTokenInRequest
+					}
+					if err != nil {
+						if opts.Optional {
+							next.ServeHTTP(w, r)
+							return
+						}
+						if opts.RequireDPoP {
+							err = opts.dpopError(err)
+						}
+						// No error code when the request lacks any
+						// authentication information (RFC 6750, section 3.1)
+						errorHandler(w, r, &AuthError{http.StatusUnauthorized, "", opts.Realm, err})
+						return
+					}
+					token, err = m.ValidateToken(raw)
+					if err == nil && dpopBinding(token.Payload) != "" {
+						// A token bound to a DPoP key must never be
+						// accepted as a bearer token (RFC 9449, section 7.2)
+						err = opts.dpopError(ErrDPoPBindingMismatch)
+					}
+					if err != nil {
+						errorHandler(w, r, &AuthError{http.StatusUnauthorized, AuthErrorInvalidToken, opts.Realm, err})
+						return
+					}
+				}
+				for _, req := range opts.Requirements {
+					if err := req(token.Payload); err != nil {
+						errorHandler(w, r, &AuthError{http.StatusForbidden, AuthErrorInsufficientScope, opts.Realm, err})
+						return
+					}
+				}
+				next.ServeHTTP(w, r.WithContext(ContextWithToken(r.Context(), token)))
+			},
+		)
+	}
+}
+
+// dpopExtractor extracts tokens sent using the DPoP authorization scheme.
+var dpopExtractor = HeaderExtractor{Scheme: "DPoP"}
+
+// dpopError wraps the error in a DPoPError, so the client is challenged to
+// use the DPoP scheme.
+func (o *MiddlewareOptions) dpopError(err error) error {
+	var algs []string
+	if o.DPoP != nil {
+		algs = o.DPoP.algorithms()
+	}
+	return &DPoPError{Algs: algs, Err: err}
+}
@@ -0,0 +1,206 @@
+package jwt
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenManager_Middleware(t *testing.T) {
+	tm := NewTokenManager(HS256, HS256.GenerateKeyPair())
+	raw, err := tm.GenerateToken(
+		MapClaims{
+			"sub":  "jon doe",
+			"role": "admin",
+			"exp":  NumericDateNow().Add(time.Hour),
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := tm.GenerateToken(
+		MapClaims{
+			"sub": "jon doe",
+			"exp": NumericDateNow().Add(-time.Hour),
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := NewTokenManager(HS256, HS256.GenerateKeyPair())
+	bad, err := other.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			tok, ok := TokenFromContext(r.Context())
+			if !ok {
+				w.Write([]byte("anonymous"))
+				return
+			}
+			sub, _ := tok.Payload.GetSUB()
+			w.Write([]byte(sub))
+		},
+	)
+
+	opts := &MiddlewareOptions{
+		CookieName: "session",
+		QueryParam: "access_token",
+		FormField:  "access_token",
+		Realm:      "example",
+	}
+	handler := tm.Middleware(opts)(next)
+
+	tests := []struct {
+		name      string
+		req       func() *http.Request
+		status    int
+		challenge string
+	}{
+		{
+			"header",
+			func() *http.Request {
+				r := httptest.NewRequest("GET", "/", nil)
+				r.Header.Set("Authorization", "Bearer "+string(raw))
+				return r
+			},
+			http.StatusOK, "",
+		},
+		{
+			"cookie",
+			func() *http.Request {
+				r := httptest.NewRequest("GET", "/", nil)
+				r.AddCookie(&http.Cookie{Name: "session", Value: string(raw)})
+				return r
+			},
+			http.StatusOK, "",
+		},
+		{
+			"query",
+			func() *http.Request {
+				return httptest.NewRequest("GET", "/?access_token="+string(raw), nil)
+			},
+			http.StatusOK, "",
+		},
+		{
+			"form",
+			func() *http.Request {
+				body := url.Values{"access_token": {string(raw)}}.Encode()
+				r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+				r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				return r
+			},
+			http.StatusOK, "",
+		},
+		{
+			"missing",
+			func() *http.Request {
+				return httptest.NewRequest("GET", "/", nil)
+			},
+			http.StatusUnauthorized, `Bearer realm="example"`,
+		},
+		{
+			"invalid",
+			func() *http.Request {
+				r := httptest.NewRequest("GET", "/", nil)
+				r.Header.Set("Authorization", "Bearer "+string(bad))
+				return r
+			},
+			http.StatusUnauthorized, `Bearer realm="example", error="invalid_token"`,
+		},
+		{
+			"expired",
+			func() *http.Request {
+				r := httptest.NewRequest("GET", "/", nil)
+				r.Header.Set("Authorization", "Bearer "+string(expired))
+				return r
+			},
+			http.StatusUnauthorized,
+			`Bearer realm="example", error="invalid_token", error_description="token failed validation: exp"`,
+		},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, tt.req())
+		if w.Code != tt.status {
+			t.Errorf("[%s] wanted status %d, got %d", tt.name, tt.status, w.Code)
+		}
+		assert(t, tt.challenge, w.Header().Get("WWW-Authenticate"))
+		if tt.status == http.StatusOK {
+			assert(t, "jon doe", w.Body.String())
+		}
+	}
+}
+
+func TestTokenManager_MiddlewareRequirements(t *testing.T) {
+	tm := NewTokenManager(HS256, HS256.GenerateKeyPair())
+	raw, err := tm.GenerateToken(
+		MapClaims{
+			"role":   "user",
+			"level":  3,
+			"groups": []string{"staff", "ops"},
+			"exp":    NumericDateNow().Add(time.Hour),
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		req    ClaimRequirement
+		status int
+	}{
+		{RequireClaim("role", "user"), http.StatusOK},
+		{RequireClaim("role", "admin"), http.StatusForbidden},
+		{RequireClaim("missing", "admin"), http.StatusForbidden},
+		{RequireClaim("level", 3), http.StatusOK},
+		{RequireClaim("level", int64(3)), http.StatusOK},
+		{RequireClaim("level", 4), http.StatusForbidden},
+		{RequireClaim("groups", []string{"staff", "ops"}), http.StatusOK},
+		{RequireClaim("groups", []any{"staff", "ops"}), http.StatusOK},
+		{RequireClaim("groups", []string{"ops"}), http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		handler := tm.Middleware(&MiddlewareOptions{Requirements: []ClaimRequirement{tt.req}})(next)
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", "Bearer "+string(raw))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != tt.status {
+			t.Errorf("wanted status %d, got %d", tt.status, w.Code)
+		}
+		if tt.status == http.StatusForbidden {
+			assert(t, `Bearer error="insufficient_scope"`, w.Header().Get("WWW-Authenticate"))
+		}
+	}
+
+	// Optional should let requests without a token through
+	handler := tm.Middleware(&MiddlewareOptions{Optional: true})(next)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("wanted status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	// Custom error handlers should be called
+	var called bool
+	handler = tm.Middleware(
+		&MiddlewareOptions{
+			ErrorHandler: func(w http.ResponseWriter, r *http.Request, err *AuthError) {
+				called = true
+				w.WriteHeader(err.StatusCode)
+			},
+		},
+	)(next)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if !called || w.Code != http.StatusUnauthorized {
+		t.Errorf("expected custom error handler to be called with a 401")
+	}
+}
@@ -0,0 +1,102 @@
+package oidc
+
+import (
+	"crypto"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/scottcagno/webslinger/pkg/web/jwt"
+)
+
+// IDTokenClaims holds the claims of an ID token, as described in OpenID
+// Connect Core 1.0, section 2. Any other claims, such as the standard
+// profile claims, can be decoded using Claims.
+type IDTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce           string          `json:"nonce,omitempty"`
+	AuthorizedParty string          `json:"azp,omitempty"`
+	AccessTokenHash string          `json:"at_hash,omitempty"`
+	CodeHash        string          `json:"c_hash,omitempty"`
+	AuthTime        jwt.NumericDate `json:"auth_time,omitempty"`
+	ACR             string          `json:"acr,omitempty"`
+	AMR             []string        `json:"amr,omitempty"`
+	SessionID       string          `json:"sid,omitempty"`
+
+	// raw holds the decoded payload, so any other claims can be decoded
+	// on demand.
+	raw json.RawMessage
+}
+
+// UnmarshalJSON decodes the claims, keeping a copy of the payload for
+// Claims.
+func (c *IDTokenClaims) UnmarshalJSON(b []byte) error {
+	type plain IDTokenClaims
+	err := json.Unmarshal(b, (*plain)(c))
+	if err != nil {
+		return err
+	}
+	c.raw = append(c.raw[:0], b...)
+	return nil
+}
+
+// Claims decodes the full payload of the token into v, which makes any
+// claims that are not part of IDTokenClaims available.
+func (c *IDTokenClaims) Claims(v any) error {
+	if c.raw == nil {
+		return jwt.ErrTokenClaimNotFound
+	}
+	return json.Unmarshal(c.raw, v)
+}
+
+// hashFor returns the hash used for the at_hash and c_hash claims of tokens
+// signed using alg. It is the hash used by the signing algorithm, and
+// SHA-512 for EdDSA (which is only specified for Ed25519).
+func hashFor(alg string) (crypto.Hash, bool) {
+	if alg == "EdDSA" {
+		return crypto.SHA512, true
+	}
+	if len(alg) != 5 {
+		return 0, false
+	}
+	switch alg[2:] {
+	case "256":
+		return crypto.SHA256, true
+	case "384":
+		return crypto.SHA384, true
+	case "512":
+		return crypto.SHA512, true
+	}
+	return 0, false
+}
+
+// TokenHash computes the at_hash or c_hash value of an access token or an
+// authorization code, for an ID token signed using alg. It is the base64url
+// encoding of the left-most half of the hash of the value.
+func TokenHash(alg, value string) (string, error) {
+	hash, ok := hashFor(alg)
+	if !ok {
+		return "", ErrHashUnsupported
+	}
+	h := hash.New()
+	h.Write([]byte(value))
+	sum := h.Sum(nil)
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2]), nil
+}
+
+// verifyHash checks that the hash claim matches the value.
+func verifyHash(alg, claim, value string) error {
+	if claim == "" {
+		return jwt.ErrTokenClaimNotFound
+	}
+	expected, err := TokenHash(alg, value)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(claim), []byte(expected)) != 1 {
+		return ErrHashMismatch
+	}
+	return nil
+}
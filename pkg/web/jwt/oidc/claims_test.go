@@ -0,0 +1,54 @@
+package oidc
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/scottcagno/webslinger/pkg/web/jwt"
+)
+
+func TestTokenHash(t *testing.T) {
+	// OpenID Connect Core 1.0, appendix A.3
+	got, err := TokenHash("RS256", "jHkWEdUXMU1BwAsC4vtUsZwnNvTIxEl0z9K3vx5KF0Y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "77QmUPtjPfzWtF2AnpK9RQ"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	if _, err = TokenHash("HS1", "x"); !errors.Is(err, ErrHashUnsupported) {
+		t.Errorf("expected %v, got %v", ErrHashUnsupported, err)
+	}
+	if err = verifyHash("ES384", "", "x"); !errors.Is(err, jwt.ErrTokenClaimNotFound) {
+		t.Errorf("expected %v, got %v", jwt.ErrTokenClaimNotFound, err)
+	}
+	h, _ := TokenHash("EdDSA", "code")
+	if err = verifyHash("EdDSA", h, "code"); err != nil {
+		t.Errorf("expected the hash to match, got %v", err)
+	}
+	if err = verifyHash("EdDSA", h, "other"); !errors.Is(err, ErrHashMismatch) {
+		t.Errorf("expected %v, got %v", ErrHashMismatch, err)
+	}
+}
+
+func TestIDTokenClaims_Claims(t *testing.T) {
+	var c IDTokenClaims
+	err := json.Unmarshal([]byte(`{"sub":"alice","nonce":"n-0S6","auth_time":1311280969,"email":"alice@example.com"}`), &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Subject != "alice" || c.Nonce != "n-0S6" || c.AuthTime != 1311280969 {
+		t.Errorf("unexpected claims: %+v", c)
+	}
+	var profile struct {
+		Email string `json:"email"`
+	}
+	if err = c.Claims(&profile); err != nil {
+		t.Fatal(err)
+	}
+	if profile.Email != "alice@example.com" {
+		t.Errorf("expected the email claim, got %q", profile.Email)
+	}
+}
@@ -0,0 +1,158 @@
+// Package oidc validates OpenID Connect ID tokens. It builds on the
+// jwt.Validator, adding the checks from OpenID Connect Core 1.0 (nonce,
+// azp, auth_time, at_hash and c_hash), and discovers the keys of a
+// provider using OpenID Connect Discovery 1.0.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/scottcagno/webslinger/pkg/web/jwt"
+)
+
+// DiscoveryPath is the well-known path, relative to the issuer, that the
+// provider metadata is published at.
+const DiscoveryPath = "/.well-known/openid-configuration"
+
+// maxDiscoveryBytes is the largest discovery document that will be read.
+const maxDiscoveryBytes = 1 << 20
+
+var (
+	ErrDiscovery        = errors.New("oidc: failed to fetch provider metadata")
+	ErrIssuerMismatch   = errors.New("oidc: issuer does not match the provider metadata")
+	ErrNoJWKSURI        = errors.New("oidc: provider metadata does not contain a jwks_uri")
+	ErrNonceMismatch    = errors.New("oidc: nonce does not match")
+	ErrInvalidAZP       = errors.New("oidc: invalid authorized party")
+	ErrNoClientID       = errors.New("oidc: client id must be set unless the client id check is skipped")
+	ErrAuthTimeExceeded = errors.New("oidc: authentication is older than max_age")
+	ErrHashMismatch     = errors.New("oidc: hash does not match")
+	ErrHashUnsupported  = errors.New("oidc: no hash function for the signing algorithm")
+)
+
+// ProviderMetadata holds the provider metadata published in the discovery
+// document, as described in OpenID Connect Discovery 1.0, section 3. Only
+// the members that are relevant to validating tokens are included.
+type ProviderMetadata struct {
+	Issuer                           string   `json:"issuer"`
+	AuthorizationEndpoint            string   `json:"authorization_endpoint,omitempty"`
+	TokenEndpoint                    string   `json:"token_endpoint,omitempty"`
+	UserinfoEndpoint                 string   `json:"userinfo_endpoint,omitempty"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	RevocationEndpoint               string   `json:"revocation_endpoint,omitempty"`
+	IntrospectionEndpoint            string   `json:"introspection_endpoint,omitempty"`
+	ScopesSupported                  []string `json:"scopes_supported,omitempty"`
+	ResponseTypesSupported           []string `json:"response_types_supported,omitempty"`
+	SubjectTypesSupported            []string `json:"subject_types_supported,omitempty"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported,omitempty"`
+	ClaimsSupported                  []string `json:"claims_supported,omitempty"`
+}
+
+// Discover fetches the provider metadata of the issuer. The issuer in the
+// metadata must exactly match the issuer it was fetched for. The client
+// may be nil, in which case a client with a 10 second timeout is used.
+func Discover(ctx context.Context, issuer string, client *http.Client) (*ProviderMetadata, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	url := strings.TrimSuffix(issuer, "/") + DiscoveryPath
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, errors.Join(ErrDiscovery, err)
+	}
+	req.Header.Set("Accept", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Join(ErrDiscovery, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Join(ErrDiscovery, fmt.Errorf("unexpected status code %d", res.StatusCode))
+	}
+	b, err := io.ReadAll(io.LimitReader(res.Body, maxDiscoveryBytes))
+	if err != nil {
+		return nil, errors.Join(ErrDiscovery, err)
+	}
+	var md ProviderMetadata
+	err = json.Unmarshal(b, &md)
+	if err != nil {
+		return nil, errors.Join(ErrDiscovery, err)
+	}
+
+	// OpenID Connect Discovery 1.0, section 4.3: the issuer must be
+	// identical to the one used to fetch the metadata, which stops a
+	// provider from impersonating another.
+	if md.Issuer != issuer {
+		return nil, fmt.Errorf("%w: expected %q, got %q", ErrIssuerMismatch, issuer, md.Issuer)
+	}
+	if md.JWKSURI == "" {
+		return nil, ErrNoJWKSURI
+	}
+	return &md, nil
+}
+
+// ProviderOptions holds the optional configuration for a Provider. Any
+// fields left as their zero value will use the defaults.
+type ProviderOptions struct {
+
+	// Client is the http client used to fetch the provider metadata and
+	// the key set. It defaults to a client with a 10 second timeout.
+	Client *http.Client
+
+	// KeySetOptions configures the refreshing and caching of the keys
+	// of the provider. The Client field is ignored in favor of Client.
+	KeySetOptions *jwt.RemoteKeySetOptions
+}
+
+// Provider is an OpenID Connect provider, holding its metadata and the
+// key set its ID tokens are signed with.
+type Provider struct {
+	Metadata *ProviderMetadata
+	KeySet   *jwt.RemoteKeySet
+}
+
+// NewProvider discovers the provider metadata of the issuer, and sets up
+// a key set for the keys it publishes. The keys are not fetched until the
+// first token is verified. The options may be nil.
+func NewProvider(ctx context.Context, issuer string, opts *ProviderOptions) (*Provider, error) {
+	var o ProviderOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Client == nil {
+		o.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	md, err := Discover(ctx, issuer, o.Client)
+	if err != nil {
+		return nil, err
+	}
+	var kso jwt.RemoteKeySetOptions
+	if o.KeySetOptions != nil {
+		kso = *o.KeySetOptions
+	}
+	kso.Client = o.Client
+	return &Provider{
+		Metadata: md,
+		KeySet:   jwt.NewRemoteKeySet(md.JWKSURI, &kso),
+	}, nil
+}
+
+// Verifier returns a Verifier for ID tokens issued by the provider. If
+// the config does not list any algorithms, the algorithms the provider
+// advertises are used. The config may be nil.
+func (p *Provider) Verifier(config *Config) *Verifier {
+	var c Config
+	if config != nil {
+		c = *config
+	}
+	if len(c.Algorithms) == 0 {
+		c.Algorithms = p.Metadata.IDTokenSigningAlgValuesSupported
+	}
+	return NewVerifier(p.Metadata.Issuer, p.KeySet, &c)
+}
@@ -0,0 +1,147 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/scottcagno/webslinger/pkg/web/jwt"
+)
+
+// testProvider is a minimal OpenID Connect provider, which publishes its
+// metadata and keys, and issues ID tokens.
+type testProvider struct {
+	*httptest.Server
+	manager *jwt.TokenManager
+
+	// issuer overrides the issuer in the metadata when it is set.
+	issuer string
+}
+
+func newTestProvider(t *testing.T, method jwt.SigningMethod) *testProvider {
+	t.Helper()
+	ring := jwt.NewKeyRing(method, time.Hour)
+	if _, err := ring.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	p := &testProvider{manager: jwt.NewTokenManagerWithKeyRing(ring)}
+	mux := http.NewServeMux()
+	mux.Handle(jwt.JWKSPath, p.manager.JWKSHandler())
+	mux.HandleFunc(
+		DiscoveryPath, func(w http.ResponseWriter, r *http.Request) {
+			issuer := p.issuer
+			if issuer == "" {
+				issuer = p.URL
+			}
+			json.NewEncoder(w).Encode(
+				&ProviderMetadata{
+					Issuer:                           issuer,
+					AuthorizationEndpoint:            p.URL + "/authorize",
+					TokenEndpoint:                    p.URL + "/token",
+					JWKSURI:                          p.URL + jwt.JWKSPath,
+					IDTokenSigningAlgValuesSupported: []string{method.Name()},
+				},
+			)
+		},
+	)
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+// issue signs an ID token for the client, letting the caller adjust the
+// claims first.
+func (p *testProvider) issue(t *testing.T, clientID string, fn func(c *IDTokenClaims)) jwt.RawToken {
+	t.Helper()
+	now := jwt.NumericDateNow()
+	c := &IDTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:         p.URL,
+			Subject:        "248289761001",
+			Audience:       jwt.Audience{clientID},
+			ExpirationTime: now.Add(10 * time.Minute),
+			IssuedAtTime:   now,
+		},
+		Nonce:    "n-0S6_WzA2Mj",
+		AuthTime: now,
+	}
+	if fn != nil {
+		fn(c)
+	}
+	raw, err := p.manager.GenerateToken(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestDiscover(t *testing.T) {
+	p := newTestProvider(t, jwt.ES256)
+	md, err := Discover(context.Background(), p.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, p.URL, md.Issuer)
+	assert(t, p.URL+jwt.JWKSPath, md.JWKSURI)
+
+	// A trailing slash is not the same issuer
+	_, err = Discover(context.Background(), p.URL+"/", nil)
+	if !errors.Is(err, ErrIssuerMismatch) {
+		t.Errorf("expected %v, got %v", ErrIssuerMismatch, err)
+	}
+
+	// A provider claiming to be another issuer is rejected
+	p.issuer = "https://accounts.example.com"
+	_, err = Discover(context.Background(), p.URL, nil)
+	if !errors.Is(err, ErrIssuerMismatch) {
+		t.Errorf("expected %v, got %v", ErrIssuerMismatch, err)
+	}
+
+	_, err = Discover(context.Background(), p.URL+"/missing", nil)
+	if !errors.Is(err, ErrDiscovery) {
+		t.Errorf("expected %v, got %v", ErrDiscovery, err)
+	}
+}
+
+func TestProvider_Verifier(t *testing.T) {
+	p := newTestProvider(t, jwt.ES256)
+	provider, err := NewProvider(context.Background(), p.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The algorithms default to the ones the provider advertises
+	verifier := provider.Verifier(&Config{ClientID: "client"})
+	token, err := verifier.Verify(context.Background(), p.issue(t, "client", nil), "n-0S6_WzA2Mj")
+	if err != nil {
+		t.Fatalf("error verifying token: %v", err)
+	}
+	assert(t, "248289761001", token.Claims.Subject)
+	assert(t, "ES256", token.Header.Alg)
+
+	// A nil config uses the defaults, which has no client id, so tokens
+	// are only accepted when the client id check is skipped
+	_, err = provider.Verifier(nil).Verify(context.Background(), p.issue(t, "client", nil), "n-0S6_WzA2Mj")
+	if !errors.Is(err, ErrNoClientID) {
+		t.Errorf("expected %v, got %v", ErrNoClientID, err)
+	}
+	verifier = provider.Verifier(&Config{SkipClientIDCheck: true})
+	if _, err = verifier.Verify(context.Background(), p.issue(t, "client", nil), "n-0S6_WzA2Mj"); err != nil {
+		t.Errorf("error verifying token: %v", err)
+	}
+	verifier = NewVerifier(p.URL, provider.KeySet, nil)
+	_, err = verifier.Verify(context.Background(), p.issue(t, "client", nil), "n-0S6_WzA2Mj")
+	if err == nil {
+		t.Errorf("expected the default algorithms to reject ES256")
+	}
+}
+
+func assert(t *testing.T, wanted, got any) {
+	if wanted != got {
+		t.Errorf("wanted=%s, got=%s\n", wanted, got)
+	}
+}
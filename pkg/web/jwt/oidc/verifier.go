@@ -0,0 +1,265 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/scottcagno/webslinger/pkg/web/jwt"
+)
+
+// KeySet is a source of verification keys, looked up by key id. The
+// jwt.RemoteKeySet implements it.
+type KeySet interface {
+	Key(ctx context.Context, kid string) (*jwt.JWK, error)
+}
+
+// Config holds the configuration for a Verifier.
+type Config struct {
+
+	// ClientID is the client id of the relying party. ID tokens must
+	// contain it in their audience, and if they carry an azp claim it
+	// must match it. It is required unless SkipClientIDCheck is set.
+	ClientID string
+
+	// SkipClientIDCheck disables the audience and azp checks. It should
+	// only be used when the tokens are checked some other way.
+	SkipClientIDCheck bool
+
+	// Algorithms holds the signing algorithms that are accepted. If it
+	// is left empty, only RS256 is accepted, which is the default for
+	// ID tokens.
+	Algorithms []string
+
+	// MaxAge is the maximum time since the user authenticated. If it is
+	// non-zero, the auth_time claim is required. It should be set when
+	// the max_age parameter was sent in the authentication request.
+	MaxAge time.Duration
+
+	// Leeway is the time margin applied to the time based claims to
+	// account for clock skew.
+	Leeway time.Duration
+
+	// Clock is the source of the current time; if it is left as nil,
+	// jwt.SystemClock will be used.
+	Clock jwt.Clock
+}
+
+// Verifier verifies ID tokens issued by a single provider, as described
+// in OpenID Connect Core 1.0, section 3.1.3.7.
+type Verifier struct {
+	issuer    string
+	keys      KeySet
+	config    Config
+	validator *jwt.Validator
+}
+
+// NewVerifier returns a Verifier for ID tokens issued by issuer and signed
+// using the keys in the key set. The config may be nil, in which case the
+// defaults are used; since there is no ClientID, every token is rejected
+// unless SkipClientIDCheck is set.
+func NewVerifier(issuer string, keys KeySet, config *Config) *Verifier {
+	v := &Verifier{
+		issuer: issuer,
+		keys:   keys,
+	}
+	if config != nil {
+		v.config = *config
+	}
+	if len(v.config.Algorithms) == 0 {
+		v.config.Algorithms = []string{jwt.RS256.Name()}
+	}
+	if v.config.Clock == nil {
+		v.config.Clock = jwt.SystemClock
+	}
+	v.validator = &jwt.Validator{
+		Algorithms:     v.config.Algorithms,
+		ExpectedISS:    issuer,
+		Margin:         v.config.Leeway,
+		ValidateIAT:    true,
+		Clock:          v.config.Clock,
+		RequiredClaims: []string{"iss", "sub", "aud", "iat"},
+	}
+	if !v.config.SkipClientIDCheck {
+		v.validator.ExpectedAUD = v.config.ClientID
+	}
+	return v
+}
+
+// IDToken is a verified ID token.
+type IDToken struct {
+	Raw    jwt.RawToken
+	Header jwt.TokenHeader
+	Claims *IDTokenClaims
+}
+
+// VerifyAccessToken checks the access token that was issued along with the
+// ID token against the at_hash claim. It returns jwt.ErrTokenClaimNotFound
+// if the ID token does not carry an at_hash claim, which is only required
+// for some flows.
+func (t *IDToken) VerifyAccessToken(accessToken string) error {
+	return verifyHash(t.Header.Alg, t.Claims.AccessTokenHash, accessToken)
+}
+
+// VerifyCode checks the authorization code that was issued along with the
+// ID token against the c_hash claim. It returns jwt.ErrTokenClaimNotFound
+// if the ID token does not carry a c_hash claim.
+func (t *IDToken) VerifyCode(code string) error {
+	return verifyHash(t.Header.Alg, t.Claims.CodeHash, code)
+}
+
+// Verify verifies the signature and claims of the raw ID token. If a nonce
+// was sent in the authentication request, it must be provided, and the
+// nonce claim must match it. Any error returned for a token that fails
+// validation is a *jwt.ValidationError reporting every failed check, with
+// the OpenID Connect specific checks reported as jwt.CheckCustomClaims.
+func (v *Verifier) Verify(ctx context.Context, raw jwt.RawToken, nonce string) (*IDToken, error) {
+	unverified, err := jwt.ParseUnverifiedWithClaims[*IDTokenClaims](raw)
+	if err != nil {
+		return nil, err
+	}
+	key, err := v.key(ctx, &unverified.Header)
+	if err != nil {
+		return nil, errors.Join(jwt.ErrTokenUnverifiable, err)
+	}
+	token, err := jwt.ParseWithClaims[*IDTokenClaims](raw, v.validator, key)
+
+	// The header checks stop validation right away, in which case the
+	// claims should not be looked at either.
+	var verr *jwt.ValidationError
+	if errors.As(err, &verr) && verr.Has(jwt.CheckAlgorithm|jwt.CheckKeyType|jwt.CheckCritical) {
+		return nil, err
+	}
+	if failures := v.check(unverified.Claims, nonce); len(failures) > 0 {
+		if verr == nil {
+			if err != nil {
+				return nil, err
+			}
+			verr = new(jwt.ValidationError)
+		}
+		for _, f := range failures {
+			verr.Checks |= f.Check
+			verr.Failures = append(verr.Failures, f)
+		}
+		return nil, verr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &IDToken{
+		Raw:    token.RawToken,
+		Header: token.Header,
+		Claims: token.Claims,
+	}, nil
+}
+
+// key looks up the verification key for the token. The key is not looked
+// up for algorithms that are not accepted, so a token with a made up kid
+// cannot trigger a refresh of the key set; the validator rejects it.
+func (v *Verifier) key(ctx context.Context, hdr *jwt.TokenHeader) (crypto.PublicKey, error) {
+	if !contains(v.config.Algorithms, hdr.Alg) {
+		return nil, nil
+	}
+	jwk, err := v.keys.Key(ctx, hdr.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if jwk.Alg != "" && jwk.Alg != hdr.Alg {
+		return nil, fmt.Errorf("key %q is for %s, not %s", hdr.Kid, jwk.Alg, hdr.Alg)
+	}
+	return jwk.PublicKey()
+}
+
+// check runs the checks that are specific to ID tokens.
+func (v *Verifier) check(claims *IDTokenClaims, nonce string) []*jwt.ValidationFailure {
+	var failures []*jwt.ValidationFailure
+
+	// nonce
+	if nonce != "" && subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		failures = append(
+			failures, &jwt.ValidationFailure{
+				Check:  jwt.CheckCustomClaims,
+				Detail: "nonce",
+				Err:    ErrNonceMismatch,
+			},
+		)
+	}
+
+	// A verifier without a client id would accept tokens issued to any
+	// client
+	if !v.config.SkipClientIDCheck && v.config.ClientID == "" {
+		failures = append(
+			failures, &jwt.ValidationFailure{
+				Check:  jwt.CheckAudience,
+				Detail: "aud",
+				Err:    ErrNoClientID,
+			},
+		)
+	}
+
+	// azp, which is required when there are several audiences
+	if !v.config.SkipClientIDCheck {
+		azp := claims.AuthorizedParty
+		if azp != "" && azp != v.config.ClientID {
+			failures = append(
+				failures, &jwt.ValidationFailure{
+					Check:    jwt.CheckCustomClaims,
+					Expected: v.config.ClientID,
+					Actual:   azp,
+					Detail:   "azp",
+					Err:      ErrInvalidAZP,
+				},
+			)
+		}
+		if azp == "" && len(claims.Audience) > 1 {
+			failures = append(
+				failures, &jwt.ValidationFailure{
+					Check:  jwt.CheckCustomClaims,
+					Actual: claims.Audience,
+					Detail: "azp is required when there are several audiences",
+					Err:    errors.Join(ErrInvalidAZP, jwt.ErrTokenClaimNotFound),
+				},
+			)
+		}
+	}
+
+	// auth_time
+	if v.config.MaxAge > 0 {
+		if claims.AuthTime == 0 {
+			failures = append(
+				failures, &jwt.ValidationFailure{
+					Check:  jwt.CheckCustomClaims,
+					Detail: "auth_time is required when max_age is set",
+					Err:    errors.Join(ErrAuthTimeExceeded, jwt.ErrTokenClaimNotFound),
+				},
+			)
+		} else {
+			now := v.config.Clock.Now()
+			limit := claims.AuthTime.Time().Add(v.config.MaxAge + v.config.Leeway)
+			if now.After(limit) {
+				failures = append(
+					failures, &jwt.ValidationFailure{
+						Check:    jwt.CheckCustomClaims,
+						Expected: limit,
+						Actual:   now,
+						Detail:   fmt.Sprintf("authenticated %s ago", now.Sub(claims.AuthTime.Time()).Truncate(time.Second)),
+						Err:      ErrAuthTimeExceeded,
+					},
+				)
+			}
+		}
+	}
+	return failures
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
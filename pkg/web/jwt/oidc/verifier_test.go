@@ -0,0 +1,201 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/scottcagno/webslinger/pkg/web/jwt"
+)
+
+func TestVerifier_Verify(t *testing.T) {
+	p := newTestProvider(t, jwt.RS256)
+	keys := jwt.NewRemoteKeySet(p.URL+jwt.JWKSPath, nil)
+	clock := jwt.NewFakeClock(time.Now())
+	verifier := NewVerifier(p.URL, keys, &Config{ClientID: "client", Clock: clock})
+	ctx := context.Background()
+
+	// A valid token
+	token, err := verifier.Verify(ctx, p.issue(t, "client", nil), "n-0S6_WzA2Mj")
+	if err != nil {
+		t.Fatalf("error verifying token: %v", err)
+	}
+	assert(t, "client", token.Claims.Audience[0])
+
+	// The nonce is only checked when one was sent
+	if _, err = verifier.Verify(ctx, p.issue(t, "client", nil), ""); err != nil {
+		t.Errorf("error verifying token: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		clientID string
+		nonce    string
+		claims   func(c *IDTokenClaims)
+		check    jwt.ValidationCheck
+		err      error
+	}{
+		{
+			name:  "wrong nonce",
+			nonce: "other",
+			check: jwt.CheckCustomClaims,
+			err:   ErrNonceMismatch,
+		},
+		{
+			name:     "wrong audience",
+			clientID: "other",
+			check:    jwt.CheckAudience,
+			err:      jwt.ErrTokenInvalidAudience,
+		},
+		{
+			name:   "wrong issuer",
+			claims: func(c *IDTokenClaims) { c.Issuer = "https://evil.example.com" },
+			check:  jwt.CheckIssuer,
+			err:    jwt.ErrTokenInvalidIssuer,
+		},
+		{
+			name:   "missing sub",
+			claims: func(c *IDTokenClaims) { c.Subject = "" },
+			check:  jwt.CheckRequiredClaims,
+			err:    jwt.ErrTokenClaimNotFound,
+		},
+		{
+			name:   "missing iat",
+			claims: func(c *IDTokenClaims) { c.IssuedAtTime = 0 },
+			check:  jwt.CheckRequiredClaims,
+			err:    jwt.ErrTokenClaimNotFound,
+		},
+		{
+			name:   "wrong azp",
+			claims: func(c *IDTokenClaims) { c.AuthorizedParty = "other" },
+			check:  jwt.CheckCustomClaims,
+			err:    ErrInvalidAZP,
+		},
+		{
+			name:   "missing azp with several audiences",
+			claims: func(c *IDTokenClaims) { c.Audience = append(c.Audience, "other") },
+			check:  jwt.CheckCustomClaims,
+			err:    ErrInvalidAZP,
+		},
+		{
+			name:   "expired",
+			claims: func(c *IDTokenClaims) { c.ExpirationTime = jwt.NumericDateNow().Add(-time.Hour) },
+			check:  jwt.CheckExpiresAt,
+			err:    jwt.ErrTokenExpired,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				raw := p.issue(t, "client", tt.claims)
+				if tt.clientID != "" {
+					raw = p.issue(t, tt.clientID, tt.claims)
+				}
+				nonce := tt.nonce
+				if nonce == "" {
+					nonce = "n-0S6_WzA2Mj"
+				}
+				_, err := verifier.Verify(ctx, raw, nonce)
+				var verr *jwt.ValidationError
+				if !errors.As(err, &verr) {
+					t.Fatalf("expected a validation error, got %v", err)
+				}
+				if !verr.Has(tt.check) {
+					t.Errorf("expected check %s to fail, got %s", tt.check, verr.Checks)
+				}
+				if !errors.Is(err, tt.err) {
+					t.Errorf("expected %v, got %v", tt.err, err)
+				}
+			},
+		)
+	}
+
+	// Every failed check is reported, including the ID token checks
+	raw := p.issue(
+		t, "client", func(c *IDTokenClaims) {
+			c.AuthorizedParty = "other"
+			c.ExpirationTime = jwt.NumericDateNow().Add(-time.Hour)
+		},
+	)
+	_, err = verifier.Verify(ctx, raw, "wrong")
+	var verr *jwt.ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	assert(t, 3, len(verr.Failures))
+}
+
+func TestVerifier_MaxAge(t *testing.T) {
+	p := newTestProvider(t, jwt.EdDSA)
+	keys := jwt.NewRemoteKeySet(p.URL+jwt.JWKSPath, nil)
+	clock := jwt.NewFakeClock(time.Now())
+	verifier := NewVerifier(
+		p.URL, keys, &Config{
+			ClientID:   "client",
+			Algorithms: []string{"EdDSA"},
+			MaxAge:     5 * time.Minute,
+			Clock:      clock,
+		},
+	)
+	ctx := context.Background()
+	raw := p.issue(t, "client", nil)
+	if _, err := verifier.Verify(ctx, raw, ""); err != nil {
+		t.Fatalf("error verifying token: %v", err)
+	}
+
+	// The user authenticated too long ago
+	clock.Advance(6 * time.Minute)
+	_, err := verifier.Verify(ctx, raw, "")
+	if !errors.Is(err, ErrAuthTimeExceeded) {
+		t.Errorf("expected %v, got %v", ErrAuthTimeExceeded, err)
+	}
+
+	// auth_time is required when max_age is set
+	clock.Set(time.Now())
+	raw = p.issue(t, "client", func(c *IDTokenClaims) { c.AuthTime = 0 })
+	_, err = verifier.Verify(ctx, raw, "")
+	if !errors.Is(err, ErrAuthTimeExceeded) || !errors.Is(err, jwt.ErrTokenClaimNotFound) {
+		t.Errorf("expected a missing auth_time, got %v", err)
+	}
+}
+
+func TestVerifier_Algorithms(t *testing.T) {
+	p := newTestProvider(t, jwt.ES256)
+	keys := jwt.NewRemoteKeySet(p.URL+jwt.JWKSPath, nil)
+
+	// Only RS256 is accepted by default
+	verifier := NewVerifier(p.URL, keys, &Config{ClientID: "client"})
+	_, err := verifier.Verify(context.Background(), p.issue(t, "client", nil), "")
+	var verr *jwt.ValidationError
+	if !errors.As(err, &verr) || !verr.Has(jwt.CheckAlgorithm) {
+		t.Fatalf("expected the algorithm check to fail, got %v", err)
+	}
+	// The claims are not looked at when the header is rejected
+	assert(t, 1, len(verr.Failures))
+}
+
+func TestIDToken_VerifyAccessToken(t *testing.T) {
+	p := newTestProvider(t, jwt.ES384)
+	keys := jwt.NewRemoteKeySet(p.URL+jwt.JWKSPath, nil)
+	verifier := NewVerifier(p.URL, keys, &Config{ClientID: "client", Algorithms: []string{"ES384"}})
+
+	atHash, err := TokenHash("ES384", "access-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := p.issue(t, "client", func(c *IDTokenClaims) { c.AccessTokenHash = atHash })
+	token, err := verifier.Verify(context.Background(), raw, "")
+	if err != nil {
+		t.Fatalf("error verifying token: %v", err)
+	}
+	if err = token.VerifyAccessToken("access-token"); err != nil {
+		t.Errorf("expected the access token to match, got %v", err)
+	}
+	if err = token.VerifyAccessToken("other"); !errors.Is(err, ErrHashMismatch) {
+		t.Errorf("expected %v, got %v", ErrHashMismatch, err)
+	}
+	if err = token.VerifyCode("code"); !errors.Is(err, jwt.ErrTokenClaimNotFound) {
+		t.Errorf("expected %v, got %v", jwt.ErrTokenClaimNotFound, err)
+	}
+}
@@ -0,0 +1,204 @@
+package jwt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Default parser limits
+const (
+	DefaultMaxTokenSize  = 64 << 10
+	DefaultMaxHeaderSize = 8 << 10
+)
+
+// Parser parses compact tokens. It never panics, no matter the input, and
+// every error it returns is a *ParseError (which also matches
+// ErrTokenMalformed using errors.Is). The zero value is ready to use.
+type Parser struct {
+
+	// MaxTokenSize is the maximum size of the whole raw token in bytes.
+	// If it is left as zero, DefaultMaxTokenSize will be used.
+	MaxTokenSize int
+
+	// MaxHeaderSize is the maximum size of the encoded header segment
+	// in bytes. If it is left as zero, DefaultMaxHeaderSize will be used.
+	MaxHeaderSize int
+}
+
+// DefaultParser is the Parser used by ParseRawToken and the Validator.
+var DefaultParser = &Parser{}
+
+// ParseError is returned by the Parser when a token cannot be parsed. It
+// reports the section of the token the parser failed on.
+type ParseError struct {
+	Section Section
+	Err     error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", ErrTokenMalformed, e.Section, e.Err)
+}
+
+func (e *ParseError) Unwrap() []error {
+	return []error{ErrTokenMalformed, e.Err}
+}
+
+// segments holds the positions of the two dots separating the segments of
+// a compact token, so the segments can be sliced out without copying.
+type segments struct {
+	raw        RawToken
+	dot1, dot2 int
+}
+
+func (s segments) header() []byte {
+	return s.raw[:s.dot1]
+}
+
+func (s segments) payload() []byte {
+	return s.raw[s.dot1+1 : s.dot2]
+}
+
+func (s segments) signature() []byte {
+	return s.raw[s.dot2+1:]
+}
+
+func (p *Parser) maxTokenSize() int {
+	if p.MaxTokenSize == 0 {
+		return DefaultMaxTokenSize
+	}
+	return p.MaxTokenSize
+}
+
+func (p *Parser) maxHeaderSize() int {
+	if p.MaxHeaderSize == 0 {
+		return DefaultMaxHeaderSize
+	}
+	return p.MaxHeaderSize
+}
+
+// split checks the size and the number of segments of the raw token, and
+// locates the segments.
+func (p *Parser) split(raw RawToken) (segments, error) {
+	if len(raw) > p.maxTokenSize() {
+		return segments{}, &ParseError{SigningInputSection, ErrTokenTooLarge}
+	}
+	s := segments{raw: raw, dot1: bytes.IndexByte(raw, dot)}
+	if s.dot1 < 0 {
+		return segments{}, &ParseError{HeaderSection, ErrTokenSegmentCount}
+	}
+	if s.dot1 > p.maxHeaderSize() {
+		return segments{}, &ParseError{HeaderSection, ErrTokenTooLarge}
+	}
+	s.dot2 = bytes.IndexByte(raw[s.dot1+1:], dot)
+	if s.dot2 < 0 {
+		return segments{}, &ParseError{PayloadSection, ErrTokenSegmentCount}
+	}
+	s.dot2 += s.dot1 + 1
+	if n := bytes.Count(raw[s.dot2+1:], []byte{dot}); n != 0 {
+		if n == 2 {
+			return segments{}, &ParseError{SignatureSection, ErrTokenEncrypted}
+		}
+		return segments{}, &ParseError{SignatureSection, ErrTokenSegmentCount}
+	}
+	return s, nil
+}
+
+// Parse parses the raw token, decoding the payload into MapClaims. The
+// token is not validated.
+func (p *Parser) Parse(raw RawToken) (*Token, error) {
+	var payload MapClaims
+	token, err := p.parse(raw, &payload)
+	if err != nil {
+		return nil, err
+	}
+	token.Payload = payload
+	return token, nil
+}
+
+// ParseHeader only decodes the header of the raw token, which is all that
+// is needed to route a token (e.g. by kid) before doing anything else with
+// it. The payload is not touched.
+func (p *Parser) ParseHeader(raw RawToken) (*TokenHeader, error) {
+	s, err := p.split(raw)
+	if err != nil {
+		return nil, err
+	}
+	var hdr TokenHeader
+	err = decodeSegment(HeaderSection, s.header(), &hdr)
+	if err != nil {
+		return nil, err
+	}
+	return &hdr, nil
+}
+
+// parse parses the raw token, decoding the payload into the provided
+// claims. The returned token holds everything except for the payload,
+// which is left to the caller. The signature is not decoded, it is only
+// sliced out of the raw token.
+func (p *Parser) parse(raw RawToken, claims any) (*Token, error) {
+	s, err := p.split(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize a token instance
+	token := &Token{RawToken: raw}
+
+	// Parse the raw token header
+	err = decodeSegment(HeaderSection, s.header(), &token.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the signing method (even though it's not verifiable, yet).
+	// Unsecured tokens ("alg":"none") are never accepted, and there is
+	// no signing method registered for them.
+	token.Method = GetSigningMethod(token.Header.Alg)
+	if token.Method == nil {
+		return nil, &ParseError{HeaderSection, ErrTokenUnverifiable}
+	}
+
+	// Parse the raw token claims
+	err = decodeSegment(PayloadSection, s.payload(), claims)
+	if err != nil {
+		return nil, err
+	}
+
+	// Add the signature
+	token.Signature = s.signature()
+	return token, nil
+}
+
+// segmentPool holds buffers for decoding segments into. The decoded bytes
+// never outlive the call to json.Unmarshal, which copies everything it
+// keeps, so the buffers can be reused.
+var segmentPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, 1024)
+		return &b
+	},
+}
+
+// decodeSegment decodes the base64url encoded segment and unmarshals the
+// JSON into v.
+func decodeSegment(section Section, src []byte, v any) error {
+	n := base64.RawURLEncoding.DecodedLen(len(src))
+	bp := segmentPool.Get().(*[]byte)
+	defer segmentPool.Put(bp)
+	if cap(*bp) < n {
+		*bp = make([]byte, n)
+	}
+	buf := (*bp)[:n]
+	n, err := base64.RawURLEncoding.Decode(buf, src)
+	if err != nil {
+		return &ParseError{section, err}
+	}
+	err = json.Unmarshal(buf[:n], v)
+	if err != nil {
+		return &ParseError{section, err}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package jwt
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParser_Parse(t *testing.T) {
+	tok, err := DefaultParser.Parse(rawToken)
+	if err != nil {
+		t.Fatalf("error parsing raw token: %v", err)
+	}
+	assert(t, "HS256", tok.Header.Alg)
+	assert(t, "John Doe", tok.Payload["name"])
+	assert(t, string(validToken.Signature), string(tok.Signature))
+
+	hdr, err := DefaultParser.ParseHeader(rawToken)
+	if err != nil {
+		t.Fatalf("error parsing header: %v", err)
+	}
+	assert(t, "JWT", hdr.Typ)
+}
+
+func TestParser_Errors(t *testing.T) {
+	header := string(Base64Encode([]byte(`{"alg":"HS256"}`)))
+	p := &Parser{MaxTokenSize: 256, MaxHeaderSize: 64}
+	tests := []struct {
+		name    string
+		raw     string
+		section Section
+		want    error
+	}{
+		{"no dots", "abc", HeaderSection, ErrTokenSegmentCount},
+		{"one dot", header + ".e30", PayloadSection, ErrTokenSegmentCount},
+		{"four segments", header + ".e30.sig.x", SignatureSection, ErrTokenSegmentCount},
+		{"encrypted", "a.b.c.d.e", SignatureSection, ErrTokenEncrypted},
+		{"token too large", header + ".e30." + strings.Repeat("a", 256), SigningInputSection, ErrTokenTooLarge},
+		{"header too large", strings.Repeat("a", 65) + ".e30.sig", HeaderSection, ErrTokenTooLarge},
+		{"unknown alg", string(Base64Encode([]byte(`{"alg":"none"}`))) + ".e30.", HeaderSection, ErrTokenUnverifiable},
+		{"bad header", "!!.e30.sig", HeaderSection, nil},
+		{"bad payload", header + ".!!.sig", PayloadSection, nil},
+		{"payload not json", header + "." + string(Base64Encode([]byte("nope"))) + ".sig", PayloadSection, nil},
+	}
+	for _, tt := range tests {
+		_, err := p.Parse(RawToken(tt.raw))
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("[%s] expected a *ParseError, got %v", tt.name, err)
+			continue
+		}
+		assert(t, tt.section, perr.Section)
+		if !errors.Is(err, ErrTokenMalformed) {
+			t.Errorf("[%s] expected %v, got %v", tt.name, ErrTokenMalformed, err)
+		}
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("[%s] expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestRawToken_Sections(t *testing.T) {
+	// Every section must have its own value
+	sections := map[Section]bool{}
+	for _, s := range []Section{HeaderSection, PayloadSection, SignatureSection, SigningInputSection} {
+		if sections[s] {
+			t.Errorf("section %s overlaps another section", s)
+		}
+		sections[s] = true
+	}
+
+	raw := rawToken
+	i, j := raw.GetSection(PayloadSection)
+	assert(t, "eyJzdWIiOiIxMjM0NTY3ODkwIiwibmFtZSI6IkpvaG4gRG9lIiwiaWF0IjoxNTE2MjM5MDIyfQ", string(raw[i:j]))
+
+	// The signing section shares memory with the token, and cannot be
+	// appended to without copying
+	ss := raw.SigningSection()
+	if &ss[0] != &raw[0] {
+		t.Errorf("signing section was copied")
+	}
+	ss = append(ss, 'x')
+	if !bytes.Equal(raw, rawToken) {
+		t.Errorf("appending to the signing section modified the token")
+	}
+
+	// Malformed tokens never panic
+	bad := RawToken("not a token")
+	i, j = bad.GetSection(SignatureSection)
+	assert(t, -1, i)
+	assert(t, -1, j)
+	if bad.Header() != nil || bad.Claims() != nil || bad.SigningSection() != nil || bad.Signature() != nil {
+		t.Errorf("expected nil sections for a malformed token")
+	}
+	_, err := bad.ParseHeader()
+	if !errors.Is(err, ErrTokenSegmentCount) {
+		t.Errorf("expected %v, got %v", ErrTokenSegmentCount, err)
+	}
+}
+
+func BenchmarkParser_Parse(b *testing.B) {
+	benchmarkParseRawToken(b, rawToken)
+}
+
+func BenchmarkParser_ParseHeader(b *testing.B) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(
+		func(pb *testing.PB) {
+			for pb.Next() {
+				_, err := DefaultParser.ParseHeader(rawToken)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		},
+	)
+}
+
+func BenchmarkValidateTokenHS256(b *testing.B) {
+	claims := MapClaims{
+		"sub":   "jon doe",
+		"scope": "orders:read orders:write",
+		"iat":   NumericDateNow(),
+		"exp":   NumericDateNow().Add(time.Hour),
+	}
+	raw, err := NewToken(HS256, claims, hmacTestKey)
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkValidateToken(b, Validator{Method: HS256}, raw, hmacTestKey)
+}
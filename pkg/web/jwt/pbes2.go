@@ -0,0 +1,228 @@
+package jwt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/asn1"
+	"encoding/binary"
+	"hash"
+)
+
+// This file implements just enough of PKCS#5 v2.0 (RFC 8018) to read and
+// write password protected PKCS#8 keys ("ENCRYPTED PRIVATE KEY"), which the
+// standard library does not support. Only PBES2 with PBKDF2 and AES-CBC is
+// supported, which is what OpenSSL has used by default for years.
+
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+	oidAES128CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// pbkdf2Iterations is the iteration count used when encrypting keys.
+const pbkdf2Iterations = 600000
+
+// maxPBKDF2Iterations is the highest iteration count accepted when
+// decrypting keys, so a crafted key cannot keep the CPU busy for hours.
+const maxPBKDF2Iterations = 10000000
+
+type algorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     algorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc algorithmIdentifier
+	EncryptionScheme  algorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                 `asn1:"optional"`
+	PRF            algorithmIdentifier `asn1:"optional"`
+}
+
+// pbkdf2 derives a key from the password as described in RFC 8018,
+// section 5.2.
+func pbkdf2(h func() hash.Hash, password, salt []byte, iter, keyLen int) []byte {
+	prf := hmac.New(h, password)
+	hashLen := prf.Size()
+	numBlocks := (keyLen + hashLen - 1) / hashLen
+
+	var buf [4]byte
+	dk := make([]byte, 0, numBlocks*hashLen)
+	u := make([]byte, hashLen)
+	for block := 1; block <= numBlocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf[:], uint32(block))
+		prf.Write(buf[:4])
+		dk = prf.Sum(dk)
+		t := dk[len(dk)-hashLen:]
+		copy(u, t)
+		for n := 2; n <= iter; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = u[:0]
+			u = prf.Sum(u)
+			for i := range u {
+				t[i] ^= u[i]
+			}
+		}
+	}
+	return dk[:keyLen]
+}
+
+func prfHash(oid asn1.ObjectIdentifier) func() hash.Hash {
+	switch {
+	case oid == nil, oid.Equal(oidHMACWithSHA1):
+		return sha1.New
+	case oid.Equal(oidHMACWithSHA256):
+		return sha256.New
+	case oid.Equal(oidHMACWithSHA512):
+		return sha512.New
+	}
+	return nil
+}
+
+func aesKeySize(oid asn1.ObjectIdentifier) int {
+	switch {
+	case oid.Equal(oidAES128CBC):
+		return 16
+	case oid.Equal(oidAES192CBC):
+		return 24
+	case oid.Equal(oidAES256CBC):
+		return 32
+	}
+	return 0
+}
+
+// decryptPKCS8 decrypts DER encoded EncryptedPrivateKeyInfo, returning the
+// DER encoded PKCS#8 private key.
+func decryptPKCS8(der, password []byte) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, ErrParsingPEMPrivateKey
+	}
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, ErrPEMUnsupportedEncryption
+	}
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, ErrParsingPEMPrivateKey
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, ErrPEMUnsupportedEncryption
+	}
+	var kdf pbkdf2Params
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+		return nil, ErrParsingPEMPrivateKey
+	}
+	h := prfHash(kdf.PRF.Algorithm)
+	keySize := aesKeySize(params.EncryptionScheme.Algorithm)
+	if h == nil || keySize == 0 || kdf.IterationCount <= 0 || kdf.IterationCount > maxPBKDF2Iterations {
+		return nil, ErrPEMUnsupportedEncryption
+	}
+	if kdf.KeyLength != 0 && kdf.KeyLength != keySize {
+		return nil, ErrPEMUnsupportedEncryption
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, ErrParsingPEMPrivateKey
+	}
+	if len(iv) != aes.BlockSize || len(info.EncryptedData) == 0 || len(info.EncryptedData)%aes.BlockSize != 0 {
+		return nil, ErrParsingPEMPrivateKey
+	}
+
+	key := pbkdf2(h, password, kdf.Salt, kdf.IterationCount, keySize)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(info.EncryptedData))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, info.EncryptedData)
+
+	// Remove the PKCS#7 padding. A wrong password almost always shows up
+	// as bad padding.
+	pad := int(out[len(out)-1])
+	if pad == 0 || pad > aes.BlockSize {
+		return nil, ErrPEMDecryption
+	}
+	for _, b := range out[len(out)-pad:] {
+		if int(b) != pad {
+			return nil, ErrPEMDecryption
+		}
+	}
+	return out[:len(out)-pad], nil
+}
+
+// encryptPKCS8 encrypts the DER encoded PKCS#8 private key using PBES2
+// with PBKDF2-HMAC-SHA256 and AES-256-CBC.
+func encryptPKCS8(der, password []byte) ([]byte, error) {
+	salt, err := randomBytes(16)
+	if err != nil {
+		return nil, err
+	}
+	iv, err := randomBytes(aes.BlockSize)
+	if err != nil {
+		return nil, err
+	}
+	key := pbkdf2(sha256.New, password, salt, pbkdf2Iterations, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	pad := aes.BlockSize - len(der)%aes.BlockSize
+	out := make([]byte, len(der)+pad)
+	copy(out, der)
+	for i := len(der); i < len(out); i++ {
+		out[i] = byte(pad)
+	}
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, out)
+
+	null := asn1.RawValue{Tag: asn1.TagNull}
+	kdf, err := asn1.Marshal(
+		pbkdf2Params{
+			Salt:           salt,
+			IterationCount: pbkdf2Iterations,
+			PRF:            algorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: null},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	ivParam, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+	params, err := asn1.Marshal(
+		pbes2Params{
+			KeyDerivationFunc: algorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdf}},
+			EncryptionScheme:  algorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParam}},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(
+		encryptedPrivateKeyInfo{
+			Algorithm:     algorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+			EncryptedData: out,
+		},
+	)
+}
@@ -1,52 +1,235 @@
 package jwt
 
 import (
+	"bytes"
 	"crypto"
 	"crypto/dsa"
 	"crypto/ecdh"
 	"crypto/ecdsa"
 	"crypto/ed25519"
+	"crypto/hmac"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"os"
 )
 
 var (
-	ErrParsingPEMPublicKey  = errors.New("failed to decode PEM block containing public key")
-	ErrParsingPEMPrivateKey = errors.New("failed to decode PEM block containing private key")
+	ErrParsingPEMPublicKey      = errors.New("failed to decode PEM block containing public key")
+	ErrParsingPEMPrivateKey     = errors.New("failed to decode PEM block containing private key")
+	ErrPEMEncrypted             = errors.New("PEM block is encrypted and requires a password")
+	ErrPEMDecryption            = errors.New("failed to decrypt PEM block, the password may be wrong")
+	ErrPEMUnsupportedEncryption = errors.New("PEM block is encrypted using an unsupported algorithm")
+	ErrKeyPairMismatch          = errors.New("public key does not belong to the private key")
 )
 
-// ParsePrivateKeyFromPEM parses a PEM encoded PKCS1 or PKCS8 public key
+// ParsePrivateKeyFromPEM parses a PEM encoded private key. PKCS#8
+// ("PRIVATE KEY"), PKCS#1 ("RSA PRIVATE KEY") and SEC1 ("EC PRIVATE KEY")
+// blocks are supported. Any other blocks in front of the key, such as the
+// "EC PARAMETERS" block written by OpenSSL, are skipped. Encrypted keys
+// return ErrPEMEncrypted, use ParsePrivateKeyFromPEMWithPassword instead.
 func ParsePrivateKeyFromPEM(key []byte) (crypto.PrivateKey, error) {
-	// Parse PEM block
-	block, _ := pem.Decode(key)
-	if block == nil || block.Type != "PRIVATE KEY" {
-		return nil, ErrParsingPEMPrivateKey
+	return ParsePrivateKeyFromPEMWithPassword(key, nil)
+}
+
+// ParsePrivateKeyFromPEMWithPassword works just like ParsePrivateKeyFromPEM,
+// but it also supports password protected PKCS#8 ("ENCRYPTED PRIVATE KEY")
+// blocks. Legacy encrypted PEM blocks (with a Proc-Type header) are not
+// supported, as their encryption is insecure.
+func ParsePrivateKeyFromPEMWithPassword(key, password []byte) (crypto.PrivateKey, error) {
+	for {
+		var block *pem.Block
+		block, key = pem.Decode(key)
+		if block == nil {
+			return nil, ErrParsingPEMPrivateKey
+		}
+		switch block.Type {
+		case "PRIVATE KEY", "RSA PRIVATE KEY", "EC PRIVATE KEY":
+			if _, found := block.Headers["Proc-Type"]; found {
+				return nil, ErrPEMUnsupportedEncryption
+			}
+			return ParsePrivateKeyFromDER(block.Bytes)
+		case "ENCRYPTED PRIVATE KEY":
+			if password == nil {
+				return nil, ErrPEMEncrypted
+			}
+			der, err := decryptPKCS8(block.Bytes, password)
+			if err != nil {
+				return nil, err
+			}
+			pri, err := x509.ParsePKCS8PrivateKey(der)
+			if err != nil {
+				// The padding happened to look fine, but the password
+				// was still wrong.
+				return nil, ErrPEMDecryption
+			}
+			return pri, nil
+		}
+	}
+}
+
+// ParsePrivateKeyFromDER parses a DER encoded PKCS#8, PKCS#1 or SEC1
+// private key.
+func ParsePrivateKeyFromDER(der []byte) (crypto.PrivateKey, error) {
+	if pri, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return pri, nil
+	}
+	if pri, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return pri, nil
+	}
+	if pri, err := x509.ParseECPrivateKey(der); err == nil {
+		return pri, nil
+	}
+	return nil, ErrParsingPEMPrivateKey
+}
+
+// ParsePublicKeyFromPEM parses a PEM encoded public key. PKIX
+// ("PUBLIC KEY") and PKCS#1 ("RSA PUBLIC KEY") blocks are supported, and
+// so are X.509 certificates ("CERTIFICATE"), in which case the public key
+// of the certificate is returned. Any other blocks in front of the key are
+// skipped.
+func ParsePublicKeyFromPEM(key []byte) (crypto.PublicKey, error) {
+	for {
+		var block *pem.Block
+		block, key = pem.Decode(key)
+		if block == nil {
+			return nil, ErrParsingPEMPublicKey
+		}
+		switch block.Type {
+		case "PUBLIC KEY", "RSA PUBLIC KEY", "CERTIFICATE":
+			return ParsePublicKeyFromDER(block.Bytes)
+		}
+	}
+}
+
+// ParsePublicKeyFromDER parses a DER encoded PKIX or PKCS#1 public key, or
+// an X.509 certificate, in which case the public key of the certificate is
+// returned.
+func ParsePublicKeyFromDER(der []byte) (crypto.PublicKey, error) {
+	if pub, err := x509.ParsePKIXPublicKey(der); err == nil {
+		return pub, nil
+	}
+	if pub, err := x509.ParsePKCS1PublicKey(der); err == nil {
+		return pub, nil
+	}
+	if cert, err := x509.ParseCertificate(der); err == nil {
+		return cert.PublicKey, nil
+	}
+	return nil, ErrParsingPEMPublicKey
+}
+
+// MarshalPrivateKeyToPEM encodes the private key as a PKCS#8
+// ("PRIVATE KEY") PEM block.
+func MarshalPrivateKeyToPEM(key crypto.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
 	}
-	// Parse the key
-	pri, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// MarshalPrivateKeyToEncryptedPEM encodes the private key as a password
+// protected PKCS#8 ("ENCRYPTED PRIVATE KEY") PEM block. The key is
+// encrypted using AES-256-CBC, with a key derived from the password using
+// PBKDF2-HMAC-SHA256.
+func MarshalPrivateKeyToEncryptedPEM(key crypto.PrivateKey, password []byte) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
 	if err != nil {
-		return nil, ErrParsingPEMPublicKey
+		return nil, err
 	}
-	return pri, nil
+	der, err = encryptPKCS8(der, password)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: der}), nil
+}
 
+// MarshalPublicKeyToPEM encodes the public key as a PKIX ("PUBLIC KEY")
+// PEM block.
+func MarshalPublicKeyToPEM(key crypto.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
 }
 
-// ParsePublicKeyFromPEM parses a PEM encoded PKCS1 or PKCS8 public key
-func ParsePublicKeyFromPEM(key []byte) (crypto.PublicKey, error) {
-	// Parse PEM block
-	block, _ := pem.Decode(key)
-	if block == nil || block.Type != "PUBLIC KEY" {
-		return nil, ErrParsingPEMPublicKey
+// LoadKeyPair loads a key pair for the signing method from PEM files. The
+// public key file is optional, if it is left empty the public key is taken
+// from the private key. The password is only needed for encrypted private
+// keys. For HMAC signing methods the private key file holds the raw secret,
+// and the public key file is ignored. The key pair is checked using
+// ValidateKeyPair before it is returned.
+func LoadKeyPair(method SigningMethod, privateFile, publicFile string, password []byte) (*KeyPair, error) {
+	pri, err := os.ReadFile(privateFile)
+	if err != nil {
+		return nil, err
+	}
+	var pub []byte
+	if publicFile != "" {
+		pub, err = os.ReadFile(publicFile)
+		if err != nil {
+			return nil, err
+		}
 	}
-	// Parse the key
-	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	return KeyPairFromPEM(method, pri, pub, password)
+}
+
+// KeyPairFromPEM works just like LoadKeyPair, except that it takes the
+// contents of the files instead.
+func KeyPairFromPEM(method SigningMethod, privatePEM, publicPEM, password []byte) (*KeyPair, error) {
+	var keys KeyPair
+	if _, ok := method.(*SigningMethodHMAC); ok {
+		secret := bytes.TrimSpace(privatePEM)
+		keys.PrivateKey, keys.PublicKey = secret, secret
+	} else {
+		pri, err := ParsePrivateKeyFromPEMWithPassword(privatePEM, password)
+		if err != nil {
+			return nil, err
+		}
+		keys.PrivateKey = pri
+		if publicPEM != nil {
+			keys.PublicKey, err = ParsePublicKeyFromPEM(publicPEM)
+			if err != nil {
+				return nil, err
+			}
+		} else if signer, ok := pri.(crypto.Signer); ok {
+			keys.PublicKey = signer.Public()
+		}
+	}
+	err := ValidateKeyPair(method, &keys)
+	if err != nil {
+		return nil, err
+	}
+	return &keys, nil
+}
+
+// ValidateKeyPair checks that the keys are of the right type for the
+// signing method (including the curve for ECDSA methods), and that the
+// public key belongs to the private key.
+func ValidateKeyPair(method SigningMethod, keys *KeyPair) error {
+	err := checkKeyType(method, keys.PublicKey)
 	if err != nil {
-		return nil, ErrParsingPEMPublicKey
+		return err
+	}
+	if _, ok := method.(*SigningMethodHMAC); ok {
+		pri, _ := keys.PrivateKey.([]byte)
+		if !hmac.Equal(pri, keys.PublicKey.([]byte)) {
+			return ErrKeyPairMismatch
+		}
+		return nil
+	}
+	signer, ok := keys.PrivateKey.(crypto.Signer)
+	if !ok {
+		return ErrInvalidKeyType
+	}
+	pub, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !pub.Equal(keys.PublicKey) {
+		return ErrKeyPairMismatch
 	}
-	return pub, nil
+	return nil
 }
 
 func PrivateKeyType(key crypto.PrivateKey) {
@@ -0,0 +1,293 @@
+package jwt
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Claims that hold the scopes and roles of a token. The scope claim is a
+// space separated string (RFC 8693, section 4.2), but some providers use a
+// scp claim holding an array instead, so both are checked.
+const (
+	ScopeClaim = "scope"
+	ScpClaim   = "scp"
+	RolesClaim = "roles"
+)
+
+// ScopeError is returned by the scope requirements when the token does not
+// have the required scopes. The scopes are reported in the scope attribute
+// of the WWW-Authenticate header by WriteAuthError, as suggested by RFC
+// 6750, section 3.
+type ScopeError struct {
+	Scopes []string
+}
+
+func (e *ScopeError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrTokenInsufficientScope, strings.Join(e.Scopes, " "))
+}
+
+func (e *ScopeError) Unwrap() error {
+	return ErrTokenInsufficientScope
+}
+
+// RequireScope returns a ClaimRequirement that checks that the token has
+// every one of the provided scopes.
+func RequireScope(scopes ...string) ClaimRequirement {
+	return func(claims MapClaims) error {
+		have := claimScopes(claims)
+		for _, scope := range scopes {
+			if !containsString(have, scope) {
+				return &ScopeError{Scopes: scopes}
+			}
+		}
+		return nil
+	}
+}
+
+// RequireAnyScope returns a ClaimRequirement that checks that the token has
+// at least one of the provided scopes.
+func RequireAnyScope(scopes ...string) ClaimRequirement {
+	return func(claims MapClaims) error {
+		have := claimScopes(claims)
+		for _, scope := range scopes {
+			if containsString(have, scope) {
+				return nil
+			}
+		}
+		return &ScopeError{Scopes: scopes}
+	}
+}
+
+// RequireAnyRole returns a ClaimRequirement that checks that the roles
+// claim holds at least one of the provided roles.
+func RequireAnyRole(roles ...string) ClaimRequirement {
+	return RequireAnyValue(RolesClaim, roles...)
+}
+
+// RequireRoles returns a ClaimRequirement that checks that the roles claim
+// holds every one of the provided roles.
+func RequireRoles(roles ...string) ClaimRequirement {
+	return RequireAllValues(RolesClaim, roles...)
+}
+
+// RequireAnyValue returns a ClaimRequirement that checks that the claim
+// (a string, or an array of strings) holds at least one of the values.
+// Nested claims can be referred to using a dotted path, such as
+// "realm_access.roles".
+func RequireAnyValue(name string, values ...string) ClaimRequirement {
+	return func(claims MapClaims) error {
+		have, err := claimStrings(claims, name)
+		if err != nil {
+			return err
+		}
+		for _, v := range values {
+			if containsString(have, v) {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %q must hold one of %q", ErrTokenClaimMismatch, name, values)
+	}
+}
+
+// RequireAllValues returns a ClaimRequirement that checks that the claim
+// (a string, or an array of strings) holds every one of the values. Nested
+// claims can be referred to using a dotted path.
+func RequireAllValues(name string, values ...string) ClaimRequirement {
+	return func(claims MapClaims) error {
+		have, err := claimStrings(claims, name)
+		if err != nil {
+			return err
+		}
+		for _, v := range values {
+			if !containsString(have, v) {
+				return fmt.Errorf("%w: %q must hold %q", ErrTokenClaimMismatch, name, v)
+			}
+		}
+		return nil
+	}
+}
+
+// RequireClaimMatch returns a ClaimRequirement that checks that the claim
+// (a string, or an array of strings) matches the glob pattern. In the
+// pattern, '*' matches any sequence of characters and '?' matches any
+// single character. For arrays, any one of the values must match. Nested
+// claims can be referred to using a dotted path.
+func RequireClaimMatch(name, pattern string) ClaimRequirement {
+	return func(claims MapClaims) error {
+		have, err := claimStrings(claims, name)
+		if err != nil {
+			return err
+		}
+		for _, v := range have {
+			if globMatch(pattern, v) {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %q must match %q", ErrTokenClaimMismatch, name, pattern)
+	}
+}
+
+// AllOf returns a ClaimRequirement that is met when all the requirements
+// are met. The first failed requirement is returned.
+func AllOf(reqs ...ClaimRequirement) ClaimRequirement {
+	return func(claims MapClaims) error {
+		for _, req := range reqs {
+			if err := req(claims); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// AnyOf returns a ClaimRequirement that is met when at least one of the
+// requirements is met. If none are, all the failures are returned.
+func AnyOf(reqs ...ClaimRequirement) ClaimRequirement {
+	return func(claims MapClaims) error {
+		var errs []error
+		for _, req := range reqs {
+			err := req(claims)
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, err)
+		}
+		if len(errs) == 0 {
+			return ErrTokenClaimMismatch
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// Not returns a ClaimRequirement that is met when the requirement is not.
+func Not(req ClaimRequirement) ClaimRequirement {
+	return func(claims MapClaims) error {
+		if req(claims) == nil {
+			return fmt.Errorf("%w: negated requirement was met", ErrTokenClaimMismatch)
+		}
+		return nil
+	}
+}
+
+// Authorize returns http middleware that checks the token placed in the
+// request context by the Middleware of a TokenManager against the
+// requirements. It allows a single Middleware to authenticate requests,
+// while each route has its own policy. Requests without a token are
+// rejected with a 401, and requests with a token that does not meet the
+// requirements are rejected with a 403.
+func Authorize(reqs ...ClaimRequirement) func(http.Handler) http.Handler {
+	req := AllOf(reqs...)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				token, ok := TokenFromContext(r.Context())
+				if !ok {
+					WriteAuthError(w, r, &AuthError{http.StatusUnauthorized, "", "", ErrNoTokenInRequest})
+					return
+				}
+				if err := req(token.Payload); err != nil {
+					WriteAuthError(w, r, &AuthError{http.StatusForbidden, AuthErrorInsufficientScope, "", err})
+					return
+				}
+				next.ServeHTTP(w, r)
+			},
+		)
+	}
+}
+
+// lookupClaim returns the value of the claim. If there is no claim with
+// the name, it is treated as a dotted path into nested claims.
+func lookupClaim(claims MapClaims, name string) (any, bool) {
+	if v, found := claims[name]; found {
+		return v, true
+	}
+	var v any = map[string]any(claims)
+	for _, part := range strings.Split(name, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// claimStrings returns the claim as a list of strings. A string claim
+// returns a list holding just that string.
+func claimStrings(claims MapClaims, name string) ([]string, error) {
+	v, found := lookupClaim(claims, name)
+	if !found {
+		return nil, fmt.Errorf("%w: %q", ErrTokenClaimNotFound, name)
+	}
+	switch v := v.(type) {
+	case string:
+		return []string{v}, nil
+	case []string:
+		return v, nil
+	case []any:
+		s := make([]string, 0, len(v))
+		for _, e := range v {
+			str, ok := e.(string)
+			if !ok {
+				return nil, fmt.Errorf("%w: %q must hold strings", ErrTokenMalformed, name)
+			}
+			s = append(s, str)
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("%w: %q must be a string or an array of strings", ErrTokenMalformed, name)
+}
+
+// claimScopes returns the scopes of the token, from either the scope or
+// the scp claim.
+func claimScopes(claims MapClaims) []string {
+	var scopes []string
+	if s, ok := claims[ScopeClaim].(string); ok {
+		scopes = strings.Fields(s)
+	}
+	switch scp := claims[ScpClaim].(type) {
+	case string:
+		scopes = append(scopes, strings.Fields(scp)...)
+	case []any:
+		for _, e := range scp {
+			if s, ok := e.(string); ok {
+				scopes = append(scopes, s)
+			}
+		}
+	}
+	return scopes
+}
+
+// globMatch reports whether s matches the pattern, where '*' matches any
+// sequence of characters (including none) and '?' matches any single
+// character. Unlike path.Match, '/' is not treated specially.
+func globMatch(pattern, s string) bool {
+	p, str := []rune(pattern), []rune(s)
+	pi, si := 0, 0
+	// The position of the last '*' seen, and where in s it would resume
+	star, resume := -1, 0
+	for si < len(str) {
+		switch {
+		case pi < len(p) && p[pi] == '*':
+			star, resume = pi, si
+			pi++
+		case pi < len(p) && (p[pi] == '?' || p[pi] == str[si]):
+			pi++
+			si++
+		case star >= 0:
+			// Let the last '*' match one more character
+			resume++
+			pi, si = star+1, resume
+		default:
+			return false
+		}
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
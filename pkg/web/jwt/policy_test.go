@@ -0,0 +1,145 @@
+package jwt
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClaimRequirements(t *testing.T) {
+	var claims MapClaims
+	err := decodeTestClaims(
+		`{
+			"sub": "alice",
+			"email": "alice@example.com",
+			"scope": "orders:read orders:write",
+			"scp": ["profile"],
+			"roles": ["user", "billing"],
+			"level": 3,
+			"realm_access": {"roles": ["offline_access"]},
+			"groups": ["/eng/backend", "/ops"]
+		}`, &claims,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		req  ClaimRequirement
+		err  error
+	}{
+		{"scope", RequireScope("orders:write"), nil},
+		{"scp", RequireScope("orders:read", "profile"), nil},
+		{"missing scope", RequireScope("orders:write", "admin"), ErrTokenInsufficientScope},
+		{"any scope", RequireAnyScope("admin", "orders:read"), nil},
+		{"no scope", RequireAnyScope("admin"), ErrTokenInsufficientScope},
+		{"any role", RequireAnyRole("admin", "billing"), nil},
+		{"no role", RequireAnyRole("admin"), ErrTokenClaimMismatch},
+		{"roles", RequireRoles("user", "billing"), nil},
+		{"missing role", RequireRoles("user", "admin"), ErrTokenClaimMismatch},
+		{"nested", RequireAnyValue("realm_access.roles", "offline_access"), nil},
+		{"missing nested", RequireAnyValue("realm_access.groups", "x"), ErrTokenClaimNotFound},
+		{"not strings", RequireAnyValue("level", "3"), ErrTokenMalformed},
+		{"glob", RequireClaimMatch("email", "*@example.com"), nil},
+		{"glob mismatch", RequireClaimMatch("email", "*@example.org"), ErrTokenClaimMismatch},
+		{"glob slash", RequireClaimMatch("groups", "/eng/*"), nil},
+		{"glob single", RequireClaimMatch("sub", "al?ce"), nil},
+		{"equal number", RequireClaim("level", 3), nil},
+		{"equal array", RequireClaim("roles", []string{"user", "billing"}), nil},
+		{"equal nested", RequireClaim("realm_access.roles", []string{"offline_access"}), nil},
+		{"not equal", RequireClaim("level", 4), ErrTokenClaimMismatch},
+		{"all of", AllOf(RequireScope("orders:read"), RequireAnyRole("user")), nil},
+		{"all of failing", AllOf(RequireScope("orders:read"), RequireAnyRole("admin")), ErrTokenClaimMismatch},
+		{"any of", AnyOf(RequireAnyRole("admin"), RequireScope("orders:write")), nil},
+		{"any of failing", AnyOf(RequireAnyRole("admin"), RequireScope("admin")), ErrTokenInsufficientScope},
+		{"not", Not(RequireAnyRole("admin")), nil},
+		{"not failing", Not(RequireAnyRole("user")), ErrTokenClaimMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				err := tt.req(claims)
+				if tt.err == nil && err != nil {
+					t.Errorf("expected the requirement to be met, got %v", err)
+				}
+				if tt.err != nil && !errors.Is(err, tt.err) {
+					t.Errorf("expected %v, got %v", tt.err, err)
+				}
+			},
+		)
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		match      bool
+	}{
+		{"", "", true},
+		{"*", "", true},
+		{"*", "anything/at/all", true},
+		{"a*", "abc", true},
+		{"a*c", "abbbc", true},
+		{"a*c", "abbbd", false},
+		{"*.example.com", "api.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{"*a*b", "xaxxb", true},
+		{"*a*b", "xaxxbx", false},
+		{"*", "*a", true},
+		{"ü?", "üx", true},
+	}
+	for _, tt := range tests {
+		if got := globMatch(tt.pattern, tt.s); got != tt.match {
+			t.Errorf("globMatch(%q, %q) = %v, wanted %v", tt.pattern, tt.s, got, tt.match)
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	tm := NewTokenManager(HS256, HS256.GenerateKeyPair())
+	raw, err := tm.GenerateToken(
+		MapClaims{
+			"scope": "orders:read",
+			"exp":   NumericDateNow().Add(time.Hour),
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	authenticate := tm.Middleware(&MiddlewareOptions{Optional: true})
+
+	tests := []struct {
+		req       ClaimRequirement
+		token     bool
+		status    int
+		challenge string
+	}{
+		{RequireScope("orders:read"), true, http.StatusOK, ""},
+		{RequireScope("orders:write"), true, http.StatusForbidden, `Bearer error="insufficient_scope", scope="orders:write"`},
+		{RequireScope("orders:read"), false, http.StatusUnauthorized, "Bearer"},
+	}
+	for _, tt := range tests {
+		handler := authenticate(Authorize(tt.req)(next))
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.token {
+			r.Header.Set("Authorization", "Bearer "+string(raw))
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != tt.status {
+			t.Errorf("wanted status %d, got %d", tt.status, w.Code)
+		}
+		assert(t, tt.challenge, w.Header().Get("WWW-Authenticate"))
+	}
+}
+
+// decodeTestClaims decodes the JSON claims the same way the parser does.
+func decodeTestClaims(s string, claims *MapClaims) error {
+	return decodeSegment(PayloadSection, Base64Encode([]byte(s)), claims)
+}
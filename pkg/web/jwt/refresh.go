@@ -0,0 +1,377 @@
+package jwt
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenPair is a short-lived access token along with the long-lived
+// refresh token that can be used to obtain a new pair. It marshals to
+// the token response format described in RFC 6749, section 5.1.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// maxRefreshHistory is the number of previously issued refresh tokens that
+// are remembered for each family. Replaying a refresh token older than that
+// is treated like presenting a refresh token that was never issued.
+const maxRefreshHistory = 100
+
+// RefreshFamily is the server side state of a chain of refresh tokens.
+// Every time a refresh token is used it is replaced by a new one, and
+// only the most recent refresh token in the family is valid. If a
+// refresh token that has already been used shows up again, one of the
+// parties holding it is an attacker, so the whole family is revoked.
+type RefreshFamily struct {
+
+	// ID is the unique identifier of the family.
+	ID string
+
+	// Subject is the subject the access tokens are issued to.
+	Subject string
+
+	// Claims holds any additional claims that are added to each of the
+	// access tokens issued to the family.
+	Claims MapClaims
+
+	// Current holds the hash of the current refresh token secret.
+	Current []byte
+
+	// Previous holds the hashes of the refresh token secrets issued to
+	// the family before the current one, oldest first. They are used to
+	// tell a replayed refresh token apart from one that was never issued.
+	Previous [][]byte
+
+	// JKT is the thumbprint of the DPoP key the family is bound to, or
+	// empty if it is not bound. The refresh tokens of a bound family can
+	// only be used along with a proof signed using that key, see
+	// RefreshDPoPTokenPair.
+	JKT string
+
+	// AccessID and AccessExpires identify the most recently issued access
+	// token, so it can be revoked along with the family.
+	AccessID      string
+	AccessExpires time.Time
+
+	// Expires is the time the current refresh token expires at.
+	Expires time.Time
+
+	// Revoked is set once the family has been revoked.
+	Revoked bool
+}
+
+// RefreshTokenStore is an interface for storing refresh token families.
+type RefreshTokenStore interface {
+
+	// Save should store the provided family, replacing any existing family
+	// with the same id.
+	Save(f *RefreshFamily) error
+
+	// Load should return the family with the provided id, or
+	// ErrRefreshTokenInvalid if there is no such family.
+	Load(id string) (*RefreshFamily, error)
+
+	// Swap should atomically replace the stored family with the provided
+	// family, but only if the current refresh token hash of the stored
+	// family matches prev. Otherwise, it should return
+	// ErrRefreshTokenReused.
+	Swap(f *RefreshFamily, prev []byte) error
+}
+
+// MemoryRefreshTokenStore is an in-memory RefreshTokenStore. Families are
+// removed automatically once their refresh token expires.
+type MemoryRefreshTokenStore struct {
+	mu sync.Mutex
+	m  *timeoutMap[string, RefreshFamily]
+
+	// Clock is the source of the current time; if it is left as nil,
+	// SystemClock will be used.
+	Clock Clock
+}
+
+// NewMemoryRefreshTokenStore initializes and returns a new, empty
+// MemoryRefreshTokenStore that cleans up expired families at the interval
+// supplied.
+func NewMemoryRefreshTokenStore(interval time.Duration) *MemoryRefreshTokenStore {
+	s := &MemoryRefreshTokenStore{}
+	s.m = newTimeoutMap[string, RefreshFamily](interval, s.now)
+	return s
+}
+
+func (s *MemoryRefreshTokenStore) now() time.Time {
+	return clockNow(s.Clock)
+}
+
+func (s *MemoryRefreshTokenStore) Save(f *RefreshFamily) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m.put(f.ID, *f, f.Expires)
+	return nil
+}
+
+func (s *MemoryRefreshTokenStore) Load(id string) (*RefreshFamily, error) {
+	f, found := s.m.get(id)
+	if !found {
+		return nil, ErrRefreshTokenInvalid
+	}
+	return &f, nil
+}
+
+func (s *MemoryRefreshTokenStore) Swap(f *RefreshFamily, prev []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, found := s.m.get(f.ID)
+	if !found {
+		return ErrRefreshTokenInvalid
+	}
+	if old.Revoked || subtle.ConstantTimeCompare(old.Current, prev) != 1 {
+		return ErrRefreshTokenReused
+	}
+	s.m.put(f.ID, *f, f.Expires)
+	return nil
+}
+
+// Close stops the background cleaner of the store.
+func (s *MemoryRefreshTokenStore) Close() error {
+	s.m.stop()
+	return nil
+}
+
+// IssueTokenPair issues a new access token for the subject, along with a
+// refresh token that starts a new refresh token family. The claims are
+// optional, and are added to every access token issued to the family.
+// The manager must have a RefreshTokenStore.
+func (m *TokenManager) IssueTokenPair(sub string, claims MapClaims) (*TokenPair, error) {
+	return m.issueTokenFamily(sub, "", claims)
+}
+
+// issueTokenFamily starts a new refresh token family, bound to the DPoP
+// key with the thumbprint jkt unless it is empty.
+func (m *TokenManager) issueTokenFamily(sub, jkt string, claims MapClaims) (*TokenPair, error) {
+	if m.RefreshTokenStore == nil {
+		return nil, ErrNoRefreshTokenStore
+	}
+	id, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+	f := &RefreshFamily{
+		ID:      id,
+		Subject: sub,
+		Claims:  claims,
+		JKT:     jkt,
+	}
+	pair, err := m.issueTokenPair(f)
+	if err != nil {
+		return nil, err
+	}
+	err = m.RefreshTokenStore.Save(f)
+	if err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// RefreshTokenPair exchanges a refresh token for a new token pair. The
+// refresh token is rotated, so the provided refresh token cannot be used
+// again. If a refresh token that has already been used is presented, the
+// whole family is revoked (along with the most recently issued access
+// token if the manager has a RevocationStore) and ErrRefreshTokenReused
+// is returned. A refresh token that was never issued to the family is
+// rejected with ErrRefreshTokenInvalid, and leaves the family untouched.
+// The refresh tokens of a family bound to a DPoP key are rejected with
+// ErrDPoPProofMissing, they must be used with RefreshDPoPTokenPair.
+func (m *TokenManager) RefreshTokenPair(refreshToken string) (*TokenPair, error) {
+	return m.refreshTokenPair(refreshToken, "")
+}
+
+// refreshTokenPair exchanges a refresh token for a new token pair, as long
+// as the family is bound to the DPoP key with the thumbprint jkt, or jkt
+// is empty and the family is not bound.
+func (m *TokenManager) refreshTokenPair(refreshToken, jkt string) (*TokenPair, error) {
+	f, err := m.lookupRefreshToken(refreshToken)
+	if err == ErrRefreshTokenReused {
+		// The refresh token was issued to the family before the current
+		// one, so it has been used before.
+		m.revokeFamily(f)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(f.JKT), []byte(jkt)) != 1 {
+		if jkt == "" {
+			return nil, ErrDPoPProofMissing
+		}
+		return nil, ErrDPoPBindingMismatch
+	}
+
+	// Issue the new pair, and rotate the refresh token
+	prev := f.Current
+	next := *f
+	pair, err := m.issueTokenPair(&next)
+	if err != nil {
+		return nil, err
+	}
+	err = m.RefreshTokenStore.Swap(&next, prev)
+	if err != nil {
+		if err == ErrRefreshTokenReused {
+			// Someone else used the same refresh token concurrently.
+			m.revokeFamily(f)
+		}
+		return nil, err
+	}
+	return pair, nil
+}
+
+// RevokeTokenFamily revokes the refresh token family that the refresh
+// token belongs to, so none of the refresh tokens in the family can be
+// used anymore. The refresh token must be one that was issued to the
+// family, otherwise ErrRefreshTokenInvalid is returned.
+func (m *TokenManager) RevokeTokenFamily(refreshToken string) error {
+	f, err := m.lookupRefreshToken(refreshToken)
+	switch err {
+	case nil, ErrRefreshTokenReused:
+		return m.revokeFamily(f)
+	case ErrRefreshTokenRevoked, ErrRefreshTokenExpired:
+		// There is nothing left to revoke
+		return nil
+	}
+	return err
+}
+
+// lookupRefreshToken returns the family of the refresh token, as long as
+// it is the current refresh token of a family that is still active. If it
+// is a refresh token that was issued to the family before the current one,
+// the family is returned along with ErrRefreshTokenReused, but it is up to
+// the caller to revoke the family. Refresh tokens that were never issued
+// to the family are rejected with ErrRefreshTokenInvalid, before anything
+// else about the family is revealed.
+func (m *TokenManager) lookupRefreshToken(refreshToken string) (*RefreshFamily, error) {
+	if m.RefreshTokenStore == nil {
+		return nil, ErrNoRefreshTokenStore
+	}
+	id, secret, err := splitRefreshToken(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	f, err := m.RefreshTokenStore.Load(id)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(secret)
+	if subtle.ConstantTimeCompare(hash[:], f.Current) != 1 {
+		if f.used(hash[:]) {
+			return f, ErrRefreshTokenReused
+		}
+		// A mistyped or truncated refresh token, or a guess
+		return nil, ErrRefreshTokenInvalid
+	}
+	if f.Revoked {
+		return nil, ErrRefreshTokenRevoked
+	}
+	if !m.now().Before(f.Expires) {
+		return nil, ErrRefreshTokenExpired
+	}
+	return f, nil
+}
+
+// used reports whether the hash belongs to a refresh token that was issued
+// to the family before the current one.
+func (f *RefreshFamily) used(hash []byte) bool {
+	var found int
+	for _, prev := range f.Previous {
+		found |= subtle.ConstantTimeCompare(hash, prev)
+	}
+	return found == 1
+}
+
+func (m *TokenManager) revokeFamily(f *RefreshFamily) error {
+	if m.RevocationStore != nil && f.AccessID != "" {
+		m.RevocationStore.Revoke(f.AccessID, f.AccessExpires.Add(m.leeway(m.ExpLeeway)))
+	}
+	f.Revoked = true
+	return m.RefreshTokenStore.Save(f)
+}
+
+// issueTokenPair issues a new access token and refresh token for the
+// family, and updates the family to match.
+func (m *TokenManager) issueTokenPair(f *RefreshFamily) (*TokenPair, error) {
+	now := m.now()
+	jti, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+	exp := now.Add(m.AccessTokenTTL)
+	claims := make(MapClaims, len(f.Claims)+4)
+	for k, v := range f.Claims {
+		claims[k] = v
+	}
+	claims["sub"] = f.Subject
+	claims["iat"] = now.Unix()
+	claims["exp"] = exp.Unix()
+	claims["jti"] = jti
+	access, err := m.GenerateToken(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := randomBytes(32)
+	if err != nil {
+		return nil, err
+	}
+	if f.Current != nil {
+		// Remember the refresh token being replaced. The family may share
+		// its history with the stored family, so a new slice is used.
+		prev := f.Previous
+		if len(prev) >= maxRefreshHistory {
+			prev = prev[len(prev)-maxRefreshHistory+1:]
+		}
+		f.Previous = append(append(make([][]byte, 0, len(prev)+1), prev...), f.Current)
+	}
+	hash := sha256.Sum256(secret)
+	f.Current = hash[:]
+	f.AccessID = jti
+	f.AccessExpires = exp
+	f.Expires = now.Add(m.RefreshTokenTTL)
+
+	tokenType := "Bearer"
+	if dpopBinding(claims) != "" {
+		tokenType = "DPoP"
+	}
+	return &TokenPair{
+		AccessToken:  string(access),
+		TokenType:    tokenType,
+		ExpiresIn:    int64(m.AccessTokenTTL / time.Second),
+		RefreshToken: f.ID + "." + base64.RawURLEncoding.EncodeToString(secret),
+	}, nil
+}
+
+// splitRefreshToken splits an opaque refresh token into the family id and
+// the secret.
+func splitRefreshToken(refreshToken string) (string, []byte, error) {
+	id, enc, found := strings.Cut(refreshToken, ".")
+	if !found || id == "" {
+		return "", nil, ErrRefreshTokenInvalid
+	}
+	secret, err := base64.RawURLEncoding.DecodeString(enc)
+	if err != nil || len(secret) == 0 {
+		return "", nil, ErrRefreshTokenInvalid
+	}
+	return id, secret, nil
+}
+
+func randomString(n int) (string, error) {
+	b, err := randomBytes(n)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,140 @@
+package jwt
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newRefreshTestManager(t *testing.T) *TokenManager {
+	t.Helper()
+	tm := NewTokenManager(ES256, ES256.GenerateKeyPair())
+	refreshStore := NewMemoryRefreshTokenStore(time.Minute)
+	revocationStore := NewMemoryRevocationStore(time.Minute)
+	t.Cleanup(
+		func() {
+			refreshStore.Close()
+			revocationStore.Close()
+		},
+	)
+	tm.RefreshTokenStore = refreshStore
+	tm.RevocationStore = revocationStore
+	return tm
+}
+
+func TestTokenManager_RefreshTokenPair(t *testing.T) {
+	tm := newRefreshTestManager(t)
+
+	p1, err := tm.IssueTokenPair("jon doe", MapClaims{"scope": "orders:read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, "Bearer", p1.TokenType)
+	assert(t, int64(15*60), p1.ExpiresIn)
+
+	tok, err := tm.ValidateToken(RawToken(p1.AccessToken))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, _ := tok.Payload.GetSUB()
+	assert(t, "jon doe", sub)
+	assert(t, "orders:read", tok.Payload["scope"])
+
+	// Exchange the refresh token for a new pair
+	p2, err := tm.RefreshTokenPair(p1.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p2.RefreshToken == p1.RefreshToken || p2.AccessToken == p1.AccessToken {
+		t.Fatalf("expected a new token pair")
+	}
+	tok, err = tm.ValidateToken(RawToken(p2.AccessToken))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, "orders:read", tok.Payload["scope"])
+
+	// The new refresh token can be used again
+	p3, err := tm.RefreshTokenPair(p2.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replaying an already used refresh token revokes the family
+	_, err = tm.RefreshTokenPair(p1.RefreshToken)
+	if err != ErrRefreshTokenReused {
+		t.Errorf("expected %v, got %v", ErrRefreshTokenReused, err)
+	}
+	_, err = tm.RefreshTokenPair(p3.RefreshToken)
+	if err != ErrRefreshTokenRevoked {
+		t.Errorf("expected %v, got %v", ErrRefreshTokenRevoked, err)
+	}
+
+	// Along with the most recently issued access token
+	_, err = tm.ValidateToken(RawToken(p3.AccessToken))
+	if !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("expected %v, got %v", ErrTokenRevoked, err)
+	}
+}
+
+func TestTokenManager_RefreshTokenPairErrors(t *testing.T) {
+	tm := newRefreshTestManager(t)
+
+	for _, refreshToken := range []string{"", "nodot", ".abc", "unknown.abc", "abc.!!!"} {
+		_, err := tm.RefreshTokenPair(refreshToken)
+		if err != ErrRefreshTokenInvalid {
+			t.Errorf("[%q] expected %v, got %v", refreshToken, ErrRefreshTokenInvalid, err)
+		}
+	}
+
+	// Refresh tokens that were never issued to the family are rejected,
+	// but do not revoke the family
+	p, err := tm.IssueTokenPair("jon doe", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _, _ := strings.Cut(p.RefreshToken, ".")
+	for _, refreshToken := range []string{id + ".abc", p.RefreshToken[:len(p.RefreshToken)-2], p.RefreshToken + "x"} {
+		if _, err = tm.RefreshTokenPair(refreshToken); err != ErrRefreshTokenInvalid {
+			t.Errorf("[%q] expected %v, got %v", refreshToken, ErrRefreshTokenInvalid, err)
+		}
+		if err = tm.RevokeTokenFamily(refreshToken); err != ErrRefreshTokenInvalid {
+			t.Errorf("[%q] expected %v, got %v", refreshToken, ErrRefreshTokenInvalid, err)
+		}
+	}
+	if p, err = tm.RefreshTokenPair(p.RefreshToken); err != nil {
+		t.Fatalf("expected the family to still be active, got %v", err)
+	}
+
+	tm.RefreshTokenTTL = -time.Second
+	p, err = tm.IssueTokenPair("jon doe", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tm.RefreshTokenPair(p.RefreshToken)
+	if err != ErrRefreshTokenInvalid && err != ErrRefreshTokenExpired {
+		t.Errorf("expected expired refresh token, got %v", err)
+	}
+
+	tm.RefreshTokenTTL = time.Hour
+	p, err = tm.IssueTokenPair("jon doe", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tm.RevokeTokenFamily(p.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+	_, err = tm.RefreshTokenPair(p.RefreshToken)
+	if err != ErrRefreshTokenRevoked {
+		t.Errorf("expected %v, got %v", ErrRefreshTokenRevoked, err)
+	}
+	if err = tm.RevokeTokenFamily(p.RefreshToken); err != nil {
+		t.Errorf("expected revoking twice to succeed, got %v", err)
+	}
+
+	tm.RefreshTokenStore = nil
+	if _, err = tm.IssueTokenPair("jon doe", nil); err != ErrNoRefreshTokenStore {
+		t.Errorf("expected %v, got %v", ErrNoRefreshTokenStore, err)
+	}
+}
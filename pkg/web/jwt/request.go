@@ -6,12 +6,154 @@ import (
 	"strings"
 )
 
-func ExtractTokenFromRequest(r *http.Request) (RawToken, error) {
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" || !strings.HasPrefix(strings.ToLower(authHeader), "bearer ") {
+// Extractor is an interface for extracting a raw token from a request.
+// Implementations should return ErrNoTokenInRequest if the request does
+// not carry a token in the place they are looking.
+type Extractor interface {
+	Extract(r *http.Request) (RawToken, error)
+}
+
+// ExtractorFunc is an adapter that allows an ordinary function to be used
+// as an Extractor.
+type ExtractorFunc func(r *http.Request) (RawToken, error)
+
+func (fn ExtractorFunc) Extract(r *http.Request) (RawToken, error) {
+	return fn(r)
+}
+
+// HeaderExtractor extracts the token from a request header. By default,
+// it looks for a bearer token in the Authorization header.
+type HeaderExtractor struct {
+
+	// Header is the name of the header. It defaults to "Authorization".
+	Header string
+
+	// Scheme is the authentication scheme that must prefix the token,
+	// matched case-insensitively. It defaults to "Bearer". Set it to "-"
+	// if the header holds nothing but the token.
+	Scheme string
+}
+
+func (e HeaderExtractor) Extract(r *http.Request) (RawToken, error) {
+	header := e.Header
+	if header == "" {
+		header = "Authorization"
+	}
+	scheme := e.Scheme
+	if scheme == "" {
+		scheme = "Bearer"
+	}
+	v := r.Header.Get(header)
+	if scheme != "-" {
+		if len(v) <= len(scheme) || !strings.EqualFold(v[:len(scheme)], scheme) || v[len(scheme)] != ' ' {
+			return nil, ErrNoTokenInRequest
+		}
+		v = v[len(scheme)+1:]
+	}
+	v = strings.TrimSpace(v)
+	if v == "" {
 		return nil, ErrNoTokenInRequest
 	}
-	return RawToken(authHeader[7:]), nil
+	return RawToken(v), nil
+}
+
+// CookieExtractor extracts the token from the cookie with the provided
+// name.
+type CookieExtractor struct {
+	Name string
+}
+
+func (e CookieExtractor) Extract(r *http.Request) (RawToken, error) {
+	c, err := r.Cookie(e.Name)
+	if err != nil || c.Value == "" {
+		return nil, errors.Join(ErrNoTokenInRequest, ErrNoCookieFound)
+	}
+	return RawToken(c.Value), nil
+}
+
+// QueryExtractor extracts the token from the query string parameter with
+// the provided name.
+type QueryExtractor struct {
+	Param string
+}
+
+func (e QueryExtractor) Extract(r *http.Request) (RawToken, error) {
+	v := r.URL.Query().Get(e.Param)
+	if v == "" {
+		return nil, ErrNoTokenInRequest
+	}
+	return RawToken(v), nil
+}
+
+// FormExtractor extracts the token from the form body field with the
+// provided name. Only the request body is checked, not the query string.
+type FormExtractor struct {
+	Field string
+}
+
+func (e FormExtractor) Extract(r *http.Request) (RawToken, error) {
+	v := r.PostFormValue(e.Field)
+	if v == "" {
+		return nil, ErrNoTokenInRequest
+	}
+	return RawToken(v), nil
+}
+
+// WebSocketProtocolExtractor extracts the token from the
+// Sec-WebSocket-Protocol header. Browsers cannot set an Authorization
+// header on a websocket handshake, so clients instead offer a protocol
+// consisting of a prefix followed by the token, for example
+// "access_token.<token>".
+type WebSocketProtocolExtractor struct {
+
+	// Prefix is the prefix of the protocol carrying the token. It
+	// defaults to "access_token.".
+	Prefix string
+}
+
+func (e WebSocketProtocolExtractor) Extract(r *http.Request) (RawToken, error) {
+	prefix := e.Prefix
+	if prefix == "" {
+		prefix = "access_token."
+	}
+	for _, v := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(v, ",") {
+			protocol = strings.TrimSpace(protocol)
+			if strings.HasPrefix(protocol, prefix) && len(protocol) > len(prefix) {
+				return RawToken(protocol[len(prefix):]), nil
+			}
+		}
+	}
+	return nil, ErrNoTokenInRequest
+}
+
+// MultiExtractor tries each of the extractors in order, and returns the
+// first token that is found. If an extractor returns an error other than
+// ErrNoTokenInRequest, that error is returned right away.
+type MultiExtractor []Extractor
+
+func (e MultiExtractor) Extract(r *http.Request) (RawToken, error) {
+	for _, extractor := range e {
+		raw, err := extractor.Extract(r)
+		if err == nil {
+			return raw, nil
+		}
+		if !errors.Is(err, ErrNoTokenInRequest) {
+			return nil, err
+		}
+	}
+	return nil, ErrNoTokenInRequest
+}
+
+// DefaultExtractor looks for a bearer token in the Authorization header
+// first, and then in a cookie named "token".
+var DefaultExtractor Extractor = MultiExtractor{
+	HeaderExtractor{},
+	CookieExtractor{Name: "token"},
+}
+
+func ExtractTokenFromRequest(r *http.Request) (RawToken, error) {
+	return HeaderExtractor{}.Extract(r)
 }
 
 func ExtractTokenFromCookie(name string, r *http.Request) (RawToken, error) {
@@ -0,0 +1,170 @@
+package jwt
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExtractors(t *testing.T) {
+	newRequest := func(target string) *http.Request {
+		return httptest.NewRequest("GET", target, nil)
+	}
+	tests := []struct {
+		name      string
+		extractor Extractor
+		req       func() *http.Request
+		want      string
+	}{
+		{
+			"bearer header",
+			HeaderExtractor{},
+			func() *http.Request {
+				r := newRequest("/")
+				r.Header.Set("Authorization", "bearer abc")
+				return r
+			},
+			"abc",
+		},
+		{
+			"wrong scheme",
+			HeaderExtractor{},
+			func() *http.Request {
+				r := newRequest("/")
+				r.Header.Set("Authorization", "Basic abc")
+				return r
+			},
+			"",
+		},
+		{
+			"custom header and scheme",
+			HeaderExtractor{Header: "X-Auth", Scheme: "Token"},
+			func() *http.Request {
+				r := newRequest("/")
+				r.Header.Set("X-Auth", "Token abc")
+				return r
+			},
+			"abc",
+		},
+		{
+			"header without scheme",
+			HeaderExtractor{Header: "X-Auth", Scheme: "-"},
+			func() *http.Request {
+				r := newRequest("/")
+				r.Header.Set("X-Auth", "abc")
+				return r
+			},
+			"abc",
+		},
+		{
+			"cookie",
+			CookieExtractor{Name: "session"},
+			func() *http.Request {
+				r := newRequest("/")
+				r.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+				return r
+			},
+			"abc",
+		},
+		{
+			"query",
+			QueryExtractor{Param: "token"},
+			func() *http.Request {
+				return newRequest("/?token=abc")
+			},
+			"abc",
+		},
+		{
+			"form",
+			FormExtractor{Field: "token"},
+			func() *http.Request {
+				body := url.Values{"token": {"abc"}}.Encode()
+				r := httptest.NewRequest("POST", "/?token=nope", strings.NewReader(body))
+				r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				return r
+			},
+			"abc",
+		},
+		{
+			"websocket",
+			WebSocketProtocolExtractor{},
+			func() *http.Request {
+				r := newRequest("/")
+				r.Header.Set("Sec-WebSocket-Protocol", "chat, access_token.abc")
+				return r
+			},
+			"abc",
+		},
+		{
+			"multi first match",
+			MultiExtractor{QueryExtractor{Param: "token"}, HeaderExtractor{}, CookieExtractor{Name: "token"}},
+			func() *http.Request {
+				r := newRequest("/")
+				r.Header.Set("Authorization", "Bearer header")
+				r.AddCookie(&http.Cookie{Name: "token", Value: "cookie"})
+				return r
+			},
+			"header",
+		},
+		{
+			"multi no match",
+			MultiExtractor{QueryExtractor{Param: "token"}, CookieExtractor{Name: "token"}},
+			func() *http.Request {
+				return newRequest("/")
+			},
+			"",
+		},
+	}
+	for _, tt := range tests {
+		raw, err := tt.extractor.Extract(tt.req())
+		if tt.want == "" {
+			if !errors.Is(err, ErrNoTokenInRequest) {
+				t.Errorf("[%s] expected %v, got %v", tt.name, ErrNoTokenInRequest, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("[%s] error extracting token: %v", tt.name, err)
+			continue
+		}
+		assert(t, tt.want, string(raw))
+	}
+}
+
+func TestTokenManager_ValidateTokenFromRequestExtractor(t *testing.T) {
+	tm := NewTokenManager(HS256, HS256.GenerateKeyPair())
+	raw, err := tm.GenerateToken(&RegisteredClaims{ExpirationTime: NumericDateNow().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The default extractor checks the header first
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer "+string(raw))
+	if _, err = tm.ValidateTokenFromRequest(r); err != nil {
+		t.Errorf("error validating token from header: %v", err)
+	}
+
+	// And then falls back on the "token" cookie
+	r = httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: "token", Value: string(raw)})
+	if _, err = tm.ValidateTokenFromRequest(r); err != nil {
+		t.Errorf("error validating token from cookie: %v", err)
+	}
+
+	// A custom extractor can be used instead
+	tm.Extractor = WebSocketProtocolExtractor{}
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Sec-WebSocket-Protocol", "access_token."+string(raw))
+	if _, err = tm.ValidateTokenFromRequest(r); err != nil {
+		t.Errorf("error validating token from websocket protocol: %v", err)
+	}
+	r.Header.Del("Sec-WebSocket-Protocol")
+	if _, err = tm.ValidateTokenFromRequest(r); !errors.Is(err, ErrNoTokenInRequest) {
+		t.Errorf("expected %v, got %v", ErrNoTokenInRequest, err)
+	}
+}
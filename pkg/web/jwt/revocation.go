@@ -0,0 +1,304 @@
+package jwt
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RevocationStore is an interface for keeping track of revoked tokens. A
+// token is identified by its unique identifier (jti), and a revoked token
+// only needs to be remembered until it expires, after which the validator
+// will reject it anyway.
+type RevocationStore interface {
+
+	// Revoke should mark the token with the provided jti as revoked until
+	// the provided expiration time. A zero expiration time means the
+	// token must be remembered forever.
+	Revoke(jti string, exp time.Time) error
+
+	// IsRevoked should report whether the token with the provided jti has
+	// been revoked.
+	IsRevoked(jti string) (bool, error)
+}
+
+// MemoryRevocationStore is an in-memory RevocationStore. Revoked entries
+// are removed automatically once the token they belong to expires.
+type MemoryRevocationStore struct {
+	m *timeoutMap[string, struct{}]
+
+	// Clock is the source of the current time; if it is left as nil,
+	// SystemClock will be used.
+	Clock Clock
+}
+
+// NewMemoryRevocationStore initializes and returns a new, empty
+// MemoryRevocationStore that cleans up expired entries at the interval
+// supplied.
+func NewMemoryRevocationStore(interval time.Duration) *MemoryRevocationStore {
+	s := &MemoryRevocationStore{}
+	s.m = newTimeoutMap[string, struct{}](interval, s.now)
+	return s
+}
+
+func (s *MemoryRevocationStore) now() time.Time {
+	return clockNow(s.Clock)
+}
+
+func (s *MemoryRevocationStore) Revoke(jti string, exp time.Time) error {
+	s.m.put(jti, struct{}{}, exp)
+	return nil
+}
+
+func (s *MemoryRevocationStore) IsRevoked(jti string) (bool, error) {
+	_, found := s.m.get(jti)
+	return found, nil
+}
+
+// Len returns the number of revoked tokens currently held by the store.
+func (s *MemoryRevocationStore) Len() int {
+	return s.m.len()
+}
+
+// Close stops the background cleaner of the store.
+func (s *MemoryRevocationStore) Close() error {
+	s.m.stop()
+	return nil
+}
+
+// FileRevocationStore is a RevocationStore that persists revoked tokens
+// to an append only file, so revocations survive a restart. Each line in
+// the file holds a jti and the unix time it expires at. The entries are
+// also kept in memory, so lookups never touch the file.
+type FileRevocationStore struct {
+	mu   sync.RWMutex
+	path string
+	file *os.File
+	m    map[string]time.Time
+
+	// Clock is the source of the current time; if it is left as nil,
+	// SystemClock will be used.
+	Clock Clock
+}
+
+// OpenFileRevocationStore opens (or creates) the revocation file at the
+// provided path and loads all the entries that have not expired yet.
+func OpenFileRevocationStore(path string) (*FileRevocationStore, error) {
+	s := &FileRevocationStore{
+		path: path,
+		m:    make(map[string]time.Time),
+	}
+	err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	s.file, err = os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load reads all the unexpired entries from the revocation file.
+func (s *FileRevocationStore) load() error {
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	now := s.now()
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		jti, exp, err := parseRevocationLine(sc.Text())
+		if err != nil {
+			return err
+		}
+		if !exp.IsZero() && !now.Before(exp) {
+			continue
+		}
+		s.m[jti] = exp
+	}
+	return sc.Err()
+}
+
+func formatRevocationLine(jti string, exp time.Time) string {
+	var unix int64
+	if !exp.IsZero() {
+		unix = exp.Unix()
+	}
+	return strconv.Quote(jti) + " " + strconv.FormatInt(unix, 10) + "\n"
+}
+
+func parseRevocationLine(line string) (string, time.Time, error) {
+	i := bytes.LastIndexByte([]byte(line), ' ')
+	if i < 0 {
+		return "", time.Time{}, fmt.Errorf("revocation: malformed line %q", line)
+	}
+	jti, err := strconv.Unquote(line[:i])
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("revocation: malformed line %q", line)
+	}
+	unix, err := strconv.ParseInt(line[i+1:], 10, 64)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("revocation: malformed line %q", line)
+	}
+	var exp time.Time
+	if unix != 0 {
+		exp = time.Unix(unix, 0)
+	}
+	return jti, exp, nil
+}
+
+func (s *FileRevocationStore) Revoke(jti string, exp time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.file.WriteString(formatRevocationLine(jti, exp))
+	if err != nil {
+		return err
+	}
+	err = s.file.Sync()
+	if err != nil {
+		return err
+	}
+	s.m[jti] = exp
+	return nil
+}
+
+func (s *FileRevocationStore) IsRevoked(jti string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	exp, found := s.m[jti]
+	if !found {
+		return false, nil
+	}
+	return exp.IsZero() || s.now().Before(exp), nil
+}
+
+func (s *FileRevocationStore) now() time.Time {
+	return clockNow(s.Clock)
+}
+
+// Compact rewrites the revocation file, leaving out all the entries that
+// have expired. The new file is written next to the old one and then
+// renamed, so a crash will never leave a partially written file behind.
+func (s *FileRevocationStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	var buf bytes.Buffer
+	for jti, exp := range s.m {
+		if !exp.IsZero() && !now.Before(exp) {
+			delete(s.m, jti)
+			continue
+		}
+		buf.WriteString(formatRevocationLine(jti, exp))
+	}
+	tmp := s.path + ".tmp"
+	err := os.WriteFile(tmp, buf.Bytes(), 0600)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp, s.path)
+	if err != nil {
+		return err
+	}
+	// Reopen, the old file descriptor points to the replaced file
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	s.file.Close()
+	s.file = file
+	return nil
+}
+
+// Close closes the revocation file.
+func (s *FileRevocationStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// checkRevocation checks that the token with the claims has not been
+// revoked. Tokens without a jti cannot be revoked.
+func (v *Validator) checkRevocation(claims ClaimsSet) *ValidationFailure {
+	if v.RevocationStore == nil {
+		return nil
+	}
+	jti, err := claims.GetJTI()
+	if err != nil || jti == "" {
+		return nil
+	}
+	revoked, err := v.RevocationStore.IsRevoked(jti)
+	if err != nil {
+		// Fail closed, we cannot tell if the token was revoked.
+		return &ValidationFailure{
+			Check:  CheckRevocation,
+			Actual: jti,
+			Detail: "revocation store unavailable",
+			Err:    errors.Join(ErrTokenRevoked, err),
+		}
+	}
+	if revoked {
+		return &ValidationFailure{
+			Check:  CheckRevocation,
+			Actual: jti,
+			Err:    ErrTokenRevoked,
+		}
+	}
+	return nil
+}
+
+// Revoke revokes the provided token, so that it will be rejected by the
+// manager from now on. The signature of the token is verified before it is
+// revoked, but the claims are not, so an expired token can be revoked too
+// (although there is no need to.) The token must carry a jti claim, and
+// the manager must have a RevocationStore.
+func (m *TokenManager) Revoke(raw RawToken) error {
+	if m.RevocationStore == nil {
+		return ErrNoRevocationStore
+	}
+	token, err := m.parser().Parse(raw)
+	if err != nil {
+		return err
+	}
+	method, keys, err := m.ring.lookup(token.Header.Kid, m.now())
+	if err != nil {
+		return err
+	}
+	if token.Header.Alg != method.Name() {
+		return ErrTokenUnverifiable
+	}
+	partialToken := raw[:bytes.LastIndexByte(raw, '.')]
+	err = method.Verify(partialToken, token.Signature, keys.PublicKey)
+	if err != nil {
+		return errors.Join(err, ErrTokenSignatureInvalid)
+	}
+	return m.RevokeClaims(token.Payload)
+}
+
+// RevokeClaims revokes the token the claims belong to, without verifying
+// anything. It is useful when the token has already been validated.
+func (m *TokenManager) RevokeClaims(claims ClaimsSet) error {
+	if m.RevocationStore == nil {
+		return ErrNoRevocationStore
+	}
+	jti, err := claims.GetJTI()
+	if err != nil || jti == "" {
+		return ErrTokenClaimNotFound
+	}
+	// Remember the token for as long as the validator could accept it,
+	// which includes the exp leeway.
+	var exp time.Time
+	if n, err := claims.GetEXP(); err == nil && n > 0 {
+		exp = n.Time().Add(m.leeway(m.ExpLeeway))
+	}
+	return m.RevocationStore.Revoke(jti, exp)
+}
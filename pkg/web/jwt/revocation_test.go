@@ -0,0 +1,132 @@
+package jwt
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTokenManager_Revoke(t *testing.T) {
+	store := NewMemoryRevocationStore(time.Minute)
+	defer store.Close()
+
+	tm := NewTokenManager(HS256, HS256.GenerateKeyPair())
+	tm.RevocationStore = store
+
+	raw, err := tm.GenerateToken(
+		&RegisteredClaims{
+			ExpirationTime: NumericDateNow().Add(time.Hour),
+			ID:             "token-1",
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tm.ValidateToken(raw); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = tm.Revoke(raw); err != nil {
+		t.Fatal(err)
+	}
+	_, err = tm.ValidateToken(raw)
+	if !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("expected %v, got %v", ErrTokenRevoked, err)
+	}
+
+	// Tokens without a jti cannot be revoked
+	raw, err = tm.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tm.Revoke(raw); !errors.Is(err, ErrTokenClaimNotFound) {
+		t.Errorf("expected %v, got %v", ErrTokenClaimNotFound, err)
+	}
+
+	// Tokens signed by someone else cannot be revoked
+	other := NewTokenManager(HS256, HS256.GenerateKeyPair())
+	raw, err = other.GenerateToken(&RegisteredClaims{ID: "token-2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tm.Revoke(raw); err == nil {
+		t.Errorf("revoked a token with an invalid signature")
+	}
+}
+
+func TestMemoryRevocationStore_Expiry(t *testing.T) {
+	store := NewMemoryRevocationStore(10 * time.Millisecond)
+	defer store.Close()
+
+	store.Revoke("expires", time.Now().Add(20*time.Millisecond))
+	store.Revoke("forever", time.Time{})
+
+	if revoked, _ := store.IsRevoked("expires"); !revoked {
+		t.Errorf("expected token to be revoked")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if revoked, _ := store.IsRevoked("expires"); revoked {
+		t.Errorf("expected revocation to have expired")
+	}
+	if revoked, _ := store.IsRevoked("forever"); !revoked {
+		t.Errorf("expected token to be revoked")
+	}
+	if n := store.Len(); n != 1 {
+		t.Errorf("expected expired entries to be cleaned up, got %d entries", n)
+	}
+}
+
+func TestFileRevocationStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revoked.db")
+	store, err := OpenFileRevocationStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Revoke("live", time.Now().Add(time.Hour))
+	store.Revoke("expired", time.Now().Add(-time.Hour))
+	store.Revoke("with \"quotes\" and spaces", time.Time{})
+	if err = store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopen the store, and make sure the entries are still there
+	store, err = OpenFileRevocationStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	for jti, want := range map[string]bool{
+		"live":                       true,
+		"expired":                    false,
+		"with \"quotes\" and spaces": true,
+		"unknown":                    false,
+	} {
+		got, err := store.IsRevoked(jti)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("[%s] wanted=%v, got=%v", jti, want, got)
+		}
+	}
+
+	// Compact, and make sure we can keep writing
+	if err = store.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Revoke("after", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+	store, err = OpenFileRevocationStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked, _ := store.IsRevoked("after"); !revoked {
+		t.Errorf("expected token to be revoked after compaction")
+	}
+	if revoked, _ := store.IsRevoked("live"); !revoked {
+		t.Errorf("expected token to be revoked after compaction")
+	}
+}
@@ -0,0 +1,309 @@
+package jwt
+
+import (
+	"bufio"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// Signer signs digests using a private key that is held by another process,
+// such as a KMS or a signing daemon, so the key never has to be loaded into
+// memory. Unlike crypto.Signer it takes a context, since every call is a
+// round trip that may block or fail. Use NewCryptoSigner to sign tokens
+// with it.
+type Signer interface {
+
+	// PublicKey returns the public half of the key.
+	PublicKey(ctx context.Context) (crypto.PublicKey, error)
+
+	// SignDigest signs the digest, just like crypto.Signer. For Ed25519
+	// keys, the digest is the whole message and opts.HashFunc() is zero.
+	SignDigest(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error)
+}
+
+// cryptoSigner adapts a Signer to a crypto.Signer.
+type cryptoSigner struct {
+	signer Signer
+	public crypto.PublicKey
+}
+
+// NewCryptoSigner returns a crypto.Signer that signs using the Signer, so
+// it can be used as the private key of the RSA, RSA-PSS, ECDSA and EdDSA
+// signing methods (e.g. in a KeyPair). The public key is fetched once, up
+// front. Each signature is made without a deadline, so the Signer should
+// apply its own timeout (SignerClient does).
+func NewCryptoSigner(ctx context.Context, signer Signer) (crypto.Signer, error) {
+	pub, err := signer.PublicKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &cryptoSigner{signer: signer, public: pub}, nil
+}
+
+func (s *cryptoSigner) Public() crypto.PublicKey {
+	return s.public
+}
+
+func (s *cryptoSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.signer.SignDigest(context.Background(), digest, opts)
+}
+
+// maxSignerMessage is the largest message that is read by the SignerClient
+// and ServeSigner. Digests, signatures and public keys are all small.
+const maxSignerMessage = 64 << 10
+
+// signerRequest is a request sent to a signing daemon. Each request and
+// response is a single line of JSON.
+type signerRequest struct {
+	Op         string      `json:"op"` // "public" or "sign"
+	Digest     []byte      `json:"digest,omitempty"`
+	Hash       crypto.Hash `json:"hash,omitempty"`
+	PSS        bool        `json:"pss,omitempty"`
+	SaltLength int         `json:"salt_length,omitempty"`
+}
+
+// signerResponse is the response of a signing daemon.
+type signerResponse struct {
+	Key       *JWK   `json:"key,omitempty"`
+	Signature []byte `json:"signature,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// SignerClient is a Signer backed by a signing daemon listening on a
+// socket, which is served by ServeSigner. A new connection is made for
+// every call, so the client is safe for concurrent use.
+type SignerClient struct {
+	network string
+	address string
+	dialer  net.Dialer
+
+	// Timeout is the maximum amount of time each call may take. If it
+	// is left as zero, 10 seconds will be used.
+	Timeout time.Duration
+}
+
+// NewSignerClient returns a SignerClient for the signing daemon listening
+// at the address, e.g. NewSignerClient("unix", "/run/signer.sock").
+func NewSignerClient(network, address string) *SignerClient {
+	return &SignerClient{
+		network: network,
+		address: address,
+	}
+}
+
+func (c *SignerClient) PublicKey(ctx context.Context) (crypto.PublicKey, error) {
+	res, err := c.call(ctx, &signerRequest{Op: "public"})
+	if err != nil {
+		return nil, err
+	}
+	if res.Key == nil {
+		return nil, errors.Join(ErrRemoteSigner, ErrJWKMalformed)
+	}
+	return res.Key.PublicKey()
+}
+
+func (c *SignerClient) SignDigest(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	req := &signerRequest{
+		Op:     "sign",
+		Digest: digest,
+		Hash:   opts.HashFunc(),
+	}
+	if pss, ok := opts.(*rsa.PSSOptions); ok {
+		req.PSS = true
+		req.SaltLength = pss.SaltLength
+	}
+	res, err := c.call(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res.Signature, nil
+}
+
+// call sends the request to the signing daemon, and reads its response.
+func (c *SignerClient) call(ctx context.Context, req *signerRequest) (*signerResponse, error) {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	conn, err := c.dialer.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return nil, errors.Join(ErrRemoteSigner, err)
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	err = json.NewEncoder(conn).Encode(req)
+	if err != nil {
+		return nil, errors.Join(ErrRemoteSigner, err)
+	}
+	var res signerResponse
+	err = readSignerMessage(bufio.NewReader(conn), &res)
+	if err != nil {
+		return nil, errors.Join(ErrRemoteSigner, err)
+	}
+	if res.Error != "" {
+		return nil, fmt.Errorf("%w: %s", ErrRemoteSigner, res.Error)
+	}
+	return &res, nil
+}
+
+// SignerServerOptions holds the configuration for ServeSigner. Any fields
+// left as their zero value will use the defaults.
+type SignerServerOptions struct {
+
+	// Authorize is called for every new connection before any request is
+	// read from it, and the connection is closed if it returns an error.
+	// It can check the peer credentials of a unix socket, or the client
+	// certificate of a TLS connection. If it is left as nil, every
+	// connection is served.
+	Authorize func(conn net.Conn) error
+
+	// IdleTimeout is how long to wait for the next request on a
+	// connection before closing it. It defaults to 30 seconds.
+	IdleTimeout time.Duration
+}
+
+// ServeSigner serves signing requests for the key on the listener, for use
+// by a SignerClient. It is the daemon side of the SignerClient, and blocks
+// until the listener is closed. The key never leaves the process, only
+// its public half and the signatures are sent back. The options are
+// optional.
+//
+// The protocol has no authentication of its own, so anyone who can connect
+// can have any digest signed. Only serve on a unix socket with permissions
+// that restrict it to the processes that may sign, or set Authorize. Never
+// serve on a TCP listener without Authorize.
+func ServeSigner(l net.Listener, key crypto.Signer, opts *SignerServerOptions) error {
+	if opts == nil {
+		opts = &SignerServerOptions{}
+	}
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go serveSignerConn(conn, key, opts)
+	}
+}
+
+// serveSignerConn handles the requests on a single connection until the
+// client hangs up, or goes quiet for longer than the idle timeout.
+func serveSignerConn(conn net.Conn, key crypto.Signer, opts *SignerServerOptions) {
+	defer conn.Close()
+	// A key that panics on a bad request must not take the daemon down
+	// with it
+	defer func() {
+		recover()
+	}()
+	idle := opts.IdleTimeout
+	if idle == 0 {
+		idle = 30 * time.Second
+	}
+	enc := json.NewEncoder(conn)
+	if opts.Authorize != nil {
+		conn.SetDeadline(time.Now().Add(idle))
+		if err := opts.Authorize(conn); err != nil {
+			enc.Encode(&signerResponse{Error: "not authorized"})
+			return
+		}
+	}
+	r := bufio.NewReader(conn)
+	for {
+		var req signerRequest
+		conn.SetDeadline(time.Now().Add(idle))
+		if err := readSignerMessage(r, &req); err != nil {
+			var nerr net.Error
+			if !errors.Is(err, io.EOF) && !(errors.As(err, &nerr) && nerr.Timeout()) {
+				enc.Encode(&signerResponse{Error: err.Error()})
+			}
+			return
+		}
+		var res signerResponse
+		switch req.Op {
+		case "public":
+			jwk, err := NewJWK(key.Public())
+			if err != nil {
+				res.Error = err.Error()
+				break
+			}
+			res.Key = jwk
+		case "sign":
+			if err := checkSignerRequest(&req); err != nil {
+				res.Error = err.Error()
+				break
+			}
+			var opts crypto.SignerOpts = req.Hash
+			if req.PSS {
+				opts = &rsa.PSSOptions{SaltLength: req.SaltLength, Hash: req.Hash}
+			}
+			sig, err := key.Sign(rand.Reader, req.Digest, opts)
+			if err != nil {
+				res.Error = err.Error()
+				break
+			}
+			res.Signature = sig
+		default:
+			res.Error = fmt.Sprintf("unknown op %q", req.Op)
+		}
+		if err := enc.Encode(&res); err != nil {
+			return
+		}
+	}
+}
+
+// checkSignerRequest checks the hash and digest of a sign request before
+// they are handed to the key, which may panic on a hash it does not know.
+// Ed25519 keys sign the whole message, without a hash.
+func checkSignerRequest(req *signerRequest) error {
+	switch req.Hash {
+	case crypto.Hash(0):
+		if req.PSS {
+			return errors.New("pss requires a hash")
+		}
+		return nil
+	case crypto.SHA256, crypto.SHA384, crypto.SHA512:
+	default:
+		return fmt.Errorf("unsupported hash %d", req.Hash)
+	}
+	if !req.Hash.Available() {
+		return fmt.Errorf("unsupported hash %d", req.Hash)
+	}
+	if len(req.Digest) != req.Hash.Size() {
+		return errors.New("digest does not match the hash size")
+	}
+	return nil
+}
+
+// readSignerMessage reads a single line of JSON into v, refusing to read
+// more than maxSignerMessage bytes.
+func readSignerMessage(r *bufio.Reader, v any) error {
+	var line []byte
+	for {
+		chunk, isPrefix, err := r.ReadLine()
+		if err != nil {
+			return err
+		}
+		line = append(line, chunk...)
+		if len(line) > maxSignerMessage {
+			return errors.New("message too large")
+		}
+		if !isPrefix {
+			break
+		}
+	}
+	return json.Unmarshal(line, v)
+}
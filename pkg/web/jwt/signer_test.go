@@ -0,0 +1,227 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// opaqueSigner hides the concrete type of a private key, so only its
+// crypto.Signer methods can be used, just like with a key in an HSM.
+type opaqueSigner struct {
+	crypto.Signer
+}
+
+func TestSigningMethods_CryptoSigner(t *testing.T) {
+	for _, method := range []SigningMethod{RS256, RS512, PS256, PS384, ES256, ES384, ES512, EdDSA} {
+		t.Run(
+			method.Name(), func(t *testing.T) {
+				keys := method.GenerateKeyPair()
+				signer := opaqueSigner{keys.PrivateKey.(crypto.Signer)}
+				raw, err := NewToken(method, nil, signer)
+				if err != nil {
+					t.Fatalf("error signing token: %v", err)
+				}
+				validator := &Validator{Method: method}
+				if _, err = validator.ValidateToken(raw, keys.PublicKey); err != nil {
+					t.Errorf("error validating token: %v", err)
+				}
+			},
+		)
+	}
+}
+
+func TestSigningMethods_CryptoSignerKeyType(t *testing.T) {
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := opaqueSigner{p384}
+	for _, method := range []SigningMethod{RS256, PS256, ES256, EdDSA} {
+		if _, err = method.Sign([]byte("a.b"), signer); !errors.Is(err, ErrInvalidKeyType) {
+			t.Errorf("%s: expected %v, got %v", method.Name(), ErrInvalidKeyType, err)
+		}
+	}
+	if _, err = ES384.Sign([]byte("a.b"), signer); err != nil {
+		t.Errorf("expected the key to suit ES384, got %v", err)
+	}
+	if _, err = ES384.Sign([]byte("a.b"), "not a key"); !errors.Is(err, ErrInvalidKeyType) {
+		t.Errorf("expected %v, got %v", ErrInvalidKeyType, err)
+	}
+}
+
+// startFakeKMS serves the key on a unix socket, standing in for a separate
+// signing daemon. It returns the socket path and a counter of the number
+// of connections made.
+func startFakeKMS(t *testing.T, key crypto.Signer, opts *SignerServerOptions) (string, *int32) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "kms.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("unix sockets are not available: %v", err)
+	}
+	var conns int32
+	done := make(chan error, 1)
+	go func() {
+		done <- ServeSigner(&countingListener{l, &conns}, key, opts)
+	}()
+	t.Cleanup(
+		func() {
+			l.Close()
+			if err := <-done; err != nil {
+				t.Errorf("error serving signer: %v", err)
+			}
+		},
+	)
+	return path, &conns
+}
+
+type countingListener struct {
+	net.Listener
+	n *int32
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		atomic.AddInt32(l.n, 1)
+	}
+	return conn, err
+}
+
+func TestSignerClient(t *testing.T) {
+	for _, method := range []SigningMethod{RS256, PS512, ES256, EdDSA} {
+		t.Run(
+			method.Name(), func(t *testing.T) {
+				// The private key only exists on the daemon side
+				keys := method.GenerateKeyPair()
+				path, conns := startFakeKMS(t, keys.PrivateKey.(crypto.Signer), nil)
+
+				client := NewSignerClient("unix", path)
+				signer, err := NewCryptoSigner(context.Background(), client)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err = ValidateKeyPair(method, &KeyPair{PrivateKey: signer, PublicKey: keys.PublicKey}); err != nil {
+					t.Fatalf("expected the remote key to match: %v", err)
+				}
+
+				tm := NewTokenManager(method, &KeyPair{PrivateKey: signer, PublicKey: signer.Public()})
+				raw, err := tm.GenerateToken(nil)
+				if err != nil {
+					t.Fatalf("error signing token: %v", err)
+				}
+				if _, err = tm.ValidateToken(raw); err != nil {
+					t.Errorf("error validating token: %v", err)
+				}
+				// One call for the public key, and one for the signature
+				if n := atomic.LoadInt32(conns); n != 2 {
+					t.Errorf("expected 2 calls to the signer, got %d", n)
+				}
+			},
+		)
+	}
+}
+
+func TestSignerClient_Errors(t *testing.T) {
+	keys := ES256.GenerateKeyPair()
+	path, _ := startFakeKMS(t, keys.PrivateKey.(crypto.Signer), nil)
+	client := NewSignerClient("unix", path)
+
+	// Errors of the daemon are passed on (ECDSA keys cannot sign
+	// without a hash)
+	_, err := client.SignDigest(context.Background(), []byte("not a digest"), crypto.Hash(0))
+	if !errors.Is(err, ErrRemoteSigner) {
+		t.Errorf("expected %v, got %v", ErrRemoteSigner, err)
+	}
+	_, err = client.call(context.Background(), &signerRequest{Op: "decrypt"})
+	if !errors.Is(err, ErrRemoteSigner) {
+		t.Errorf("expected %v, got %v", ErrRemoteSigner, err)
+	}
+
+	// An unreachable daemon fails fast
+	client = NewSignerClient("unix", filepath.Join(t.TempDir(), "missing.sock"))
+	client.Timeout = time.Second
+	if _, err = NewCryptoSigner(context.Background(), client); !errors.Is(err, ErrRemoteSigner) {
+		t.Errorf("expected %v, got %v", ErrRemoteSigner, err)
+	}
+}
+
+func TestServeSigner_BadRequests(t *testing.T) {
+	rsaKeys := RS256.GenerateKeyPair()
+	path, _ := startFakeKMS(t, rsaKeys.PrivateKey.(crypto.Signer), nil)
+	client := NewSignerClient("unix", path)
+
+	tests := []struct {
+		name string
+		req  *signerRequest
+	}{
+		{"unknown hash", &signerRequest{Op: "sign", Digest: []byte("AAAA"), Hash: crypto.Hash(999)}},
+		{"unsupported hash", &signerRequest{Op: "sign", Digest: make([]byte, 20), Hash: crypto.SHA1}},
+		{"short digest", &signerRequest{Op: "sign", Digest: make([]byte, 31), Hash: crypto.SHA256}},
+		{"long digest", &signerRequest{Op: "sign", Digest: make([]byte, 64), Hash: crypto.SHA256}},
+		{"pss without hash", &signerRequest{Op: "sign", Digest: make([]byte, 32), PSS: true}},
+	}
+	for _, tt := range tests {
+		_, err := client.call(context.Background(), tt.req)
+		if !errors.Is(err, ErrRemoteSigner) {
+			t.Errorf("[%s] expected %v, got %v", tt.name, ErrRemoteSigner, err)
+		}
+	}
+
+	// The daemon is still serving
+	signer, err := NewCryptoSigner(context.Background(), client)
+	if err != nil {
+		t.Fatalf("expected the daemon to still be serving: %v", err)
+	}
+	if _, err = signer.Sign(nil, digest(crypto.SHA256, []byte("a.b")), crypto.SHA256); err != nil {
+		t.Errorf("error signing: %v", err)
+	}
+}
+
+func TestServeSigner_Options(t *testing.T) {
+	keys := ES256.GenerateKeyPair()
+	var authorized atomic.Bool
+	path, _ := startFakeKMS(
+		t, keys.PrivateKey.(crypto.Signer), &SignerServerOptions{
+			Authorize: func(conn net.Conn) error {
+				if !authorized.Load() {
+					return errors.New("peer is not allowed to sign")
+				}
+				return nil
+			},
+			IdleTimeout: 100 * time.Millisecond,
+		},
+	)
+	client := NewSignerClient("unix", path)
+
+	// Connections that are not authorized are turned away
+	_, err := client.PublicKey(context.Background())
+	if !errors.Is(err, ErrRemoteSigner) {
+		t.Errorf("expected %v, got %v", ErrRemoteSigner, err)
+	}
+	authorized.Store(true)
+	if _, err = client.PublicKey(context.Background()); err != nil {
+		t.Errorf("error getting the public key: %v", err)
+	}
+
+	// Idle connections are closed
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err = conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("expected the idle connection to be closed, got %v", err)
+	}
+}
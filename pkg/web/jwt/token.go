@@ -3,113 +3,223 @@ package jwt
 import (
 	"bytes"
 	"crypto"
+	"encoding/base64"
 	"encoding/json"
+	"sync"
 	"time"
 )
 
+// Section identifies a section of a compact token.
 type Section uint8
 
 const (
-	HeaderSection    Section = 0
-	PayloadSection   Section = 1
-	ClaimsSection    Section = 1
-	SignatureSection Section = 2
-	PartialToken     Section = 3
-	SigningString    Section = 3
-	dot              byte    = '.'
+	HeaderSection Section = iota
+	PayloadSection
+	SignatureSection
+	SigningInputSection
+
+	// ClaimsSection is an alias of PayloadSection.
+	ClaimsSection = PayloadSection
+
+	// PartialToken and SigningString are aliases of SigningInputSection.
+	PartialToken  = SigningInputSection
+	SigningString = SigningInputSection
+
+	dot byte = '.'
 )
 
+var sectionNames = [...]string{
+	HeaderSection:       "header",
+	PayloadSection:      "payload",
+	SignatureSection:    "signature",
+	SigningInputSection: "signing input",
+}
+
+func (s Section) String() string {
+	if int(s) < len(sectionNames) {
+		return sectionNames[s]
+	}
+	return "unknown section"
+}
+
 type TokenHeader struct {
-	Typ string `json:"typ"`
-	Alg string `json:"alg"`
+	Typ  string   `json:"typ,omitempty"`
+	Alg  string   `json:"alg"`
+	Kid  string   `json:"kid,omitempty"`
+	Jwk  *JWK     `json:"jwk,omitempty"`
+	B64  *bool    `json:"b64,omitempty"`
+	Crit []string `json:"crit,omitempty"`
 }
 
 type RawToken []byte
 
 // GetSection returns the start and end index for section
-// containing the header, the payload or the signature.
+// containing the header, the payload or the signature, or
+// the signing input (the header and payload.) If the token
+// is malformed, -1, -1 is returned.
 func (t RawToken) GetSection(section Section) (int, int) {
+	s, err := DefaultParser.split(t)
+	if err != nil {
+		return -1, -1
+	}
 	switch section {
 	case HeaderSection:
-		return 0, bytes.IndexByte(t, dot)
-	case PayloadSection | ClaimsSection:
-		return bytes.IndexByte(t, dot), bytes.LastIndexByte(t, dot)
+		return 0, s.dot1
+	case PayloadSection:
+		return s.dot1 + 1, s.dot2
 	case SignatureSection:
-		return bytes.LastIndexByte(t, dot) + 1, len(t)
-	case PartialToken | SigningString:
-		return 0, bytes.LastIndexByte(t, dot)
+		return s.dot2 + 1, len(t)
+	case SigningInputSection:
+		return 0, s.dot2
 	}
 	return -1, -1
 }
 
+// Header returns the decoded header, or nil if the token is
+// malformed. Only the header is decoded. Use ParseHeader to
+// find out why a token is malformed.
 func (t RawToken) Header() *TokenHeader {
-	var header TokenHeader
-	beg, end := t.GetSection(HeaderSection)
-	b := Base64Decode(t[beg:end])
-	err := json.Unmarshal(b, &header)
+	hdr, err := t.ParseHeader()
 	if err != nil {
 		return nil
 	}
-	return &header
+	return hdr
+}
+
+// ParseHeader returns the decoded header. Only the header is
+// decoded.
+func (t RawToken) ParseHeader() (*TokenHeader, error) {
+	return DefaultParser.ParseHeader(t)
 }
 
+// Claims returns the decoded claims, or nil if the token is
+// malformed. Use ParseClaims to find out why a token is
+// malformed.
 func (t RawToken) Claims() ClaimsSet {
-	var claims ClaimsSet
-	i, j := t.GetSection(ClaimsSection)
-	b := Base64Decode(t[i:j])
-	err := json.Unmarshal(b, &claims)
+	var claims MapClaims
+	err := t.ParseClaims(&claims)
 	if err != nil {
 		return nil
 	}
 	return claims
 }
 
+// ParseClaims decodes the claims into the value pointed to
+// by claims. Only the payload is decoded.
+func (t RawToken) ParseClaims(claims any) error {
+	s, err := DefaultParser.split(t)
+	if err != nil {
+		return err
+	}
+	return decodeSegment(PayloadSection, s.payload(), claims)
+}
+
+// SigningSection returns the signing input (the header and
+// payload) of the token, or nil if the token is malformed.
+// The returned slice shares memory with the token.
 func (t RawToken) SigningSection() []byte {
-	i, j := t.GetSection(SigningString)
-	b := make([]byte, j)
-	copy(b, t[i:j])
-	return b
+	i, j := t.GetSection(SigningInputSection)
+	if i < 0 {
+		return nil
+	}
+	return t[i:j:j]
 }
 
+// Signature returns the encoded signature of the token, or
+// nil if the token is malformed. The returned slice shares
+// memory with the token.
 func (t RawToken) Signature() []byte {
 	i, j := t.GetSection(SignatureSection)
-	b := make([]byte, len(t[i:j]))
-	copy(b, t[i:j])
-	return b
+	if i < 0 {
+		return nil
+	}
+	return t[i:j:j]
 }
 
 func NewToken(alg SigningMethod, claims ClaimsSet, key crypto.PrivateKey) (RawToken, error) {
+	return NewTokenWithHeader(alg, TokenHeader{}, claims, key)
+}
+
+// NewTokenWithHeader works just like NewToken, but it allows the caller
+// to provide additional header fields, such as the key id. The alg field
+// is always set using the provided SigningMethod, and the typ field will
+// default to "JWT" if it is left empty.
+func NewTokenWithHeader(alg SigningMethod, hdr TokenHeader, claims ClaimsSet, key crypto.PrivateKey) (RawToken, error) {
 	// create and encode the header
-	dat, err := json.Marshal(
-		TokenHeader{
-			Typ: "JWT",
-			Alg: alg.Name(),
-		},
-	)
+	hdr.Alg = alg.Name()
+	if hdr.Typ == "" {
+		hdr.Typ = "JWT"
+	}
+	dat, err := json.Marshal(hdr)
 	if err != nil {
 		return nil, err
 	}
-	header := Base64Encode(dat)
-	// create and encode the payload
 	if claims == nil {
-		claims = &RegisteredClaims{
-			ExpirationTime: NumericDate(time.Now().Add(1 * time.Hour).Unix()),
-		}
+		claims = defaultClaims(SystemClock.Now())
+	}
+	return signToken(alg, Base64Encode(dat), claims, key)
+}
+
+// defaultClaims returns the claims of a token that is issued without any,
+// which expires an hour from now.
+func defaultClaims(now time.Time) ClaimsSet {
+	return &RegisteredClaims{
+		ExpirationTime: NumericDate(now.Add(1 * time.Hour).Unix()),
 	}
-	dat, err = json.Marshal(claims)
+}
+
+// tokenPool holds buffers for building the signing input of a token in.
+var tokenPool = sync.Pool{
+	New: func() any {
+		return new(bytes.Buffer)
+	},
+}
+
+// signToken encodes the claims and signs the token with the encoded
+// header. The signing input is built in a pooled buffer, and the token is
+// copied out of it once the signature is known, so the token is allocated
+// only once.
+func signToken(alg SigningMethod, header []byte, claims ClaimsSet, key crypto.PrivateKey) (RawToken, error) {
+	buf := tokenPool.Get().(*bytes.Buffer)
+	defer tokenPool.Put(buf)
+	buf.Reset()
+
+	// encode the payload, and make room for the signing input after it
+	err := json.NewEncoder(buf).Encode(claims)
 	if err != nil {
 		return nil, err
 	}
-	payload := Base64Encode(dat)
-	// create signing string input
-	partialToken := bytes.Join([][]byte{header, payload}, []byte{'.'})
-	// create and encode the signature
-	dat, err = alg.Sign(partialToken, key)
+	payload := bytes.TrimSuffix(buf.Bytes(), []byte{'\n'})
+	n := len(header) + 1 + base64.RawURLEncoding.EncodedLen(len(payload))
+	buf.Grow(n)
+	b := buf.Bytes()
+	payload = b[:len(payload)]
+	partialToken := b[len(b) : len(b)+n : len(b)+n]
+	copy(partialToken, header)
+	partialToken[len(header)] = dot
+	base64.RawURLEncoding.Encode(partialToken[len(header)+1:], payload)
+
+	// create the signature, and build the token
+	if rs, ok := alg.(rawSigner); ok {
+		sig, err := rs.signRaw(partialToken, key)
+		if err != nil {
+			return nil, err
+		}
+		token := make(RawToken, n+1+base64.RawURLEncoding.EncodedLen(len(sig)))
+		copy(token, partialToken)
+		token[n] = dot
+		base64.RawURLEncoding.Encode(token[n+1:], sig)
+		return token, nil
+	}
+	sig, err := alg.Sign(partialToken, key)
 	if err != nil {
 		return nil, err
 	}
-	// create and return token
-	return bytes.Join([][]byte{partialToken, dat}, []byte{'.'}), nil
+	token := make(RawToken, n+1+len(sig))
+	copy(token, partialToken)
+	token[n] = dot
+	copy(token[n+1:], sig)
+	return token, nil
 }
 
 func ValidateToken(rawToken RawToken, method SigningMethod, key crypto.PublicKey) (*Token, error) {
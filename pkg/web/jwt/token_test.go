@@ -2,7 +2,9 @@ package jwt
 
 import (
 	"bytes"
+	"errors"
 	"testing"
+	"time"
 )
 
 var rawToken = RawToken(
@@ -42,3 +44,82 @@ func TestParseRawToken(t *testing.T) {
 		t.Errorf("parsed token does not match valid token")
 	}
 }
+
+func TestParseRawToken_Malformed(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"empty", ""},
+		{"two parts", "eyJhbGciOiJIUzI1NiJ9.e30"},
+		{"bad header base64", "ey!!.e30.sig"},
+		{"bad payload base64", "eyJhbGciOiJIUzI1NiJ9.e3!!.sig"},
+		{"header not json", string(Base64Encode([]byte("nope"))) + ".e30.sig"},
+		{"payload not an object", "eyJhbGciOiJIUzI1NiJ9." + string(Base64Encode([]byte("[]"))) + ".sig"},
+		{"alg none", string(Base64Encode([]byte(`{"alg":"none"}`))) + ".e30."},
+		{"unknown alg", string(Base64Encode([]byte(`{"alg":"XX999"}`))) + ".e30.sig"},
+	}
+	for _, tt := range tests {
+		_, err := ParseRawToken(RawToken(tt.raw))
+		if !errors.Is(err, ErrTokenMalformed) {
+			t.Errorf("[%s] expected %v, got %v", tt.name, ErrTokenMalformed, err)
+		}
+	}
+}
+
+// FuzzParseRawToken makes sure that no input can make the parser, or the
+// validator behind it, panic.
+func FuzzParseRawToken(f *testing.F) {
+	f.Add([]byte(rawToken))
+	f.Add([]byte("eyJhbGciOiJIUzI1NiJ9.e30.sig"))
+	f.Add([]byte("eyJhbGciOiJub25lIn0.e30."))
+	f.Add([]byte("eyJhbGciOiJFUzI1NiJ9.eyJleHAiOiJzb29uIiwiaXNzIjoxfQ.!!"))
+	f.Add([]byte("a.b.c.d.e"))
+	f.Add([]byte(".."))
+	v := &Validator{
+		Algorithms:  []string{"HS256", "ES256", "RS256", "EdDSA"},
+		ValidateIAT: true,
+		ExpectedISS: "me",
+		ExpectedAUD: "you",
+		MaxAge:      time.Hour,
+	}
+	f.Fuzz(
+		func(t *testing.T, raw []byte) {
+			tok, err := ParseRawToken(raw)
+			if err != nil {
+				return
+			}
+			tok.Payload.GetISS()
+			tok.Payload.GetSUB()
+			tok.Payload.GetAUD()
+			tok.Payload.GetEXP()
+			tok.Payload.GetJTI()
+			_, err = v.ValidateToken(raw, hmacTestKey)
+			if err == nil {
+				t.Errorf("fuzzed token was accepted: %q", raw)
+			}
+		},
+	)
+}
+
+// encodedSigner hides the signRaw method of the signing method, so tokens
+// are signed through Sign, just like with a user registered method.
+type encodedSigner struct {
+	SigningMethod
+}
+
+func TestNewToken_SigningPaths(t *testing.T) {
+	claims := MapClaims{"sub": "1234567890", "name": "John Doe", "exp": NumericDateNow().Add(time.Hour)}
+	raw, err := NewToken(HS256, claims, hmacTestKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := NewToken(encodedSigner{HS256}, claims, hmacTestKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, string(raw), string(encoded))
+	if _, err = ValidateToken(raw, HS256, hmacTestKey); err != nil {
+		t.Errorf("error validating token: %v", err)
+	}
+}
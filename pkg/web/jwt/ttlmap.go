@@ -0,0 +1,126 @@
+package jwt
+
+import (
+	"sync"
+	"time"
+)
+
+// timeoutEntry is a timeoutMap entry.
+type timeoutEntry[V any] struct {
+	data    V
+	expires time.Time // zero if the entry never expires
+}
+
+func (e *timeoutEntry[V]) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// timeoutMap is a map that automatically collects and removes expired
+// entries at a specified interval. It follows the same approach as the
+// TimeoutMap in the random package, but it expires entries at absolute
+// times, which lines up with the time based claims of a token.
+type timeoutMap[K comparable, V any] struct {
+	mu  sync.Mutex
+	m   map[K]*timeoutEntry[V]
+	now func() time.Time
+
+	ticker     *time.Ticker
+	tickerStop chan bool
+	stopOnce   sync.Once
+}
+
+// newTimeoutMap initializes and returns a new timeoutMap instance setup
+// to clean at the interval supplied. Entries are expired according to the
+// time returned by now.
+func newTimeoutMap[K comparable, V any](interval time.Duration, now func() time.Time) *timeoutMap[K, V] {
+	tm := &timeoutMap[K, V]{
+		m:          make(map[K]*timeoutEntry[V]),
+		now:        now,
+		ticker:     time.NewTicker(interval),
+		tickerStop: make(chan bool),
+	}
+	// spawn the cleaner
+	go func() {
+		for {
+			select {
+			case <-tm.ticker.C: // a new tick
+				tm.clean()
+			case <-tm.tickerStop: // stopping the ticker
+				return
+			}
+		}
+	}()
+	return tm
+}
+
+// clean iterates through the map and removes expired entries.
+func (tm *timeoutMap[K, V]) clean() {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	now := tm.now()
+	for k, e := range tm.m {
+		if e.expired(now) {
+			delete(tm.m, k)
+		}
+	}
+}
+
+// stop stops the cleaner. It is safe to call more than once.
+func (tm *timeoutMap[K, V]) stop() {
+	tm.stopOnce.Do(
+		func() {
+			tm.ticker.Stop()
+			close(tm.tickerStop)
+		},
+	)
+}
+
+// put writes the key and value to the map overwriting any existing
+// entry. The entry expires at the provided time, or never if the time
+// is the zero time.
+func (tm *timeoutMap[K, V]) put(k K, v V, expires time.Time) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.m[k] = &timeoutEntry[V]{data: v, expires: expires}
+}
+
+// putIfAbsent writes the key and value to the map, unless an entry that
+// has not expired yet already exists. It reports whether the entry was
+// written.
+func (tm *timeoutMap[K, V]) putIfAbsent(k K, v V, expires time.Time) bool {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if e, found := tm.m[k]; found && !e.expired(tm.now()) {
+		return false
+	}
+	tm.m[k] = &timeoutEntry[V]{data: v, expires: expires}
+	return true
+}
+
+// get returns the value and a found boolean for the key. Expired entries
+// that have not been cleaned up yet are never returned.
+func (tm *timeoutMap[K, V]) get(k K) (V, bool) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	e, found := tm.m[k]
+	if !found || e.expired(tm.now()) {
+		var zero V
+		return zero, false
+	}
+	return e.data, true
+}
+
+// del removes the entry with the matching key.
+func (tm *timeoutMap[K, V]) del(k K) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	delete(tm.m, k)
+}
+
+// len returns the number of entries in the map, including expired
+// entries that have not been cleaned up yet.
+func (tm *timeoutMap[K, V]) len() int {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	return len(tm.m)
+}
@@ -0,0 +1,109 @@
+package jwt
+
+import (
+	"crypto"
+	"reflect"
+)
+
+// TypedToken is just like Token, except that the payload is decoded into
+// a user provided claims type instead of MapClaims. This gives typed
+// access to custom claims without any map assertions.
+type TypedToken[T ClaimsSet] struct {
+	RawToken
+	Header    TokenHeader
+	Claims    T
+	Method    SigningMethod
+	Signature []byte
+	Valid     bool
+}
+
+// ParseUnverifiedWithClaims parses the raw token and decodes the payload
+// into a new instance of T. The token is not validated. T is usually a
+// pointer to a struct that embeds RegisteredClaims.
+func ParseUnverifiedWithClaims[T ClaimsSet](raw RawToken) (*TypedToken[T], error) {
+	claims := newClaims[T]()
+	token, err := parseRawToken(raw, claims)
+	if err != nil {
+		return nil, err
+	}
+	return &TypedToken[T]{
+		RawToken:  token.RawToken,
+		Header:    token.Header,
+		Claims:    derefClaims[T](claims),
+		Method:    token.Method,
+		Signature: token.Signature,
+	}, nil
+}
+
+// ParseWithClaims parses the raw token, decodes the payload into a new
+// instance of T, and validates it using the provided validator and key.
+// The registered claims are checked, and if T implements CustomClaimsSet
+// its Validate method is called as well.
+func ParseWithClaims[T ClaimsSet](raw RawToken, v *Validator, key crypto.PublicKey) (*TypedToken[T], error) {
+	typed, err := ParseUnverifiedWithClaims[T](raw)
+	if err != nil {
+		return nil, err
+	}
+	err = v.validate(typed.token(), typed.Claims, key)
+	if err != nil {
+		return nil, err
+	}
+	typed.Valid = true
+	return typed, nil
+}
+
+// ValidateTokenWithClaims works just like TokenManager.ValidateToken, but
+// it decodes the payload into a new instance of T, just like
+// ParseWithClaims.
+func ValidateTokenWithClaims[T ClaimsSet](m *TokenManager, raw RawToken) (*TypedToken[T], error) {
+	claims := newClaims[T]()
+	token, err := m.parser().parse(raw, claims)
+	if err != nil {
+		return nil, err
+	}
+	typed := derefClaims[T](claims)
+	token, err = m.validateToken(token, typed)
+	if err != nil {
+		return nil, err
+	}
+	return &TypedToken[T]{
+		RawToken:  token.RawToken,
+		Header:    token.Header,
+		Claims:    typed,
+		Method:    token.Method,
+		Signature: token.Signature,
+		Valid:     token.Valid,
+	}, nil
+}
+
+// token returns a Token holding everything from the typed token except
+// for the claims.
+func (t *TypedToken[T]) token() *Token {
+	return &Token{
+		RawToken:  t.RawToken,
+		Header:    t.Header,
+		Method:    t.Method,
+		Signature: t.Signature,
+		Valid:     t.Valid,
+	}
+}
+
+// newClaims returns a pointer that the payload can be decoded into. If T
+// is a pointer type, a new instance of the type it points to is returned,
+// otherwise a pointer to a zero T is returned.
+func newClaims[T ClaimsSet]() any {
+	var zero T
+	typ := reflect.TypeOf(&zero).Elem()
+	if typ.Kind() == reflect.Pointer {
+		return reflect.New(typ.Elem()).Interface()
+	}
+	return &zero
+}
+
+// derefClaims converts the value returned by newClaims back into a T.
+func derefClaims[T ClaimsSet](claims any) T {
+	if t, ok := claims.(T); ok {
+		return t
+	}
+	return *claims.(*T)
+}
@@ -0,0 +1,142 @@
+package jwt
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type UserClaims struct {
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+	RegisteredClaims
+}
+
+func TestParseWithClaims(t *testing.T) {
+	keys := HS256.GenerateKeyPair()
+	raw, err := NewToken(
+		HS256, &UserClaims{
+			Name:  "jon doe",
+			Roles: []string{"admin"},
+			RegisteredClaims: RegisteredClaims{
+				Subject:        "1234",
+				ExpirationTime: NumericDateNow().Add(time.Hour),
+			},
+		}, keys.PrivateKey,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validator := &Validator{Method: HS256, ExpectedSUB: "1234"}
+	tok, err := ParseWithClaims[*UserClaims](raw, validator, keys.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tok.Valid {
+		t.Errorf("expected token to be valid")
+	}
+	assert(t, "jon doe", tok.Claims.Name)
+	assert(t, "admin", tok.Claims.Roles[0])
+	assert(t, "1234", tok.Claims.Subject)
+
+	// Non pointer claims types should work too
+	m, err := ParseWithClaims[MapClaims](raw, validator, keys.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, "jon doe", m.Claims["name"])
+
+	// A bad signature should still be caught
+	_, err = ParseWithClaims[*UserClaims](raw, validator, []byte("wrong key"))
+	if !errors.Is(err, ErrTokenSignatureInvalid) {
+		t.Errorf("expected %v, got %v", ErrTokenSignatureInvalid, err)
+	}
+}
+
+func TestParseWithClaims_CustomValidation(t *testing.T) {
+	keys := ES256.GenerateKeyPair()
+	tm := NewTokenManager(ES256, keys)
+
+	// The custom Validate method should be reached
+	raw, err := tm.GenerateToken(
+		&MyCustomClaims{
+			RegisteredClaims: &RegisteredClaims{
+				ExpirationTime: NumericDateNow().Add(time.Hour),
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ValidateTokenWithClaims[*MyCustomClaims](tm, raw)
+	if !errors.Is(err, ErrTokenInvalidCustomClaims) {
+		t.Errorf("expected %v, got %v", ErrTokenInvalidCustomClaims, err)
+	}
+
+	raw, err = tm.GenerateToken(
+		&MyCustomClaims{
+			SecretField: "abc123",
+			RegisteredClaims: &RegisteredClaims{
+				ExpirationTime: NumericDateNow().Add(time.Hour),
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err := ValidateTokenWithClaims[*MyCustomClaims](tm, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, "abc123", tok.Claims.SecretField)
+}
+
+func TestValidateTokenWithClaims_Manager(t *testing.T) {
+	tm := newRefreshTestManager(t)
+	raw, err := tm.GenerateToken(
+		&UserClaims{
+			Name: "jon doe",
+			RegisteredClaims: RegisteredClaims{
+				Subject:        "1234",
+				ID:             "typed-1",
+				ExpirationTime: NumericDateNow().Add(time.Hour),
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err := ValidateTokenWithClaims[*UserClaims](tm, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, true, tok.Valid)
+	assert(t, "jon doe", tok.Claims.Name)
+
+	// Typed tokens go through the same checks as ValidateToken, such as
+	// the parser limits and revocation
+	tm.Parser = &Parser{MaxTokenSize: 32}
+	if _, err = ValidateTokenWithClaims[*UserClaims](tm, raw); !errors.Is(err, ErrTokenTooLarge) {
+		t.Errorf("expected %v, got %v", ErrTokenTooLarge, err)
+	}
+	tm.Parser = nil
+	if err = tm.Revoke(raw); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ValidateTokenWithClaims[*UserClaims](tm, raw); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("expected %v, got %v", ErrTokenRevoked, err)
+	}
+}
+
+func TestRawToken_Claims(t *testing.T) {
+	claims := rawToken.Claims()
+	if claims == nil {
+		t.Fatal("expected claims to be decoded")
+	}
+	sub, err := claims.GetSUB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, "1234567890", sub)
+}
@@ -0,0 +1,168 @@
+package jwt
+
+import (
+	"strings"
+)
+
+// ValidationCheck identifies one of the checks performed by the Validator.
+// Checks can be combined into a bitmask.
+type ValidationCheck uint32
+
+const (
+	CheckAlgorithm ValidationCheck = 1 << iota
+	CheckSignature
+	CheckExpiresAt
+	CheckNotBefore
+	CheckIssuedAt
+	CheckAudience
+	CheckIssuer
+	CheckSubject
+	CheckRevocation
+	CheckCustomClaims
+	CheckMaxAge
+	CheckRequiredClaims
+	CheckKeyType
+	CheckCritical
+)
+
+var checkNames = []string{
+	"alg",
+	"signature",
+	"exp",
+	"nbf",
+	"iat",
+	"aud",
+	"iss",
+	"sub",
+	"revoked",
+	"custom",
+	"max_age",
+	"required",
+	"key_type",
+	"crit",
+}
+
+// String returns the machine-readable name of the check, or the names of
+// all the checks in the bitmask separated by a '|'.
+func (c ValidationCheck) String() string {
+	var names []string
+	for i, name := range checkNames {
+		if c&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+func (c ValidationCheck) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// ValidationFailure holds the details of a single failed check.
+type ValidationFailure struct {
+
+	// Check is the check that failed.
+	Check ValidationCheck `json:"check"`
+
+	// Expected and Actual hold the expected and actual values, when it
+	// makes sense for the check. For time based checks they hold the
+	// bound the current time was compared with, and the current time.
+	Expected any `json:"expected,omitempty"`
+	Actual   any `json:"actual,omitempty"`
+
+	// Detail is a short, human-readable description of the failure.
+	Detail string `json:"detail,omitempty"`
+
+	// Err is the underlying error, which always matches one of the
+	// sentinel errors (e.g. ErrTokenExpired) using errors.Is.
+	Err error `json:"-"`
+}
+
+func (f *ValidationFailure) Error() string {
+	if f.Detail == "" {
+		return f.Err.Error()
+	}
+	return f.Err.Error() + ": " + f.Detail
+}
+
+func (f *ValidationFailure) Unwrap() error {
+	return f.Err
+}
+
+// ValidationError is returned by the Validator when a token fails one or
+// more checks. Every failed check is reported, so callers can tell exactly
+// why a token was rejected. It supports errors.Is on the sentinel errors
+// of each of the failed checks, and on ErrTokenClaimsInvalid if any of the
+// claims checks failed.
+type ValidationError struct {
+
+	// Checks is a bitmask of all the failed checks.
+	Checks ValidationCheck `json:"checks"`
+
+	// Failures holds the details of each of the failed checks, in the
+	// order the checks were performed.
+	Failures []*ValidationFailure `json:"failures"`
+}
+
+// claimsChecks are the checks that are performed on the claims, as opposed
+// to on the header and signature.
+const claimsChecks = CheckExpiresAt | CheckNotBefore | CheckIssuedAt | CheckAudience |
+	CheckIssuer | CheckSubject | CheckRevocation | CheckCustomClaims | CheckMaxAge |
+	CheckRequiredClaims
+
+// add records a failed check.
+func (e *ValidationError) add(f *ValidationFailure) {
+	e.Checks |= f.Check
+	e.Failures = append(e.Failures, f)
+}
+
+// merge adds all the failures of the provided error.
+func (e *ValidationError) merge(other *ValidationError) {
+	for _, f := range other.Failures {
+		e.add(f)
+	}
+}
+
+// err returns the ValidationError as an error, or nil if no checks failed.
+func (e *ValidationError) err() error {
+	if len(e.Failures) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Has reports whether the check (or any of the checks in the bitmask)
+// failed.
+func (e *ValidationError) Has(c ValidationCheck) bool {
+	return e.Checks&c != 0
+}
+
+// Failure returns the details of the failed check, or nil if the check did
+// not fail.
+func (e *ValidationError) Failure(c ValidationCheck) *ValidationFailure {
+	for _, f := range e.Failures {
+		if f.Check == c {
+			return f
+		}
+	}
+	return nil
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Failures))
+	for _, f := range e.Failures {
+		msgs = append(msgs, f.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failures)+1)
+	for _, f := range e.Failures {
+		errs = append(errs, f)
+	}
+	if e.Has(claimsChecks) {
+		errs = append(errs, ErrTokenClaimsInvalid)
+	}
+	return errs
+}
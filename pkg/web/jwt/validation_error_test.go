@@ -0,0 +1,110 @@
+package jwt
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidationError(t *testing.T) {
+	raw, err := NewToken(
+		HS256, &RegisteredClaims{
+			Issuer:         "someone-else",
+			Audience:       Audience{"billing"},
+			ExpirationTime: NumericDateNow().Add(-5 * time.Minute),
+		}, hmacTestKey,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &Validator{
+		Method:      HS256,
+		ExpectedISS: "auth.example.com",
+		ExpectedAUD: "orders",
+	}
+	_, err = v.ValidateToken(raw, []byte("not the right key"))
+	if err == nil {
+		t.Fatal("expected the token to be rejected")
+	}
+
+	// Every failed check must be reported
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a *ValidationError, got %T", err)
+	}
+	want := CheckExpiresAt | CheckAudience | CheckIssuer | CheckSignature
+	assert(t, want, verr.Checks)
+	assert(t, 4, len(verr.Failures))
+	assert(t, "signature|exp|aud|iss", verr.Checks.String())
+	assert(t, false, verr.Has(CheckNotBefore))
+
+	// The existing sentinel errors must still match
+	for _, sentinel := range []error{
+		ErrTokenExpired,
+		ErrTokenInvalidAudience,
+		ErrTokenInvalidIssuer,
+		ErrTokenSignatureInvalid,
+		ErrTokenClaimsInvalid,
+	} {
+		if !errors.Is(err, sentinel) {
+			t.Errorf("expected errors.Is(err, %v)", sentinel)
+		}
+	}
+	if errors.Is(err, ErrTokenNotValidYet) {
+		t.Errorf("did not expect errors.Is(err, %v)", ErrTokenNotValidYet)
+	}
+
+	// Along with the details of each check
+	exp := verr.Failure(CheckExpiresAt)
+	if exp == nil || !strings.HasPrefix(exp.Detail, "expired 5m") {
+		t.Errorf("unexpected exp failure: %+v", exp)
+	}
+	iss := verr.Failure(CheckIssuer)
+	assert(t, "auth.example.com", iss.Expected)
+	assert(t, "someone-else", iss.Actual)
+
+	// And it must be possible to hand them to clients as-is
+	dat, err := json.Marshal(verr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Checks   string `json:"checks"`
+		Failures []struct {
+			Check    string `json:"check"`
+			Expected any    `json:"expected"`
+			Actual   any    `json:"actual"`
+		} `json:"failures"`
+	}
+	err = json.Unmarshal(dat, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, "signature|exp|aud|iss", decoded.Checks)
+	assert(t, "exp", decoded.Failures[0].Check)
+	assert(t, "someone-else", decoded.Failures[2].Actual)
+}
+
+func TestValidationError_Algorithm(t *testing.T) {
+	raw, err := NewToken(HS384, &RegisteredClaims{}, hmacTestKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &Validator{Method: HS256}
+	_, err = v.ValidateToken(raw, hmacTestKey)
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a *ValidationError, got %T", err)
+	}
+	// The claims are not checked when the algorithm is wrong
+	assert(t, CheckAlgorithm, verr.Checks)
+	assert(t, "HS384", verr.Failure(CheckAlgorithm).Actual)
+	if !errors.Is(err, ErrTokenUnverifiable) {
+		t.Errorf("expected %v, got %v", ErrTokenUnverifiable, err)
+	}
+	if errors.Is(err, ErrTokenClaimsInvalid) {
+		t.Errorf("did not expect %v", ErrTokenClaimsInvalid)
+	}
+}
@@ -3,7 +3,9 @@ package jwt
 import (
 	"bytes"
 	"crypto"
+	"encoding/json"
 	"errors"
+	"strings"
 	"time"
 )
 
@@ -24,6 +26,15 @@ type Validator struct {
 	// be disabled.
 	ExpectedAUD string
 
+	// ExpectedAUDs holds a set of audiences this token expects,
+	// in addition to ExpectedAUD. By default, the token must
+	// contain at least one of the expected audiences.
+	ExpectedAUDs []string
+
+	// RequireAllAUDs specifies whether the token must contain
+	// every one of the expected audiences, instead of just one.
+	RequireAllAUDs bool
+
 	// ExpectedISS holds the issuer this token expects; if
 	// it is left as an empty string, issuer validation will
 	// be disabled.
@@ -34,44 +45,87 @@ type Validator struct {
 	// be disabled.
 	ExpectedSUB string
 
+	// RevocationStore is an optional store of revoked tokens. If
+	// it is set, tokens with a revoked jti claim are rejected.
+	RevocationStore RevocationStore
+
+	// Clock is the source of the current time; if it is left
+	// as nil, SystemClock will be used.
+	Clock Clock
+
+	// ExpLeeway, NbfLeeway and IatLeeway are the time margins
+	// applied to the exp, nbf and iat claims respectively. Any
+	// of them that are left as zero will use Margin instead.
+	ExpLeeway time.Duration
+	NbfLeeway time.Duration
+	IatLeeway time.Duration
+
+	// MaxAge is the maximum age of a token, measured from its
+	// issued at time claim; if it is non-zero, tokens without
+	// an iat claim will be rejected.
+	MaxAge time.Duration
+
+	// RequiredClaims holds the names of any claims that must be
+	// present in the token. The exp claim is always required.
+	RequiredClaims []string
+
+	// Algorithms holds the names of the signing methods that are
+	// allowed. If it is left empty, only Method is allowed. If
+	// both are set, the token must satisfy both. The algorithm
+	// in the token header is never trusted on its own.
+	Algorithms []string
+
+	// CriticalHeaders holds the names of any critical (crit)
+	// header parameters the caller understands. Tokens marking
+	// any other header parameter as critical are rejected.
+	CriticalHeaders []string
+
+	// Parser is used to parse the raw tokens; if it is left as
+	// nil, DefaultParser will be used.
+	Parser *Parser
+
+	// Method is the signing method tokens are expected to use.
 	Method SigningMethod
 }
 
-func (v *Validator) ValidateToken(raw RawToken, key crypto.PublicKey) (*Token, error) {
+// KeyFunc is used by ValidateTokenWithKeyFunc to look up the key that
+// should be used to verify a token. It is called with the parsed, but
+// not yet verified token, so it can inspect the header (e.g. the kid).
+type KeyFunc func(token *Token) (crypto.PublicKey, error)
 
-	// Create error type
-	var verr error
+func (v *Validator) ValidateToken(raw RawToken, key crypto.PublicKey) (*Token, error) {
 
 	// Parse the initial raw rawToken
-	token, err := ParseRawToken(raw)
+	token, err := v.parser().Parse(raw)
 	if err != nil {
 		return nil, err
 	}
+	return v.validateToken(token, key)
+}
 
-	// Verify the signature method matches the provided
-	// SigningMethod
-	if token.Header.Alg != v.Method.Name() {
-		verr = errors.Join(ErrTokenUnverifiable, err)
-		return nil, verr
-	}
+// ValidateTokenWithKeyFunc works just like ValidateToken, but it uses the
+// provided KeyFunc to look up the verification key.
+func (v *Validator) ValidateTokenWithKeyFunc(raw RawToken, keyFunc KeyFunc) (*Token, error) {
 
-	// ValidateRawToken the claims
-	err = v.ValidateClaims(token.Payload)
+	// Parse the initial raw rawToken
+	token, err := v.parser().Parse(raw)
 	if err != nil {
-		verr = errors.Join(err, ErrTokenClaimsInvalid)
-		// We should continue on to validating the signature
+		return nil, err
 	}
 
-	// ValidateRawToken the final "validation" on the signature
-	partialToken := raw[:bytes.LastIndexByte(raw, '.')]
-	err = token.Method.Verify(partialToken, token.Signature, key)
+	// Look up the verification key
+	key, err := keyFunc(token)
 	if err != nil {
-		verr = errors.Join(err, ErrTokenSignatureInvalid)
-		// continue
+		return nil, errors.Join(ErrTokenUnverifiable, err)
 	}
+	return v.validateToken(token, key)
+}
 
-	if verr != nil {
-		return nil, verr
+// validateToken validates a token that has already been parsed.
+func (v *Validator) validateToken(token *Token, key crypto.PublicKey) (*Token, error) {
+	err := v.validate(token, token.Payload, key)
+	if err != nil {
+		return nil, err
 	}
 
 	// We have a valid rawToken, return it!
@@ -79,42 +133,123 @@ func (v *Validator) ValidateToken(raw RawToken, key crypto.PublicKey) (*Token, e
 	return token, nil
 }
 
+// validate checks the signing method, the claims and the signature of a
+// parsed token. The claims are passed in separately, so that tokens with
+// a payload other than MapClaims can be validated as well. Any error
+// returned is a *ValidationError.
+func (v *Validator) validate(token *Token, claims ClaimsSet, key crypto.PublicKey) error {
+
+	// Create error type
+	verr := new(ValidationError)
+
+	// Verify the signature method is one we allow, that the key
+	// belongs to the same algorithm family, and that we understand
+	// every critical header. None of these can be trusted to the
+	// token itself, so we stop right away if any of them fail.
+	if f := v.checkAlgorithm(token.Header.Alg); f != nil {
+		verr.add(f)
+		return verr
+	}
+	if err := checkKeyType(token.Method, key); err != nil {
+		verr.add(
+			&ValidationFailure{
+				Check:  CheckKeyType,
+				Actual: token.Header.Alg,
+				Detail: "key does not match the algorithm",
+				Err:    errors.Join(ErrTokenUnverifiable, err),
+			},
+		)
+		return verr
+	}
+	if f := v.checkCritical(&token.Header); f != nil {
+		verr.add(f)
+		return verr
+	}
+
+	// ValidateRawToken the claims
+	if cerr := v.validateClaims(claims); cerr != nil {
+		verr.merge(cerr)
+		// We should continue on to validating the signature
+	}
+
+	// ValidateRawToken the final "validation" on the signature
+	partialToken := token.RawToken[:bytes.LastIndexByte(token.RawToken, '.')]
+	err := token.Method.Verify(partialToken, token.Signature, key)
+	if err != nil {
+		verr.add(
+			&ValidationFailure{
+				Check: CheckSignature,
+				Err:   errors.Join(err, ErrTokenSignatureInvalid),
+			},
+		)
+		// continue
+	}
+
+	return verr.err()
+}
+
+// ValidateClaims validates the claims, without looking at the signature.
+// Any error returned is a *ValidationError reporting every failed check.
 func (v *Validator) ValidateClaims(claims ClaimsSet) error {
+	if verr := v.validateClaims(claims); verr != nil {
+		return verr
+	}
+	return nil
+}
+
+// validateClaims runs each of the claims checks, and returns nil if all of
+// them passed.
+func (v *Validator) validateClaims(claims ClaimsSet) *ValidationError {
 
 	// Create a new error
-	var verr error
+	verr := new(ValidationError)
 
 	// Get the current time
-	now := time.Now()
+	now := v.now()
 
 	// ValidateRawToken expiration time claim (exp)
-	if !v.checkExpiresAtClaim(claims.GetEXP, now) {
-		verr = errors.Join(verr, ErrTokenExpired)
+	if f := v.checkExpiresAtClaim(claims.GetEXP, now); f != nil {
+		verr.add(f)
 	}
 
 	// ValidateRawToken not before time claim (nbf)
-	if !v.checkNotBeforeClaim(claims.GetNBF, now) {
-		verr = errors.Join(verr, ErrTokenNotValidYet)
+	if f := v.checkNotBeforeClaim(claims.GetNBF, now); f != nil {
+		verr.add(f)
 	}
 
 	// ValidateRawToken issued at time claim (iat)
-	if !v.checkIssuedAtClaim(claims.GetIAT, now) {
-		verr = errors.Join(verr, ErrTokenUsedBeforeIssued)
+	if f := v.checkIssuedAtClaim(claims.GetIAT, now); f != nil {
+		verr.add(f)
+	}
+
+	// ValidateRawToken the token is not too old (iat)
+	if f := v.checkMaxAge(claims.GetIAT, now); f != nil {
+		verr.add(f)
+	}
+
+	// ValidateRawToken the required claims are present
+	if f := v.checkRequiredClaims(claims); f != nil {
+		verr.add(f)
 	}
 
 	// ValidateRawToken audience claim (aud)
-	if !v.checkAudienceClaim(claims.GetAUD()) {
-		verr = errors.Join(verr, ErrTokenInvalidAudience)
+	if f := v.checkAudienceClaim(claims.GetAUD()); f != nil {
+		verr.add(f)
 	}
 
 	// ValidateRawToken issuer claim (iss)
-	if !v.checkIssuerClaim(claims.GetISS()) {
-		verr = errors.Join(verr, ErrTokenInvalidIssuer)
+	if f := v.checkIssuerClaim(claims.GetISS()); f != nil {
+		verr.add(f)
 	}
 
 	// ValidateRawToken subject claim (sub)
-	if !v.checkSubjectClaim(claims.GetSUB()) {
-		verr = errors.Join(verr, ErrTokenInvalidSubject)
+	if f := v.checkSubjectClaim(claims.GetSUB()); f != nil {
+		verr.add(f)
+	}
+
+	// ValidateRawToken the token has not been revoked (jti)
+	if f := v.checkRevocation(claims); f != nil {
+		verr.add(f)
 	}
 
 	// ValidateRawToken any custom claims set that the
@@ -122,71 +257,324 @@ func (v *Validator) ValidateClaims(claims ClaimsSet) error {
 	if custom, ok := claims.(CustomClaimsSet); ok {
 		if err := custom.Validate(); err != nil {
 			if err != SkipValidation {
-				verr = errors.Join(verr, ErrTokenInvalidCustomClaims)
-				verr = errors.Join(verr, err)
+				verr.add(
+					&ValidationFailure{
+						Check:  CheckCustomClaims,
+						Detail: err.Error(),
+						Err:    errors.Join(ErrTokenInvalidCustomClaims, err),
+					},
+				)
 			}
 		}
 	}
 
+	if len(verr.Failures) == 0 {
+		return nil
+	}
 	return verr
 }
 
-func (v *Validator) checkIssuerClaim(iss string, err error) bool {
-	// If expected is false or empty, skip (return true)
+func (v *Validator) checkAlgorithm(alg string) *ValidationFailure {
+	allowed := v.Algorithms
+	if len(allowed) == 0 && v.Method != nil {
+		allowed = []string{v.Method.Name()}
+	}
+	f := &ValidationFailure{
+		Check:    CheckAlgorithm,
+		Expected: allowed,
+		Actual:   alg,
+		Err:      ErrTokenUnverifiable,
+	}
+	if v.Method != nil && alg != v.Method.Name() {
+		return f
+	}
+	if !containsString(allowed, alg) {
+		return f
+	}
+	return nil
+}
+
+// registeredHeaders are the header parameters defined by RFC 7515 and RFC
+// 7519, which must not be listed as critical.
+var registeredHeaders = []string{
+	"alg", "jku", "jwk", "kid", "x5u", "x5c", "x5t", "x5t#S256", "typ", "cty", "crit",
+}
+
+func (v *Validator) checkCritical(hdr *TokenHeader) *ValidationFailure {
+	// The b64 header parameter is not allowed in a JWT (RFC 7797, section 7)
+	if hdr.B64 != nil {
+		return &ValidationFailure{
+			Check:  CheckCritical,
+			Actual: "b64",
+			Detail: "b64 is not allowed in a JWT",
+			Err:    errors.Join(ErrTokenUnverifiable, ErrJWSUnsupportedCrit),
+		}
+	}
+	if hdr.Crit == nil {
+		return nil
+	}
+	if len(hdr.Crit) == 0 {
+		return &ValidationFailure{
+			Check:  CheckCritical,
+			Detail: "crit must not be empty",
+			Err:    errors.Join(ErrTokenUnverifiable, ErrJWSUnsupportedCrit),
+		}
+	}
+	for _, name := range hdr.Crit {
+		if containsString(registeredHeaders, name) || !containsString(v.CriticalHeaders, name) {
+			return &ValidationFailure{
+				Check:    CheckCritical,
+				Expected: v.CriticalHeaders,
+				Actual:   name,
+				Err:      errors.Join(ErrTokenUnverifiable, ErrJWSUnsupportedCrit),
+			}
+		}
+	}
+	return nil
+}
+
+// parser returns the Parser of the validator.
+func (v *Validator) parser() *Parser {
+	if v.Parser == nil {
+		return DefaultParser
+	}
+	return v.Parser
+}
+
+// now returns the current time according to the clock of the validator.
+func (v *Validator) now() time.Time {
+	return clockNow(v.Clock)
+}
+
+// leeway returns the per claim leeway if it is set, and Margin otherwise.
+func (v *Validator) leeway(d time.Duration) time.Duration {
+	if d != 0 {
+		return d
+	}
+	return v.Margin
+}
+
+func (v *Validator) checkIssuerClaim(iss string, err error) *ValidationFailure {
+	// If expected is false or empty, skip (return nil)
 	if v.ExpectedISS == "" {
-		return true
+		return nil
 	}
-	if err != nil && err != SkipValidation {
-		return false
+	if (err != nil && err != SkipValidation) || iss != v.ExpectedISS {
+		return &ValidationFailure{
+			Check:    CheckIssuer,
+			Expected: v.ExpectedISS,
+			Actual:   iss,
+			Err:      ErrTokenInvalidIssuer,
+		}
 	}
-	return iss == v.ExpectedISS
+	return nil
 }
 
-func (v *Validator) checkSubjectClaim(sub string, err error) bool {
-	// If expected is false or empty, skip (return true)
+func (v *Validator) checkSubjectClaim(sub string, err error) *ValidationFailure {
+	// If expected is false or empty, skip (return nil)
 	if v.ExpectedSUB == "" {
-		return true
+		return nil
 	}
-	if err != nil && err != SkipValidation {
-		return false
+	if (err != nil && err != SkipValidation) || sub != v.ExpectedSUB {
+		return &ValidationFailure{
+			Check:    CheckSubject,
+			Expected: v.ExpectedSUB,
+			Actual:   sub,
+			Err:      ErrTokenInvalidSubject,
+		}
 	}
-	return sub == v.ExpectedSUB
+	return nil
 }
 
-func (v *Validator) checkAudienceClaim(aud string, err error) bool {
-	// If expected is false or empty, skip (return true)
-	if v.ExpectedAUD == "" {
-		return true
+func (v *Validator) checkAudienceClaim(aud Audience, err error) *ValidationFailure {
+	// If expected is false or empty, skip (return nil)
+	if v.ExpectedAUD == "" && len(v.ExpectedAUDs) == 0 {
+		return nil
+	}
+	expected := v.ExpectedAUDs
+	if v.ExpectedAUD != "" {
+		expected = append([]string{v.ExpectedAUD}, expected...)
+	}
+	f := &ValidationFailure{
+		Check:    CheckAudience,
+		Expected: expected,
+		Actual:   aud,
+		Err:      ErrTokenInvalidAudience,
 	}
 	if err != nil && err != SkipValidation {
-		return false
+		return f
+	}
+	for _, e := range expected {
+		found := aud.Contains(e)
+		if found && !v.RequireAllAUDs {
+			return nil
+		}
+		if !found && v.RequireAllAUDs {
+			f.Detail = "missing audience " + e
+			return f
+		}
+	}
+	if v.RequireAllAUDs {
+		return nil
 	}
-	return aud == v.ExpectedAUD
+	return f
 }
 
-func (v *Validator) checkExpiresAtClaim(claim func() (NumericDate, error), now time.Time) bool {
+func (v *Validator) checkExpiresAtClaim(claim func() (NumericDate, error), now time.Time) *ValidationFailure {
 	exp, err := claim()
 	if err != nil && err != SkipValidation {
-		return false
+		return &ValidationFailure{
+			Check:  CheckExpiresAt,
+			Detail: "missing exp claim",
+			Err:    ErrTokenExpired,
+		}
+	}
+	bound := exp.Time().Add(+v.leeway(v.ExpLeeway))
+	if now.Before(bound) {
+		return nil
+	}
+	return &ValidationFailure{
+		Check:    CheckExpiresAt,
+		Expected: bound,
+		Actual:   now,
+		Detail:   "expired " + now.Sub(bound).Truncate(time.Second).String() + " ago",
+		Err:      ErrTokenExpired,
 	}
-	return now.Before(exp.Time().Add(+v.Margin))
 }
 
-func (v *Validator) checkIssuedAtClaim(claim func() (NumericDate, error), now time.Time) bool {
+func (v *Validator) checkIssuedAtClaim(claim func() (NumericDate, error), now time.Time) *ValidationFailure {
 	if !v.ValidateIAT {
-		return true
+		return nil
 	}
 	iat, err := claim()
+	if err == ErrTokenClaimNotFound {
+		// The iat claim is optional
+		return nil
+	}
 	if err != nil && err != SkipValidation {
-		return false
+		return &ValidationFailure{
+			Check:  CheckIssuedAt,
+			Detail: "malformed iat claim",
+			Err:    ErrTokenUsedBeforeIssued,
+		}
+	}
+	bound := iat.Time().Add(-v.leeway(v.IatLeeway))
+	if !now.Before(bound) {
+		return nil
+	}
+	return &ValidationFailure{
+		Check:    CheckIssuedAt,
+		Expected: bound,
+		Actual:   now,
+		Detail:   "issued " + bound.Sub(now).Truncate(time.Second).String() + " in the future",
+		Err:      ErrTokenUsedBeforeIssued,
 	}
-	return !now.Before(iat.Time().Add(-v.Margin))
 }
 
-func (v *Validator) checkNotBeforeClaim(claim func() (NumericDate, error), now time.Time) bool {
+func (v *Validator) checkNotBeforeClaim(claim func() (NumericDate, error), now time.Time) *ValidationFailure {
 	nbf, err := claim()
+	if err == ErrTokenClaimNotFound {
+		// The nbf claim is optional
+		return nil
+	}
+	if err != nil && err != SkipValidation {
+		return &ValidationFailure{
+			Check:  CheckNotBefore,
+			Detail: "malformed nbf claim",
+			Err:    ErrTokenNotValidYet,
+		}
+	}
+	bound := nbf.Time().Add(-v.leeway(v.NbfLeeway))
+	if !now.Before(bound) {
+		return nil
+	}
+	return &ValidationFailure{
+		Check:    CheckNotBefore,
+		Expected: bound,
+		Actual:   now,
+		Detail:   "valid in " + bound.Sub(now).Truncate(time.Second).String(),
+		Err:      ErrTokenNotValidYet,
+	}
+}
+
+func (v *Validator) checkMaxAge(claim func() (NumericDate, error), now time.Time) *ValidationFailure {
+	if v.MaxAge == 0 {
+		return nil
+	}
+	iat, err := claim()
 	if err != nil && err != SkipValidation {
-		return false
+		return &ValidationFailure{
+			Check:  CheckMaxAge,
+			Detail: "missing iat claim",
+			Err:    ErrTokenTooOld,
+		}
+	}
+	bound := iat.Time().Add(v.MaxAge + v.leeway(v.IatLeeway))
+	if !now.After(bound) {
+		return nil
+	}
+	return &ValidationFailure{
+		Check:    CheckMaxAge,
+		Expected: bound,
+		Actual:   now,
+		Detail:   "issued " + now.Sub(iat.Time()).Truncate(time.Second).String() + " ago",
+		Err:      ErrTokenTooOld,
+	}
+}
+
+func (v *Validator) checkRequiredClaims(claims ClaimsSet) *ValidationFailure {
+	var missing []string
+	for _, name := range v.RequiredClaims {
+		if !hasClaim(claims, name) {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return &ValidationFailure{
+		Check:    CheckRequiredClaims,
+		Expected: missing,
+		Detail:   "missing " + strings.Join(missing, ", "),
+		Err:      ErrTokenClaimNotFound,
+	}
+}
+
+// hasClaim reports whether the claim with the provided name is present.
+// Custom claims sets are marshaled to find claims that do not have a
+// getter.
+func hasClaim(claims ClaimsSet, name string) bool {
+	if m, ok := claims.(MapClaims); ok {
+		_, found := m[name]
+		return found
+	}
+	var err error
+	switch name {
+	case "iss":
+		_, err = claims.GetISS()
+	case "sub":
+		_, err = claims.GetSUB()
+	case "aud":
+		_, err = claims.GetAUD()
+	case "exp":
+		_, err = claims.GetEXP()
+	case "nbf":
+		_, err = claims.GetNBF()
+	case "iat":
+		_, err = claims.GetIAT()
+	case "jti":
+		_, err = claims.GetJTI()
+	default:
+		dat, err := json.Marshal(claims)
+		if err != nil {
+			return false
+		}
+		var m map[string]json.RawMessage
+		if err = json.Unmarshal(dat, &m); err != nil {
+			return false
+		}
+		v, found := m[name]
+		return found && string(v) != "null"
 	}
-	return !now.Before(nbf.Time().Add(-v.Margin))
+	return err == nil
 }
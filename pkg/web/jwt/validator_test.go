@@ -2,17 +2,23 @@ package jwt
 
 import (
 	"crypto"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
 	"testing"
+	"time"
 )
 
-func benchmarkValidateToken(b *testing.B, validator Validator, raw RawToken) {
+func benchmarkValidateToken(b *testing.B, validator Validator, raw RawToken, key crypto.PublicKey) {
 	b.Helper()
 	b.ReportAllocs()
 	b.ResetTimer()
 	b.RunParallel(
 		func(pb *testing.PB) {
 			for pb.Next() {
-				_, err := validator.ValidateToken(raw, validator.Method)
+				_, err := validator.ValidateToken(raw, key)
 				if err != nil {
 					b.Fatal(err)
 				}
@@ -57,3 +63,106 @@ func benchmarkSigning(b *testing.B, method SigningMethod, key crypto.PrivateKey)
 		},
 	)
 }
+
+func TestValidator_Algorithms(t *testing.T) {
+	claims := MapClaims{"exp": NumericDateNow().Add(time.Hour)}
+	hs256, _ := NewToken(HS256, claims, hmacTestKey)
+	hs512, _ := NewToken(HS512, claims, hmacTestKey)
+
+	tests := []struct {
+		name  string
+		v     Validator
+		raw   RawToken
+		valid bool
+	}{
+		{"method", Validator{Method: HS256}, hs256, true},
+		{"method mismatch", Validator{Method: HS256}, hs512, false},
+		{"allow-list", Validator{Algorithms: []string{"HS256", "HS512"}}, hs512, true},
+		{"not in allow-list", Validator{Algorithms: []string{"HS256"}}, hs512, false},
+		{"method and allow-list", Validator{Method: HS256, Algorithms: []string{"HS512"}}, hs256, false},
+		{"nothing allowed", Validator{}, hs256, false},
+	}
+	for _, tt := range tests {
+		_, err := tt.v.ValidateToken(tt.raw, hmacTestKey)
+		if tt.valid && err != nil {
+			t.Errorf("[%s] unexpected error: %v", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrTokenUnverifiable) {
+			t.Errorf("[%s] expected %v, got %v", tt.name, ErrTokenUnverifiable, err)
+		}
+	}
+}
+
+func TestValidator_KeyConfusion(t *testing.T) {
+	keys := RS256.GenerateKeyPair()
+	der, err := x509.MarshalPKIXPublicKey(keys.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	// An attacker signs a token with HS256, using the (public) PEM encoded
+	// RSA key as the HMAC secret. HS256 refuses to sign with it...
+	claims := MapClaims{"exp": NumericDateNow().Add(time.Hour)}
+	_, err = NewToken(HS256, claims, publicPEM)
+	if err != ErrInvalidKeyType {
+		t.Errorf("expected %v, got %v", ErrInvalidKeyType, err)
+	}
+	// ...so we forge the signature by hand
+	hdr := Base64Encode([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	body := Base64Encode([]byte(`{"exp":9999999999}`))
+	mac := hmac.New(sha256.New, publicPEM)
+	mac.Write([]byte(string(hdr) + "." + string(body)))
+	forged := RawToken(string(hdr) + "." + string(body) + "." + string(Base64Encode(mac.Sum(nil))))
+
+	// A verifier that accepts both families and hands the same PEM bytes
+	// to whatever method the token names must still reject it.
+	v := &Validator{Algorithms: []string{"RS256", "HS256"}}
+	_, err = v.ValidateToken(forged, publicPEM)
+	var verr *ValidationError
+	if !errors.As(err, &verr) || !verr.Has(CheckKeyType) {
+		t.Fatalf("expected a key type failure, got %v", err)
+	}
+	if !errors.Is(err, ErrInvalidKeyType) {
+		t.Errorf("expected %v, got %v", ErrInvalidKeyType, err)
+	}
+
+	// The same goes for an EC key of the wrong curve
+	es384 := ES384.GenerateKeyPair()
+	raw, _ := NewToken(ES256, claims, ES256.GenerateKeyPair().PrivateKey)
+	v = &Validator{Method: ES256}
+	_, err = v.ValidateToken(raw, es384.PublicKey)
+	if !errors.Is(err, ErrInvalidKeyType) {
+		t.Errorf("expected %v, got %v", ErrInvalidKeyType, err)
+	}
+}
+
+func TestValidator_Critical(t *testing.T) {
+	claims := MapClaims{"exp": NumericDateNow().Add(time.Hour)}
+	b64 := false
+	tests := []struct {
+		name  string
+		hdr   TokenHeader
+		valid bool
+	}{
+		{"no crit", TokenHeader{}, true},
+		{"understood", TokenHeader{Crit: []string{"exp"}}, true},
+		{"not understood", TokenHeader{Crit: []string{"tenant"}}, false},
+		{"registered header", TokenHeader{Crit: []string{"kid"}}, false},
+		{"b64", TokenHeader{B64: &b64, Crit: []string{"b64"}}, false},
+	}
+	v := &Validator{Method: HS256, CriticalHeaders: []string{"exp", "b64"}}
+	for _, tt := range tests {
+		raw, err := NewTokenWithHeader(HS256, tt.hdr, claims, hmacTestKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = v.ValidateToken(raw, hmacTestKey)
+		if tt.valid && err != nil {
+			t.Errorf("[%s] unexpected error: %v", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrJWSUnsupportedCrit) {
+			t.Errorf("[%s] expected %v, got %v", tt.name, ErrJWSUnsupportedCrit, err)
+		}
+	}
+}